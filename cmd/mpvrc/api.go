@@ -0,0 +1,1007 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/history"
+	"github.com/miere43/mpvrc/internal/media"
+	"github.com/miere43/mpvrc/internal/mpv"
+	"github.com/miere43/mpvrc/internal/policy"
+	"github.com/miere43/mpvrc/internal/prefs"
+)
+
+// openAPIDocument describes routes in apiRoutes.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+const maxAPIRequestSize = 64 << 10
+
+var errBadRequest = errors.New("bad request")
+
+type apiRoute struct {
+	Method  string
+	Path    string
+	Handler func(s *httpServer, w http.ResponseWriter, r *http.Request)
+}
+
+// apiRoutes is typed API built on mpv client. All routes require authentication.
+var apiRoutes = []apiRoute{
+	{"GET", "/api/state", (*httpServer).apiState},
+	{"GET", "/api/playlist", (*httpServer).apiPlaylist},
+	{"GET", "/api/tracks", (*httpServer).apiTracks},
+	{"POST", "/api/playback/pause", (*httpServer).apiPause},
+	{"POST", "/api/playback/resume", (*httpServer).apiResume},
+	{"POST", "/api/playback/stop", (*httpServer).apiStop},
+	{"POST", "/api/playback/seek", (*httpServer).apiSeek},
+	{"POST", "/api/playback/volume", (*httpServer).apiVolume},
+	{"POST", "/api/playback/speed", (*httpServer).apiSpeed},
+	{"POST", "/api/playback/load", (*httpServer).apiLoad},
+	{"POST", "/api/playlist/next", (*httpServer).apiPlaylistNext},
+	{"POST", "/api/playlist/prev", (*httpServer).apiPlaylistPrev},
+	{"POST", "/api/playlist/append", (*httpServer).apiPlaylistAppend},
+	{"POST", "/api/playlist/folder", (*httpServer).apiPlaylistFolder},
+	{"POST", "/api/playlist/move", (*httpServer).apiPlaylistMove},
+	{"POST", "/api/playlist/shuffle", (*httpServer).apiPlaylistShuffle},
+	{"POST", "/api/playlist/clear", (*httpServer).apiPlaylistClear},
+	{"POST", "/api/playlist/{index}/play", (*httpServer).apiPlaylistPlay},
+	{"DELETE", "/api/playlist/{index}", (*httpServer).apiPlaylistRemove},
+	{"POST", "/api/tracks/add", (*httpServer).apiAddTrack},
+	{"POST", "/api/tracks/adjust", (*httpServer).apiAdjustTracks},
+	{"POST", "/api/tracks/{type}/{id}", (*httpServer).apiSelectTrack},
+	{"GET", "/api/chapters", (*httpServer).apiChapters},
+	{"POST", "/api/chapters/next", (*httpServer).apiChapterNext},
+	{"POST", "/api/chapters/prev", (*httpServer).apiChapterPrev},
+	{"POST", "/api/chapters/skip-intro", (*httpServer).apiSkipIntro},
+	{"POST", "/api/chapters/auto-skip-intro", (*httpServer).apiAutoSkipIntro},
+	{"POST", "/api/chapters/{index}/play", (*httpServer).apiChapterPlay},
+	{"GET", "/api/audio/devices", (*httpServer).apiAudioDevices},
+	{"POST", "/api/audio/device", (*httpServer).apiSetAudioDevice},
+	{"GET", "/api/history", (*httpServer).apiHistory},
+	{"DELETE", "/api/history", (*httpServer).apiHistoryRemove},
+	{"POST", "/api/history/resume", (*httpServer).apiHistoryResume},
+	{"GET", "/api/preferences", (*httpServer).apiPreferences},
+	{"PUT", "/api/preferences", (*httpServer).apiSetPreferences},
+	{"DELETE", "/api/preferences", (*httpServer).apiPreferencesRemove},
+	{"GET", "/api/sleep-timer", (*httpServer).apiSleepTimer},
+	{"PUT", "/api/sleep-timer", (*httpServer).apiSetSleepTimer},
+	{"DELETE", "/api/sleep-timer", (*httpServer).apiCancelSleepTimer},
+}
+
+func (s *httpServer) registerAPI(h *http.ServeMux) {
+	h.HandleFunc("GET /api/openapi.json", s.openAPI)
+	for _, route := range apiRoutes {
+		handler := route.Handler
+		h.HandleFunc(route.Method+" "+route.Path, s.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+			handler(s, w, r)
+		}))
+	}
+}
+
+func (s *httpServer) openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument)
+}
+
+// apiError is body of typed API error responses.
+type apiError struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// apiErrorStatus maps err to HTTP status code and machine-readable error code.
+func apiErrorStatus(err error) (int, string) {
+	var commandErr *mpv.CommandError
+	switch {
+	case errors.Is(err, errBadRequest), errors.Is(err, mpv.ErrInvalidParameter):
+		return http.StatusBadRequest, "bad_request"
+	case errors.Is(err, policy.ErrForbidden):
+		return http.StatusForbidden, "forbidden"
+	case errors.Is(err, mpv.ErrPropertyNotFound), errors.Is(err, media.ErrNoFiles), errors.Is(err, fs.ErrNotExist),
+		errors.Is(err, history.ErrEntryNotFound), errors.Is(err, errNothingToResume), errors.Is(err, prefs.ErrNotFound),
+		errors.Is(err, errNoSleepTimer):
+		return http.StatusNotFound, "not_found"
+	case errors.Is(err, mpv.ErrPropertyUnavailable), errors.Is(err, errNotInIntro):
+		return http.StatusConflict, "unavailable"
+	case errors.Is(err, errNotConnected), errors.Is(err, mpv.ErrClosed):
+		return http.StatusServiceUnavailable, "not_connected"
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "timeout"
+	case errors.As(err, &commandErr):
+		return http.StatusBadGateway, "mpv_error"
+	default:
+		return http.StatusInternalServerError, "internal"
+	}
+}
+
+func (s *httpServer) writeAPIError(w http.ResponseWriter, r *http.Request, err error) {
+	status, code := apiErrorStatus(err)
+	if status == http.StatusForbidden {
+		slog.Warn("rejected API request", "path", r.URL.Path, "remoteAddr", r.RemoteAddr, "err", err)
+	} else {
+		slog.Error("failed to handle API request", "path", r.URL.Path, "status", status, "err", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiError{Error: err.Error(), Code: code})
+}
+
+func (s *httpServer) writeAPIResult(w http.ResponseWriter, r *http.Request, result any) {
+	output, err := json.Marshal(result)
+	if err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(output)
+}
+
+// decodeAPIRequest decodes JSON request body into v. Unknown fields are rejected, so typos don't go unnoticed.
+func decodeAPIRequest(w http.ResponseWriter, r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIRequestSize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%w: %w", errBadRequest, err)
+	}
+	return nil
+}
+
+// runAPI runs action on mpv connection. command is the raw command equivalent to action, it is checked
+// against the policy, so typed API can't do anything /command can't. Action takes connection first,
+// so method expressions like (*mpv.Conn).Pause can be passed.
+func (s *httpServer) runAPI(r *http.Request, command []any, action func(conn *mpv.Conn, ctx context.Context) error) error {
+	if err := s.policy.Check(command); err != nil {
+		return err
+	}
+
+	conn, err := s.app.MPV()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.commandTimeout)
+	defer cancel()
+	return action(conn, ctx)
+}
+
+// apiAction runs action with runAPI and replies with 204 No Content on success.
+func (s *httpServer) apiAction(w http.ResponseWriter, r *http.Request, command []any, action func(conn *mpv.Conn, ctx context.Context) error) {
+	if err := s.runAPI(r, command, action); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiGet reads value with get and writes it as JSON. Reading property must be allowed by the policy.
+func apiGet[T any](s *httpServer, w http.ResponseWriter, r *http.Request, property string, get func(conn *mpv.Conn, ctx context.Context) (T, error)) {
+	var result T
+	err := s.runAPI(r, []any{"get_property", property}, func(conn *mpv.Conn, ctx context.Context) error {
+		var err error
+		result, err = get(conn, ctx)
+		return err
+	})
+	if err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	s.writeAPIResult(w, r, result)
+}
+
+// playbackState is state of the player returned by /api/state.
+type playbackState struct {
+	Connected    bool                `json:"connected"`
+	Path         *string             `json:"path"`
+	Pause        bool                `json:"pause"`
+	PlaybackTime *float64            `json:"playbackTime"`
+	Duration     *float64            `json:"duration"`
+	Volume       float64             `json:"volume"`
+	Speed        float64             `json:"speed"`
+	Tracks       []mpv.Track         `json:"tracks"`
+	Playlist     []mpv.PlaylistEntry `json:"playlist"`
+	Chapters     []mpv.Chapter       `json:"chapters"`
+	// Chapter is index of the current chapter, -1 if there is none.
+	Chapter       int               `json:"chapter"`
+	Intro         bool              `json:"intro"`
+	AutoSkipIntro bool              `json:"autoSkipIntro"`
+	AudioDevice   *string           `json:"audioDevice"`
+	AudioDevices  []mpv.AudioDevice `json:"audioDevices"`
+	// Selected are IDs of selected tracks.
+	Selected trackSelection `json:"selected"`
+	// SubDelay and AudioDelay are in seconds.
+	SubDelay   float64 `json:"subDelay"`
+	AudioDelay float64 `json:"audioDelay"`
+	SubScale   float64 `json:"subScale"`
+	// SubPos is vertical position of subtitles in percent of screen height.
+	SubPos float64 `json:"subPos"`
+}
+
+// PlaybackState returns state built from the latest values of global properties, so it doesn't wait for mpv.
+func (app *App) PlaybackState() playbackState {
+	app.m.Lock()
+	defer app.m.Unlock()
+
+	state := playbackState{
+		Connected:     app.mpv != nil,
+		Selected:      app.selectedTracks(),
+		Intro:         app.chapters.intro,
+		AutoSkipIntro: app.chapters.autoSkipIntro,
+	}
+	state.Chapters, state.Chapter = app.currentChapter()
+	values := map[string]any{
+		"path":              &state.Path,
+		"pause":             &state.Pause,
+		"playback-time":     &state.PlaybackTime,
+		"duration":          &state.Duration,
+		"volume":            &state.Volume,
+		"speed":             &state.Speed,
+		"track-list":        &state.Tracks,
+		"sub-delay":         &state.SubDelay,
+		"audio-delay":       &state.AudioDelay,
+		"sub-scale":         &state.SubScale,
+		"sub-pos":           &state.SubPos,
+		"playlist":          &state.Playlist,
+		"audio-device":      &state.AudioDevice,
+		"audio-device-list": &state.AudioDevices,
+	}
+	for name, value := range values {
+		if err := json.Unmarshal(app.globals.properties[name], value); err != nil {
+			slog.Error("failed to unmarshal global property", "name", name, "err", err)
+		}
+	}
+	if state.Tracks == nil {
+		state.Tracks = []mpv.Track{}
+	}
+	if state.Playlist == nil {
+		state.Playlist = []mpv.PlaylistEntry{}
+	}
+	if state.Chapters == nil {
+		state.Chapters = []mpv.Chapter{}
+	}
+	if state.AudioDevices == nil {
+		state.AudioDevices = []mpv.AudioDevice{}
+	}
+	return state
+}
+
+func (s *httpServer) apiState(w http.ResponseWriter, r *http.Request) {
+	s.writeAPIResult(w, r, s.app.PlaybackState())
+}
+
+func (s *httpServer) apiPlaylist(w http.ResponseWriter, r *http.Request) {
+	apiGet(s, w, r, "playlist", (*mpv.Conn).Playlist)
+}
+
+func (s *httpServer) apiTracks(w http.ResponseWriter, r *http.Request) {
+	apiGet(s, w, r, "track-list", (*mpv.Conn).Tracks)
+}
+
+func (s *httpServer) apiPause(w http.ResponseWriter, r *http.Request) {
+	s.apiAction(w, r, []any{"set_property", "pause", true}, (*mpv.Conn).Pause)
+}
+
+func (s *httpServer) apiResume(w http.ResponseWriter, r *http.Request) {
+	s.apiAction(w, r, []any{"set_property", "pause", false}, (*mpv.Conn).Resume)
+}
+
+func (s *httpServer) apiStop(w http.ResponseWriter, r *http.Request) {
+	s.apiAction(w, r, []any{"stop"}, (*mpv.Conn).Stop)
+}
+
+var seekModes = []mpv.SeekMode{mpv.SeekRelative, mpv.SeekAbsolute, mpv.SeekRelativeExact, mpv.SeekAbsoluteExact}
+
+func (s *httpServer) apiSeek(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		// Position is in seconds.
+		Position *float64     `json:"position"`
+		Mode     mpv.SeekMode `json:"mode"`
+	}
+	if err := decodeAPIRequest(w, r, &request); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	if request.Position == nil {
+		s.writeAPIError(w, r, fmt.Errorf("%w: position is required", errBadRequest))
+		return
+	}
+	if request.Mode == "" {
+		request.Mode = mpv.SeekRelative
+	} else if !slices.Contains(seekModes, request.Mode) {
+		s.writeAPIError(w, r, fmt.Errorf("%w: unknown seek mode %q", errBadRequest, request.Mode))
+		return
+	}
+
+	position := *request.Position
+	s.apiAction(w, r, []any{"seek", position, string(request.Mode)}, func(conn *mpv.Conn, ctx context.Context) error {
+		return conn.Seek(ctx, time.Duration(position*float64(time.Second)), request.Mode)
+	})
+}
+
+func (s *httpServer) apiVolume(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Volume *float64 `json:"volume"`
+	}
+	if err := decodeAPIRequest(w, r, &request); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	if request.Volume == nil || *request.Volume < 0 {
+		s.writeAPIError(w, r, fmt.Errorf("%w: volume must be a non-negative number", errBadRequest))
+		return
+	}
+
+	volume := *request.Volume
+	s.apiAction(w, r, []any{"set_property", "volume", volume}, func(conn *mpv.Conn, ctx context.Context) error {
+		if err := conn.SetVolume(ctx, volume); err != nil {
+			return err
+		}
+		// Volume is remembered for audio device instead when it's restored on switch.
+		if !s.app.config.Audio.RememberVolume {
+			s.app.RememberPreferences(func(p *prefs.Preferences) { p.Volume = &volume })
+		}
+		return nil
+	})
+}
+
+func (s *httpServer) apiSpeed(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Speed *float64 `json:"speed"`
+	}
+	if err := decodeAPIRequest(w, r, &request); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	if request.Speed == nil || *request.Speed <= 0 {
+		s.writeAPIError(w, r, fmt.Errorf("%w: speed must be a positive number", errBadRequest))
+		return
+	}
+
+	speed := *request.Speed
+	s.apiAction(w, r, []any{"set_property", "speed", speed}, func(conn *mpv.Conn, ctx context.Context) error {
+		return conn.SetSpeed(ctx, speed)
+	})
+}
+
+func (s *httpServer) apiLoad(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Path string           `json:"path"`
+		Mode mpv.LoadFileMode `json:"mode"`
+	}
+	if err := decodeAPIRequest(w, r, &request); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	if request.Mode == "" {
+		request.Mode = mpv.LoadFileReplace
+	}
+
+	// Policy checks that path is allowed and mode is known.
+	s.apiAction(w, r, []any{"loadfile", request.Path, string(request.Mode)}, func(conn *mpv.Conn, ctx context.Context) error {
+		return conn.LoadFile(ctx, request.Path, request.Mode, nil)
+	})
+}
+
+func (s *httpServer) apiPlaylistNext(w http.ResponseWriter, r *http.Request) {
+	s.apiAction(w, r, []any{"playlist-next"}, (*mpv.Conn).PlaylistNext)
+}
+
+func (s *httpServer) apiPlaylistPrev(w http.ResponseWriter, r *http.Request) {
+	s.apiAction(w, r, []any{"playlist-prev"}, (*mpv.Conn).PlaylistPrev)
+}
+
+// apiSelectTrack selects track by ID, "no" ID turns off track of that type. "secondary-sub" type selects
+// subtitle track which is shown together with the main subtitles.
+func (s *httpServer) apiSelectTrack(w http.ResponseWriter, r *http.Request) {
+	trackType := mpv.TrackType(r.PathValue("type"))
+	property, ok := mpv.TrackProperty(trackType)
+	if !ok {
+		s.writeAPIError(w, r, fmt.Errorf("%w: unknown track type %q", errBadRequest, trackType))
+		return
+	}
+
+	if r.PathValue("id") == "no" {
+		s.apiAction(w, r, []any{"set_property", property, "no"}, func(conn *mpv.Conn, ctx context.Context) error {
+			if err := conn.DisableTrack(ctx, trackType); err != nil {
+				return err
+			}
+			s.app.RememberTrack(trackType, 0)
+			return nil
+		})
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		s.writeAPIError(w, r, fmt.Errorf("%w: track ID must be a positive number or \"no\"", errBadRequest))
+		return
+	}
+	s.apiAction(w, r, []any{"set_property", property, id}, func(conn *mpv.Conn, ctx context.Context) error {
+		if err := conn.SelectTrack(ctx, trackType, id); err != nil {
+			return err
+		}
+		s.app.RememberTrack(trackType, id)
+		return nil
+	})
+}
+
+// apiAddTrack loads external subtitle or audio file and selects it.
+func (s *httpServer) apiAddTrack(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Type mpv.TrackType `json:"type"`
+		Path string        `json:"path"`
+	}
+	if err := decodeAPIRequest(w, r, &request); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	command, ok := mpv.TrackAddCommand(request.Type)
+	if !ok {
+		s.writeAPIError(w, r, fmt.Errorf("%w: external %q tracks are not supported", errBadRequest, request.Type))
+		return
+	}
+
+	// Policy checks that path is allowed.
+	s.apiAction(w, r, []any{command, request.Path, "select"}, func(conn *mpv.Conn, ctx context.Context) error {
+		return conn.AddTrack(ctx, request.Type, request.Path)
+	})
+}
+
+// apiAdjustTracks changes subtitle and audio options which are set in the request.
+func (s *httpServer) apiAdjustTracks(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		SubDelay   *float64 `json:"subDelay"`
+		AudioDelay *float64 `json:"audioDelay"`
+		SubScale   *float64 `json:"subScale"`
+		SubPos     *float64 `json:"subPos"`
+	}
+	if err := decodeAPIRequest(w, r, &request); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	if request.SubScale != nil && (*request.SubScale <= 0 || *request.SubScale > 100) {
+		s.writeAPIError(w, r, fmt.Errorf("%w: subScale must be a number from 0 to 100", errBadRequest))
+		return
+	}
+	if request.SubPos != nil && (*request.SubPos < 0 || *request.SubPos > 150) {
+		s.writeAPIError(w, r, fmt.Errorf("%w: subPos must be a number from 0 to 150", errBadRequest))
+		return
+	}
+
+	type option struct {
+		property string
+		value    *float64
+		set      func(conn *mpv.Conn, ctx context.Context, value float64) error
+	}
+	options := slices.DeleteFunc([]option{
+		{"sub-delay", request.SubDelay, (*mpv.Conn).SetSubDelay},
+		{"audio-delay", request.AudioDelay, (*mpv.Conn).SetAudioDelay},
+		{"sub-scale", request.SubScale, (*mpv.Conn).SetSubScale},
+		{"sub-pos", request.SubPos, (*mpv.Conn).SetSubPos},
+	}, func(o option) bool { return o.value == nil })
+	if len(options) == 0 {
+		s.writeAPIError(w, r, fmt.Errorf("%w: at least one option is required", errBadRequest))
+		return
+	}
+
+	// All options are checked before anything is changed, so forbidden option doesn't leave the rest applied.
+	for _, o := range options {
+		if err := s.policy.Check([]any{"set_property", o.property, *o.value}); err != nil {
+			s.writeAPIError(w, r, err)
+			return
+		}
+	}
+
+	s.apiAction(w, r, []any{"set_property", options[0].property, *options[0].value}, func(conn *mpv.Conn, ctx context.Context) error {
+		for _, o := range options {
+			if err := o.set(conn, ctx, *o.value); err != nil {
+				return err
+			}
+		}
+		s.app.RememberPreferences(func(p *prefs.Preferences) {
+			if request.SubDelay != nil {
+				p.SubDelay = request.SubDelay
+			}
+			if request.AudioDelay != nil {
+				p.AudioDelay = request.AudioDelay
+			}
+		})
+		return nil
+	})
+}
+
+// apiPlaylistAppend adds files to the end of playlist. With play, playback starts if nothing is playing.
+func (s *httpServer) apiPlaylistAppend(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Paths []string `json:"paths"`
+		Play  bool     `json:"play"`
+	}
+	if err := decodeAPIRequest(w, r, &request); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	if len(request.Paths) == 0 {
+		s.writeAPIError(w, r, fmt.Errorf("%w: paths are required", errBadRequest))
+		return
+	}
+
+	mode := mpv.LoadFileAppend
+	if request.Play {
+		mode = mpv.LoadFileAppendPlay
+	}
+
+	// All paths are checked before anything is queued, so forbidden path doesn't leave playlist half-changed.
+	for _, path := range request.Paths {
+		if err := s.policy.Check([]any{"loadfile", path, string(mode)}); err != nil {
+			s.writeAPIError(w, r, err)
+			return
+		}
+	}
+
+	s.apiAction(w, r, []any{"loadfile", request.Paths[0], string(mode)}, func(conn *mpv.Conn, ctx context.Context) error {
+		for _, path := range request.Paths {
+			if err := conn.LoadFile(ctx, path, mode, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// apiPlaylistFolder replaces playlist with media files in folder and plays them starting from start file,
+// or from the first file if start is not set.
+func (s *httpServer) apiPlaylistFolder(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Path      string `json:"path"`
+		Start     string `json:"start"`
+		Recursive *bool  `json:"recursive"`
+	}
+	if err := decodeAPIRequest(w, r, &request); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	if !filepath.IsAbs(request.Path) {
+		s.writeAPIError(w, r, fmt.Errorf("%w: path %q must be absolute", errBadRequest, request.Path))
+		return
+	}
+	dir := filepath.Clean(request.Path)
+	if err := s.policy.CheckPath(dir); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+
+	opts := media.Options{
+		Extensions: s.app.config.Media.Extensions,
+		Recursive:  s.app.config.Media.Recursive,
+	}
+	if request.Recursive != nil {
+		opts.Recursive = *request.Recursive
+	}
+	files, err := media.ListFiles(dir, opts)
+	if err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+
+	start := 0
+	if request.Start != "" {
+		start = slices.Index(files, filepath.Clean(request.Start))
+		if start == -1 {
+			s.writeAPIError(w, r, fmt.Errorf("%w: %q is not a media file in %q", errBadRequest, request.Start, dir))
+			return
+		}
+	}
+
+	for _, path := range files {
+		if err := s.policy.Check([]any{"loadfile", path, string(mpv.LoadFileAppend)}); err != nil {
+			s.writeAPIError(w, r, err)
+			return
+		}
+	}
+	if err := s.policy.Check([]any{"playlist-play-index", start}); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+
+	// Files are appended to the stopped player and then the start entry is played, so mpv doesn't
+	// begin loading the first file only to switch away from it.
+	err = s.runAPI(r, []any{"stop"}, func(conn *mpv.Conn, ctx context.Context) error {
+		if err := conn.Stop(ctx); err != nil {
+			return err
+		}
+		for _, path := range files {
+			if err := conn.LoadFile(ctx, path, mpv.LoadFileAppend, nil); err != nil {
+				return err
+			}
+		}
+		return conn.PlaylistPlayIndex(ctx, start)
+	})
+	if err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+
+	s.writeAPIResult(w, r, struct {
+		Files []string `json:"files"`
+		Start int      `json:"start"`
+	}{
+		Files: files,
+		Start: start,
+	})
+}
+
+// apiPlaylistMove moves entry, so it has index "to" after moving.
+func (s *httpServer) apiPlaylistMove(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		From *int `json:"from"`
+		To   *int `json:"to"`
+	}
+	if err := decodeAPIRequest(w, r, &request); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	if request.From == nil || request.To == nil || *request.From < 0 || *request.To < 0 {
+		s.writeAPIError(w, r, fmt.Errorf("%w: from and to must be playlist indexes", errBadRequest))
+		return
+	}
+
+	from, to := *request.From, *request.To
+	s.apiAction(w, r, []any{"playlist-move", from, to}, func(conn *mpv.Conn, ctx context.Context) error {
+		return conn.PlaylistMove(ctx, from, to)
+	})
+}
+
+func (s *httpServer) apiPlaylistShuffle(w http.ResponseWriter, r *http.Request) {
+	s.apiAction(w, r, []any{"playlist-shuffle"}, (*mpv.Conn).PlaylistShuffle)
+}
+
+func (s *httpServer) apiPlaylistClear(w http.ResponseWriter, r *http.Request) {
+	s.apiAction(w, r, []any{"playlist-clear"}, (*mpv.Conn).PlaylistClear)
+}
+
+func (s *httpServer) apiPlaylistPlay(w http.ResponseWriter, r *http.Request) {
+	index, err := playlistIndex(r)
+	if err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	s.apiAction(w, r, []any{"playlist-play-index", index}, func(conn *mpv.Conn, ctx context.Context) error {
+		return conn.PlaylistPlayIndex(ctx, index)
+	})
+}
+
+func (s *httpServer) apiPlaylistRemove(w http.ResponseWriter, r *http.Request) {
+	index, err := playlistIndex(r)
+	if err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	s.apiAction(w, r, []any{"playlist-remove", index}, func(conn *mpv.Conn, ctx context.Context) error {
+		return conn.PlaylistRemove(ctx, index)
+	})
+}
+
+// playlistIndex parses "index" path parameter.
+func playlistIndex(r *http.Request) (int, error) {
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || index < 0 {
+		return 0, fmt.Errorf("%w: playlist index must be a non-negative number", errBadRequest)
+	}
+	return index, nil
+}
+
+func (s *httpServer) apiChapters(w http.ResponseWriter, r *http.Request) {
+	apiGet(s, w, r, "chapter-list", (*mpv.Conn).Chapters)
+}
+
+func (s *httpServer) apiChapterNext(w http.ResponseWriter, r *http.Request) {
+	s.apiAction(w, r, []any{"add", "chapter", 1}, (*mpv.Conn).NextChapter)
+}
+
+func (s *httpServer) apiChapterPrev(w http.ResponseWriter, r *http.Request) {
+	s.apiAction(w, r, []any{"add", "chapter", -1}, (*mpv.Conn).PrevChapter)
+}
+
+func (s *httpServer) apiChapterPlay(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || index < 0 {
+		s.writeAPIError(w, r, fmt.Errorf("%w: chapter index must be a non-negative number", errBadRequest))
+		return
+	}
+	s.apiAction(w, r, []any{"set_property", "chapter", index}, func(conn *mpv.Conn, ctx context.Context) error {
+		return conn.SetChapter(ctx, index)
+	})
+}
+
+// apiSkipIntro jumps to the chapter after intro if intro is playing.
+func (s *httpServer) apiSkipIntro(w http.ResponseWriter, r *http.Request) {
+	next, err := s.app.IntroEnd()
+	if err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	s.apiAction(w, r, []any{"set_property", "chapter", next}, func(conn *mpv.Conn, ctx context.Context) error {
+		return skipIntro(ctx, conn, next)
+	})
+}
+
+func (s *httpServer) apiAutoSkipIntro(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := decodeAPIRequest(w, r, &request); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	if request.Enabled == nil {
+		s.writeAPIError(w, r, fmt.Errorf("%w: enabled is required", errBadRequest))
+		return
+	}
+	// Skipping changes chapter, so it must be allowed by the policy.
+	if err := s.policy.Check([]any{"set_property", "chapter", 0}); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+
+	s.app.SetAutoSkipIntro(*request.Enabled)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *httpServer) apiAudioDevices(w http.ResponseWriter, r *http.Request) {
+	apiGet(s, w, r, "audio-device-list", (*mpv.Conn).AudioDevices)
+}
+
+func (s *httpServer) apiSetAudioDevice(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := decodeAPIRequest(w, r, &request); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	// mpv accepts any device name and only fails when audio is played.
+	if !s.app.HasAudioDevice(request.Name) {
+		s.writeAPIError(w, r, fmt.Errorf("%w: unknown audio device %q", errBadRequest, request.Name))
+		return
+	}
+
+	s.apiAction(w, r, []any{"set_property", "audio-device", request.Name}, func(conn *mpv.Conn, ctx context.Context) error {
+		return conn.SetAudioDevice(ctx, request.Name)
+	})
+}
+
+// apiHistory returns recently played files. With unfinished=true only files which can be resumed are returned.
+func (s *httpServer) apiHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			s.writeAPIError(w, r, fmt.Errorf("%w: limit must be a non-negative number", errBadRequest))
+			return
+		}
+	}
+
+	unfinished := false
+	if value := query.Get("unfinished"); value != "" {
+		var err error
+		unfinished, err = strconv.ParseBool(value)
+		if err != nil {
+			s.writeAPIError(w, r, fmt.Errorf("%w: unfinished must be a boolean", errBadRequest))
+			return
+		}
+	}
+
+	s.writeAPIResult(w, r, s.app.history.Recent(limit, unfinished))
+}
+
+// apiHistoryRemove forgets file from "path" query parameter, or the whole history if path is not set.
+func (s *httpServer) apiHistoryRemove(w http.ResponseWriter, r *http.Request) {
+	var err error
+	if path := r.URL.Query().Get("path"); path != "" {
+		err = s.app.history.Remove(path)
+	} else {
+		err = s.app.history.Clear()
+	}
+	if err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiHistoryResume continues the playing file from position where it was left last time.
+func (s *httpServer) apiHistoryResume(w http.ResponseWriter, r *http.Request) {
+	s.apiAction(w, r, []any{"seek", 0, string(mpv.SeekAbsolute)}, func(conn *mpv.Conn, ctx context.Context) error {
+		offer, err := s.app.ResumeOffer()
+		if err != nil {
+			return err
+		}
+		return resumePlayback(ctx, conn, offer)
+	})
+}
+
+// apiPreferences returns preferences remembered for file from "path" query parameter, or for the playing file.
+func (s *httpServer) apiPreferences(w http.ResponseWriter, r *http.Request) {
+	path, err := s.app.PreferencesPath(r.URL.Query().Get("path"))
+	if err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+
+	result := struct {
+		Path      string             `json:"path"`
+		File      *prefs.Preferences `json:"file"`
+		Directory *prefs.Preferences `json:"directory"`
+		// Effective are preferences which are applied when the file is loaded.
+		Effective prefs.Preferences `json:"effective"`
+	}{
+		Path:      path,
+		Effective: s.app.prefs.Lookup(path),
+	}
+	if p, ok := s.app.prefs.Get(path, prefs.ScopeFile); ok {
+		result.File = &p
+	}
+	if p, ok := s.app.prefs.Get(path, prefs.ScopeDirectory); ok {
+		result.Directory = &p
+	}
+	s.writeAPIResult(w, r, result)
+}
+
+// apiSetPreferences replaces preferences of file or of its directory. Path defaults to the playing file.
+func (s *httpServer) apiSetPreferences(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Path        string            `json:"path"`
+		Scope       prefs.Scope       `json:"scope"`
+		Preferences prefs.Preferences `json:"preferences"`
+	}
+	if err := decodeAPIRequest(w, r, &request); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	path, err := s.app.PreferencesPath(request.Path)
+	if err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	if err := checkPreferencesScope(request.Scope); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	if err := request.Preferences.Validate(); err != nil {
+		s.writeAPIError(w, r, fmt.Errorf("%w: %w", errBadRequest, err))
+		return
+	}
+	// Preferences change properties when files are loaded, so changing them must be allowed by the policy.
+	for _, property := range preferenceProperties(request.Preferences) {
+		if err := s.policy.CheckProperty(property); err != nil {
+			s.writeAPIError(w, r, err)
+			return
+		}
+	}
+
+	if err := s.app.prefs.Set(path, request.Scope, request.Preferences); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiPreferencesRemove forgets preferences of file from "path" query parameter, or of the playing file.
+// "scope" query parameter is "file" or "directory".
+func (s *httpServer) apiPreferencesRemove(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	path, err := s.app.PreferencesPath(query.Get("path"))
+	if err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	scope := prefs.Scope(query.Get("scope"))
+	if err := checkPreferencesScope(scope); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+
+	if err := s.app.prefs.Remove(path, scope); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func checkPreferencesScope(scope prefs.Scope) error {
+	if scope != prefs.ScopeFile && scope != prefs.ScopeDirectory {
+		return fmt.Errorf("%w: scope must be \"file\" or \"directory\"", errBadRequest)
+	}
+	return nil
+}
+
+func (s *httpServer) apiSleepTimer(w http.ResponseWriter, r *http.Request) {
+	status, err := s.app.SleepTimer()
+	if err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	s.writeAPIResult(w, r, status)
+}
+
+// maxSleepMinutes limits timeout of sleep timer.
+const maxSleepMinutes = 24 * 60
+
+// apiSetSleepTimer replaces sleep timer. Timer runs out after "minutes", at the end of the playing file
+// with "endOfFile" or after "files" files are played to the end.
+func (s *httpServer) apiSetSleepTimer(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Action    sleepAction `json:"action"`
+		Minutes   *float64    `json:"minutes"`
+		EndOfFile bool        `json:"endOfFile"`
+		Files     *int        `json:"files"`
+		FadeOut   bool        `json:"fadeOut"`
+	}
+	if err := decodeAPIRequest(w, r, &request); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+
+	command, ok := sleepActions[request.Action]
+	if !ok {
+		s.writeAPIError(w, r, fmt.Errorf("%w: action must be \"pause\", \"stop\" or \"quit\"", errBadRequest))
+		return
+	}
+	timer := sleepTimer{Action: request.Action, FadeOut: request.FadeOut}
+	switch {
+	case request.Minutes != nil && !request.EndOfFile && request.Files == nil:
+		if *request.Minutes <= 0 || *request.Minutes > maxSleepMinutes {
+			s.writeAPIError(w, r, fmt.Errorf("%w: minutes must be between 0 and %d", errBadRequest, maxSleepMinutes))
+			return
+		}
+		timer.After = time.Duration(*request.Minutes * float64(time.Minute))
+	case request.Minutes == nil && request.EndOfFile && request.Files == nil:
+		timer.Files = 1
+	case request.Minutes == nil && !request.EndOfFile && request.Files != nil:
+		if *request.Files < 1 {
+			s.writeAPIError(w, r, fmt.Errorf("%w: files must be a positive number", errBadRequest))
+			return
+		}
+		timer.Files = *request.Files
+	default:
+		s.writeAPIError(w, r, fmt.Errorf("%w: exactly one of minutes, endOfFile or files is required", errBadRequest))
+		return
+	}
+
+	// Timer runs the action later, so the action must be allowed by the policy now.
+	if err := s.policy.Check(command); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	if request.FadeOut {
+		if err := s.policy.Check([]any{"set_property", "volume", 0.0}); err != nil {
+			s.writeAPIError(w, r, err)
+			return
+		}
+	}
+
+	s.app.SetSleepTimer(timer)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *httpServer) apiCancelSleepTimer(w http.ResponseWriter, r *http.Request) {
+	if err := s.app.CancelSleepTimer(); err != nil {
+		s.writeAPIError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/mpv"
+	"github.com/miere43/mpvrc/internal/policy"
+)
+
+func (s *appSuite) api(srv *httptest.Server, method, path, body string) *http.Response {
+	request, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	s.Require().NoError(err)
+	request.Header.Set("Content-Type", "application/json")
+	response := s.do(request)
+	s.T().Cleanup(func() { response.Body.Close() })
+	return response
+}
+
+func (s *appSuite) decodeAPIError(response *http.Response) apiError {
+	s.Equal("application/json", response.Header.Get("Content-Type"))
+	var result apiError
+	s.Require().NoError(json.NewDecoder(response.Body).Decode(&result))
+	return result
+}
+
+func (s *appSuite) TestAPIPlayback() {
+	r := s.Require()
+	events := s.listen()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/load", `{"path": "/video/a.mkv"}`).StatusCode)
+	s.Equal("/video/a.mkv", s.fake.Property("path"))
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/pause", "").StatusCode)
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/volume", `{"volume": 40}`).StatusCode)
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/speed", `{"speed": 1.5}`).StatusCode)
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/seek", `{"position": 30, "mode": "absolute"}`).StatusCode)
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/tracks/sub/2", "").StatusCode)
+	s.Equal(2.0, s.fake.Property("sid"))
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/tracks/sub/no", "").StatusCode)
+	s.Equal("no", s.fake.Property("sid"))
+
+	s.waitForEvent(events, "playback-time", 30.0)
+
+	response := s.api(srv, "GET", "/api/state", "")
+	r.Equal(http.StatusOK, response.StatusCode)
+	var state playbackState
+	r.NoError(json.NewDecoder(response.Body).Decode(&state))
+	s.True(state.Connected)
+	s.True(state.Pause)
+	s.Equal(40.0, state.Volume)
+	s.Equal(1.5, state.Speed)
+	r.NotNil(state.Path)
+	s.Equal("/video/a.mkv", *state.Path)
+	r.NotNil(state.PlaybackTime)
+	s.Equal(30.0, *state.PlaybackTime)
+	s.Nil(state.Duration)
+	s.Equal([]mpv.Track{}, state.Tracks)
+
+	s.fake.SetProperty("playlist", []any{map[string]any{"id": 1, "filename": "/video/a.mkv", "current": true}})
+	response = s.api(srv, "GET", "/api/playlist", "")
+	r.Equal(http.StatusOK, response.StatusCode)
+	var playlist []mpv.PlaylistEntry
+	r.NoError(json.NewDecoder(response.Body).Decode(&playlist))
+	s.Equal([]mpv.PlaylistEntry{{ID: 1, Filename: "/video/a.mkv", Current: true}}, playlist)
+}
+
+// playlistFilenames returns filenames of playlist entries from /api/state and index of the current entry.
+func (s *appSuite) playlistFilenames(srv *httptest.Server) ([]string, int) {
+	response := s.api(srv, "GET", "/api/state", "")
+	s.Require().Equal(http.StatusOK, response.StatusCode)
+	var state playbackState
+	s.Require().NoError(json.NewDecoder(response.Body).Decode(&state))
+
+	var filenames []string
+	current := -1
+	for i, entry := range state.Playlist {
+		filenames = append(filenames, entry.Filename)
+		if entry.Current {
+			current = i
+		}
+	}
+	return filenames, current
+}
+
+func (s *appSuite) TestAPIPlaylist() {
+	r := s.Require()
+	events := s.listen()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playlist/append", `{"paths": ["/video/1.mkv", "/video/2.mkv", "/video/3.mkv"], "play": true}`).StatusCode)
+	s.waitForEventFunc(events, "playlist", func(value any) bool { return len(value.([]any)) == 3 })
+	filenames, current := s.playlistFilenames(srv)
+	s.Equal([]string{"/video/1.mkv", "/video/2.mkv", "/video/3.mkv"}, filenames)
+	s.Equal(0, current)
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playlist/move", `{"from": 0, "to": 2}`).StatusCode)
+	s.waitForEventFunc(events, "playlist", func(value any) bool { return value.([]any)[2].(map[string]any)["filename"] == "/video/1.mkv" })
+	filenames, current = s.playlistFilenames(srv)
+	s.Equal([]string{"/video/2.mkv", "/video/3.mkv", "/video/1.mkv"}, filenames)
+	s.Equal(2, current, "playing entry is moved too")
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playlist/0/play", "").StatusCode)
+	s.waitForEvent(events, "path", "/video/2.mkv")
+
+	r.Equal(http.StatusNoContent, s.api(srv, "DELETE", "/api/playlist/1", "").StatusCode)
+	s.waitForEventFunc(events, "playlist", func(value any) bool { return len(value.([]any)) == 2 })
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playlist/clear", "").StatusCode)
+	s.waitForEventFunc(events, "playlist", func(value any) bool { return len(value.([]any)) == 1 })
+	filenames, current = s.playlistFilenames(srv)
+	s.Equal([]string{"/video/2.mkv"}, filenames)
+	s.Equal(0, current)
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playlist/shuffle", "").StatusCode)
+
+	response := s.api(srv, "POST", "/api/playlist/append", `{"paths": ["/video/4.mkv", "video.mkv"]}`)
+	r.Equal(http.StatusForbidden, response.StatusCode)
+	filenames, _ = s.playlistFilenames(srv)
+	s.Equal([]string{"/video/2.mkv"}, filenames, "nothing is queued if any path is forbidden")
+
+	response = s.api(srv, "DELETE", "/api/playlist/-1", "")
+	r.Equal(http.StatusBadRequest, response.StatusCode)
+}
+
+func (s *appSuite) TestAPIPlayFolder() {
+	r := s.Require()
+	events := s.listen()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+
+	dir := s.T().TempDir()
+	for _, name := range []string{"Episode 10.mkv", "Episode 2.mkv", "Episode 1.mkv", "notes.txt", "Extras/OP.mkv"} {
+		path := filepath.Join(dir, name)
+		r.NoError(os.MkdirAll(filepath.Dir(path), 0o755))
+		r.NoError(os.WriteFile(path, nil, 0o644))
+	}
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/load", `{"path": "/video/old.mkv"}`).StatusCode)
+
+	body, err := json.Marshal(map[string]any{"path": dir, "start": filepath.Join(dir, "Episode 2.mkv")})
+	r.NoError(err)
+	response := s.api(srv, "POST", "/api/playlist/folder", string(body))
+	r.Equal(http.StatusOK, response.StatusCode)
+	var result struct {
+		Files []string `json:"files"`
+		Start int      `json:"start"`
+	}
+	r.NoError(json.NewDecoder(response.Body).Decode(&result))
+	expected := []string{filepath.Join(dir, "Episode 1.mkv"), filepath.Join(dir, "Episode 2.mkv"), filepath.Join(dir, "Episode 10.mkv")}
+	s.Equal(expected, result.Files)
+	s.Equal(1, result.Start)
+
+	s.waitForEvent(events, "path", expected[1])
+	filenames, current := s.playlistFilenames(srv)
+	s.Equal(expected, filenames, "previous playlist is replaced")
+	s.Equal(1, current)
+
+	body, err = json.Marshal(map[string]any{"path": dir, "recursive": true})
+	r.NoError(err)
+	response = s.api(srv, "POST", "/api/playlist/folder", string(body))
+	r.Equal(http.StatusOK, response.StatusCode)
+	r.NoError(json.NewDecoder(response.Body).Decode(&result))
+	s.Equal(filepath.Join(dir, "Extras", "OP.mkv"), result.Files[3])
+	s.Equal(0, result.Start)
+
+	tests := []struct {
+		body   map[string]any
+		status int
+	}{
+		{map[string]any{"path": "video"}, http.StatusBadRequest},
+		{map[string]any{"path": dir, "start": filepath.Join(dir, "notes.txt")}, http.StatusBadRequest},
+		{map[string]any{"path": filepath.Join(dir, "missing")}, http.StatusNotFound},
+		{map[string]any{"path": filepath.Join(dir, "Extras"), "recursive": false, "start": "/video/old.mkv"}, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		body, err := json.Marshal(test.body)
+		r.NoError(err)
+		s.Equal(test.status, s.api(srv, "POST", "/api/playlist/folder", string(body)).StatusCode, "%v", test.body)
+	}
+
+	r.NoError(os.Remove(filepath.Join(dir, "Extras", "OP.mkv")))
+	body, err = json.Marshal(map[string]any{"path": filepath.Join(dir, "Extras")})
+	r.NoError(err)
+	response = s.api(srv, "POST", "/api/playlist/folder", string(body))
+	r.Equal(http.StatusNotFound, response.StatusCode)
+	s.Equal("not_found", s.decodeAPIError(response).Code)
+}
+
+func (s *appSuite) TestAPIErrors() {
+	r := s.Require()
+	srv := s.startHTTP()
+
+	response := s.api(srv, "POST", "/api/playback/pause", "")
+	r.Equal(http.StatusServiceUnavailable, response.StatusCode)
+	s.Equal("not_connected", s.decodeAPIError(response).Code)
+
+	r.NoError(s.app.ConnectToMPV(time.Second))
+
+	tests := []struct {
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"/api/playback/volume", `{"volume": "loud"}`, http.StatusBadRequest, "bad_request"},
+		{"/api/playback/volume", `{"volme": 10}`, http.StatusBadRequest, "bad_request"},
+		{"/api/playback/volume", `{}`, http.StatusBadRequest, "bad_request"},
+		{"/api/playback/seek", `{"position": 10, "mode": "backwards"}`, http.StatusBadRequest, "bad_request"},
+		{"/api/tracks/lyrics/1", ``, http.StatusBadRequest, "bad_request"},
+		{"/api/tracks/audio/first", ``, http.StatusBadRequest, "bad_request"},
+		{"/api/playback/load", `{"path": "http://example.com/a.mkv"}`, http.StatusForbidden, "forbidden"},
+		// Nothing is playing.
+		{"/api/playback/seek", `{"position": 10}`, http.StatusBadGateway, "mpv_error"},
+	}
+	for _, test := range tests {
+		response := s.api(srv, "POST", test.path, test.body)
+		s.Equal(test.status, response.StatusCode, "%s %s", test.path, test.body)
+		s.Equal(test.code, s.decodeAPIError(response).Code, "%s %s", test.path, test.body)
+	}
+
+	s.fake.SetLatency(50 * time.Millisecond)
+	s.app.server.commandTimeout = 10 * time.Millisecond
+	response = s.api(srv, "GET", "/api/tracks", "")
+	r.Equal(http.StatusGatewayTimeout, response.StatusCode)
+	s.Equal("timeout", s.decodeAPIError(response).Code)
+}
+
+func (s *appSuite) TestAPIRespectsPolicy() {
+	r := s.Require()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+
+	cfg := s.app.config.Policy
+	cfg.Properties = []string{"pause"}
+	s.app.server.policy = policy.New(cfg)
+
+	response := s.api(srv, "POST", "/api/playback/volume", `{"volume": 10}`)
+	r.Equal(http.StatusForbidden, response.StatusCode)
+	s.Equal("forbidden", s.decodeAPIError(response).Code)
+	s.Equal(100.0, s.fake.Property("volume"))
+}
+
+func (s *appSuite) TestOpenAPIDocumentsAllRoutes() {
+	r := s.Require()
+	srv := httptest.NewServer(s.app.server.srv.Handler)
+	defer srv.Close()
+
+	// Document is public, so API clients can be generated without pairing.
+	response, err := http.Get(srv.URL + "/api/openapi.json")
+	r.NoError(err)
+	defer response.Body.Close()
+	r.Equal(http.StatusOK, response.StatusCode)
+
+	body, err := io.ReadAll(response.Body)
+	r.NoError(err)
+	var document struct {
+		Paths map[string]map[string]any `json:"paths"`
+	}
+	r.NoError(json.Unmarshal(body, &document))
+
+	routes := map[string]bool{}
+	for _, route := range apiRoutes {
+		routes[route.Method+" "+route.Path] = true
+		s.Contains(document.Paths[route.Path], strings.ToLower(route.Method), "%s %s is not documented", route.Method, route.Path)
+	}
+	for path, operations := range document.Paths {
+		for method := range operations {
+			if method != "parameters" {
+				s.True(routes[strings.ToUpper(method)+" "+path], "%s %s is documented but doesn't exist", method, path)
+			}
+		}
+	}
+}
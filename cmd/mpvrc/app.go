@@ -1,33 +1,65 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
+	"regexp"
 	"slices"
+	"strconv"
 	"sync"
-	"syscall"
 	"time"
 
+	"github.com/miere43/mpvrc/internal/auth"
+	"github.com/miere43/mpvrc/internal/config"
+	"github.com/miere43/mpvrc/internal/history"
+	"github.com/miere43/mpvrc/internal/launcher"
 	"github.com/miere43/mpvrc/internal/mpv"
 	"github.com/miere43/mpvrc/internal/pipe"
+	"github.com/miere43/mpvrc/internal/prefs"
 	"github.com/miere43/mpvrc/internal/util"
 )
 
 type App struct {
 	m                    sync.Mutex
+	subscriptionsM       sync.Mutex // Serializes observing and unobserving of subscribed properties.
 	eventListeners       []*AppEventListener
 	eventListenerCounter int
+	eventHistory         eventHistory
+	// eventEpoch distinguishes event IDs of this process from IDs of previous runs.
+	eventEpoch string
 
 	mpv       *mpv.Conn
-	mpvEvents chan any
-
-	globals *Globals
+	mpvEvents chan mpv.Event
+	mpvDialer mpv.Dialer
+	ipcServer string
+	config    config.Config
+	files     []string
+	auth      *auth.Store
+	history   *history.Store
+	watch     watchState
+	prefs     *prefs.Store
+	// prefsApplied is path of the loaded file to which preferences were applied.
+	prefsApplied string
+
+	chapters     chapterState
+	audio        audioState
+	sleep        sleepState
+	introPattern *regexp.Regexp
+
+	connState         connectionState
+	reconnectMinDelay time.Duration
+	reconnectMaxDelay time.Duration
+
+	globals       *Globals
+	subscriptions map[string]*subscribedProperty
 
 	quit    bool
 	quitApp chan struct{}
@@ -36,34 +68,95 @@ type App struct {
 	mpvCmd *exec.Cmd
 }
 
-type AppEventListener struct {
-	Events chan []byte
-	ID     int
-}
+// NewApp starts mpv and HTTP server. files are opened in mpv on startup.
+func NewApp(cfg config.Config, files []string) (*App, bool, error) {
+	ipcServer := cfg.MPV.IPCServer
+	if ipcServer == "" {
+		ipcServer = launcher.IPCServerName(cfg.MPV.Profile)
+	}
 
-const uniqueInstancePipeName = "\\\\.\\pipe\\mpvrc-unique"
+	app := newApp(cfg, mpv.PipeDialer(ipcServer))
+	app.ipcServer = ipcServer
+	app.files = files
 
-func NewApp() (*App, bool) {
-	app := &App{
-		mpvEvents: make(chan any),
+	if tokensPath := cfg.TokensPath(); tokensPath != "" {
+		store, err := auth.LoadStore(tokensPath)
+		if err != nil {
+			return nil, false, err
+		}
+		app.auth = store
+	}
+
+	if historyPath := cfg.HistoryPath(); historyPath != "" {
+		store, err := history.LoadStore(historyPath)
+		if err != nil {
+			return nil, false, err
+		}
+		app.history = store
+	}
 
-		globals: NewGlobals(),
-		quitApp: make(chan struct{}),
+	if prefsPath := cfg.PreferencesPath(); cfg.Preferences.Remember && prefsPath != "" {
+		store, err := prefs.LoadStore(prefsPath)
+		if err != nil {
+			return nil, false, err
+		}
+		app.prefs = store
+	}
+
+	if volumesPath := cfg.AudioVolumesPath(); cfg.Audio.RememberVolume && volumesPath != "" {
+		if err := app.loadAudioVolumes(volumesPath); err != nil {
+			return nil, false, err
+		}
 	}
-	app.server = newHttpServer(app)
 
 	if app.redirectToExistingApplicationInstance() {
-		return nil, false
+		return nil, false, nil
 	}
 
 	app.registerUniqueApplicationInstance()
 
+	if err := app.server.setupTLS(); err != nil {
+		return nil, false, fmt.Errorf("failed to set up HTTPS: %w", err)
+	}
+
 	go app.handleEvents()
-	app.startMPV()
+	if err := app.startMPV(); err != nil {
+		return nil, false, err
+	}
 	app.startHttpServer()
 	app.installInterruptHandler()
 
-	return app, true
+	return app, true, nil
+}
+
+// newApp creates application without starting any background activity.
+func newApp(cfg config.Config, mpvDialer mpv.Dialer) *App {
+	app := &App{
+		config:  cfg,
+		auth:    auth.NewStore(),
+		history: history.NewStore(),
+		prefs:   prefs.NewStore(),
+
+		eventEpoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+
+		mpvEvents: make(chan mpv.Event),
+		mpvDialer: mpvDialer,
+
+		connState:         connectionState{State: connectionStateConnecting},
+		reconnectMinDelay: 100 * time.Millisecond,
+		reconnectMaxDelay: 5 * time.Second,
+
+		chapters:     chapterState{autoSkipIntro: cfg.Chapters.AutoSkipIntro},
+		audio:        audioState{volumes: make(map[string]float64)},
+		sleep:        sleepState{tick: time.Second, fade: time.Minute},
+		introPattern: regexp.MustCompile(cfg.Chapters.IntroPattern),
+
+		globals:       NewGlobals(),
+		subscriptions: make(map[string]*subscribedProperty),
+		quitApp:       make(chan struct{}),
+	}
+	app.server = newHttpServer(app)
+	return app
 }
 
 func (app *App) RequestQuit() {
@@ -76,6 +169,14 @@ func (app *App) RequestQuit() {
 
 	app.quit = true
 
+	if err := app.history.Flush(); err != nil {
+		slog.Error("failed to save watch history", "err", err)
+	}
+	if err := app.prefs.Flush(); err != nil {
+		slog.Error("failed to save preferences", "err", err)
+	}
+	app.flushAudioVolumes()
+
 	if app.mpvCmd != nil && app.mpvCmd.Process != nil {
 		// TODO: implement graceful exit, SendCommand(["run", "exit"]) doesn't work.
 		if err := app.mpvCmd.Process.Kill(); err != nil {
@@ -90,21 +191,29 @@ func (app *App) Done() <-chan struct{} {
 	return app.quitApp
 }
 
-func (app *App) startMPV() {
-	args := []string{"--force-window", "--idle", "--input-ipc-server=mpvsocket"}
-	if len(os.Args) > 1 {
-		args = append(args, os.Args[1])
+func (app *App) startMPV() error {
+	options := launcher.Options{
+		Path:    app.config.MPV.Path,
+		Profile: app.config.MPV.Profile,
+		Args:    app.config.MPV.Args,
 	}
 
-	app.mpvCmd = exec.Command("C:/soft/mpv/mpv.exe", args...)
-	if err := app.mpvCmd.Start(); err != nil {
-		util.Fatal("failed to start cmd", "err", err)
+	cmd, err := options.Command(app.ipcServer, app.files)
+	if err != nil {
+		return err
 	}
 
-	if err := app.ConnectToMPV(500 * time.Millisecond); err != nil {
-		util.Fatal("failed to connect to mpv after startup", "err", err)
+	slog.Info("starting mpv", "path", cmd.Path, "args", cmd.Args[1:])
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start mpv %q: %w", cmd.Path, err)
 	}
 
+	app.m.Lock()
+	app.mpvCmd = cmd
+	app.m.Unlock()
+
+	go app.superviseMPV()
+
 	go func() {
 		slog.Info("waiting for mpv to exit...")
 		if err := app.mpvCmd.Wait(); err != nil {
@@ -112,14 +221,33 @@ func (app *App) startMPV() {
 		}
 		app.RequestQuit()
 	}()
+
+	return nil
 }
 
 func (app *App) startHttpServer() {
 	go func() {
-		if err := app.server.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if app.server.srv.TLSConfig != nil {
+			slog.Info("starting HTTPS server", "addr", app.server.srv.Addr)
+			err = app.server.srv.ListenAndServeTLS("", "")
+		} else {
+			slog.Info("starting HTTP server", "addr", app.server.srv.Addr)
+			err = app.server.srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			util.Fatal("failed to start HTTP server", "err", err)
 		}
 	}()
+
+	if redirect := app.server.redirect; redirect != nil {
+		go func() {
+			slog.Info("starting HTTPS redirect server", "addr", redirect.Addr)
+			if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				util.Fatal("failed to start HTTPS redirect server", "err", err)
+			}
+		}()
+	}
 }
 
 func (app *App) installInterruptHandler() {
@@ -138,7 +266,7 @@ func (app *App) installInterruptHandler() {
 
 func (app *App) redirectToExistingApplicationInstance() bool {
 	client, err := pipe.Dial(uniqueInstancePipeName, 0, nil)
-	if errors.Is(err, syscall.ERROR_FILE_NOT_FOUND) {
+	if errors.Is(err, os.ErrNotExist) {
 		slog.Debug("existing mpvrc instance not found, continuing with normal execution")
 		return false
 	} else if err != nil {
@@ -175,7 +303,7 @@ func (app *App) registerUniqueApplicationInstance() {
 			util.Fatal("failed to unmarshal json args", "err", err)
 		}
 
-		app.handleCommandLineFromFromOtherInstance(args)
+		app.handleCommandLineFromOtherInstance(args)
 	})
 	if err != nil {
 		util.Fatal("failed to register unique application instance", "err", err)
@@ -188,16 +316,30 @@ func (app *App) registerUniqueApplicationInstance() {
 	}()
 }
 
-func (app *App) handleCommandLineFromFromOtherInstance(args []string) {
+func (app *App) handleCommandLineFromOtherInstance(args []string) {
 	if len(args) < 2 {
 		return
 	}
 
-	loadfile := args[1]
+	_, files, err := config.Load(args[1:], func(string) string { return "" })
+	if err != nil {
+		slog.Error("failed to parse command line from other instance", "args", args, "err", err)
+		return
+	}
 
-	_, err := app.SendCommand([]any{"loadfile", loadfile}, false)
+	conn, err := app.MPV()
 	if err != nil {
-		slog.Error("failed to send loadfile command to mpv", "err", err)
+		slog.Error("failed to load file from other instance", "err", err)
+		return
+	}
+
+	// First file replaces current one, the rest are queued after it.
+	mode := mpv.LoadFileReplace
+	for _, file := range files {
+		if err := conn.LoadFile(context.Background(), file, mode, nil); err != nil {
+			slog.Error("failed to send loadfile command to mpv", "file", file, "err", err)
+		}
+		mode = mpv.LoadFileAppend
 	}
 }
 
@@ -207,17 +349,36 @@ func (app *App) handleEvents() {
 	}
 }
 
-func (app *App) handleEvent(event any) {
+func (app *App) handleEvent(event mpv.Event) {
 	// TODO: we can reduce lock scope here
 	app.m.Lock()
 	defer app.m.Unlock()
 
 	switch e := event.(type) {
 	case mpv.PropertyChange:
-		app.setGlobalPropertyValue(e.Name, e.Data)
+		if app.isGlobalProperty(e.Name) {
+			app.setGlobalPropertyValue(e.Name, e.Data)
+			app.trackWatchProgress(e.Name, e.Data)
+			app.trackChapters(e.Name)
+			app.trackAudioDevice(e.Name, e.Data)
+			app.trackPreferences(e.Name)
+		} else {
+			app.setSubscribedPropertyValue(e.Name, e.Data)
+		}
+
+	case mpv.StartFile, mpv.FileLoaded:
+		app.handleFileEvent(e)
+		app.handlePreferencesEvent(e)
+
+	case mpv.EndFile:
+		if e.Reason == mpv.EndFileReasonError {
+			slog.Warn("mpv failed to play file", "playlistEntryId", e.PlaylistEntryID, "err", e.FileError)
+		}
+		app.handleFileEvent(e)
+		app.handleSleepTimerEvent(e)
 
 	default:
-		slog.Error("handleEvent: unhandled event type", "eventType", e)
+		slog.Debug("handleEvent: ignoring event", "event", e.Event())
 	}
 }
 
@@ -227,85 +388,146 @@ func (app *App) setGlobalPropertyValue(propertyName string, value json.RawMessag
 	}
 }
 
-type globalPropertyEvent struct {
+type propertyEvent struct {
 	Event        string `json:"event"`
 	PropertyName string `json:"propertyName"`
 	Value        any    `json:"value"`
 }
 
-func (app *App) makeGlobalPropertyEvent(propertyName string, value any) globalPropertyEvent {
-	return globalPropertyEvent{
+// appEvent returns listener event with data. Events of subscribed properties are keyed separately from
+// global properties, so they are never coalesced with each other.
+func (e propertyEvent) appEvent(data []byte) AppEvent {
+	if e.Event == propertyChangeEvent {
+		return AppEvent{Key: propertyChangeEvent + ":" + e.PropertyName, Property: e.PropertyName, Data: data}
+	}
+	return AppEvent{Key: e.PropertyName, Data: data}
+}
+
+func (app *App) makeGlobalPropertyEvent(propertyName string, value any) propertyEvent {
+	return propertyEvent{
 		Event:        "set-global-property",
 		PropertyName: propertyName,
 		Value:        value,
 	}
 }
 
-func (app *App) sendEvent(event any) {
+// sendEvent queues event for all listeners, it must be called with app.m locked.
+func (app *App) sendEvent(event propertyEvent) {
 	eventJSON, err := json.Marshal(event)
 	if err != nil {
 		slog.Error("sendEvent: failed to marshal event", "err", err)
 		return
 	}
 
-	for _, listener := range app.eventListeners {
-		listener.Events <- eventJSON
-	}
+	appEvent := app.eventHistory.add(event.appEvent(eventJSON))
+
+	now := time.Now()
+	app.eventListeners = slices.DeleteFunc(app.eventListeners, func(listener *AppEventListener) bool {
+		if !listener.wants(appEvent) {
+			return false
+		}
+		if err := listener.push(appEvent, now); err != nil {
+			slog.Warn("dropping event listener", "id", listener.ID, "err", err)
+			listener.close(err)
+			return true
+		}
+		return false
+	})
 }
 
-func (app *App) connectToMPVCore(timeout time.Duration) (bool, error) {
+// connectToMPVCore returns new connection or nil if mpv was already connected.
+func (app *App) connectToMPVCore(timeout time.Duration) (*mpv.Conn, error) {
 	app.m.Lock()
 	defer app.m.Unlock()
 
-	if app.mpv != nil {
+	if app.mpv != nil && app.mpv.Context().Err() == nil {
 		slog.Debug("ConnectToMPV: mpv was already connected")
-		return false, nil
+		return nil, nil
 	}
 
-	mpv, err := mpv.Dial(app.mpvEvents, timeout)
+	mpv, err := mpv.Dial(app.mpvDialer, app.mpvEvents, timeout)
 	if err != nil {
-		return false, fmt.Errorf("failed to connect to mpv: %w", err)
+		return nil, fmt.Errorf("failed to connect to mpv: %w", err)
 	}
 
 	app.mpv = mpv
 
 	go func() {
 		<-mpv.Context().Done()
+		app.handleDisconnect(mpv)
+	}()
 
-		app.m.Lock()
-		defer app.m.Unlock()
+	return mpv, nil
+}
 
-		app.sendEvent(app.makeGlobalPropertyEvent("connected", false))
+// handleDisconnect forgets closed connection. It is safe to call multiple times for the same connection.
+func (app *App) handleDisconnect(conn *mpv.Conn) {
+	app.m.Lock()
+	defer app.m.Unlock()
 
-		app.mpv = nil // Allow us to reconnect next time.
-	}()
+	if app.mpv != conn {
+		return // Already handled.
+	}
 
-	return true, nil
+	app.sendEvent(app.makeGlobalPropertyEvent("connected", false))
+
+	app.mpv = nil // Allow us to reconnect next time.
 }
 
 func (app *App) ConnectToMPV(timeout time.Duration) error {
-	wantInit, err := app.connectToMPVCore(timeout)
+	conn, err := app.connectToMPVCore(timeout)
 	if err != nil {
 		return err
 	}
 
-	if wantInit {
-		for propertyName := range app.globals.properties {
-			app.mpv.ObserveProperty(propertyName)
+	if conn != nil {
+		ctx, cancel := context.WithTimeout(conn.Context(), timeout)
+		defer cancel()
+		for _, propertyName := range app.globalPropertyNames() {
+			if _, err := conn.ObservePropertyContext(ctx, propertyName); err != nil {
+				// mpv which accepted connection but doesn't respond is dropped, so it's dialed again.
+				conn.Disconnect()
+				return fmt.Errorf("failed to observe property %q: %w", propertyName, err)
+			}
 		}
+		app.observeSubscribedProperties(ctx, conn)
 	}
 
 	return nil
 }
 
-func (app *App) SendCommand(args []any, async bool) (mpv.MpvResponse, error) {
+func (app *App) globalPropertyNames() []string {
+	app.m.Lock()
+	defer app.m.Unlock()
+
+	return slices.Collect(maps.Keys(app.globals.properties))
+}
+
+var errNotConnected = errors.New("not connected to mpv")
+
+// MPV returns current connection to mpv.
+func (app *App) MPV() (*mpv.Conn, error) {
 	app.m.Lock()
 	defer app.m.Unlock()
 
 	if app.mpv == nil {
-		return mpv.MpvResponse{}, fmt.Errorf("not connected to mpv")
+		return nil, errNotConnected
 	}
-	return app.mpv.SendCommand(args, async)
+	return app.mpv, nil
+}
+
+func (app *App) SendCommand(args []any, async bool) (mpv.MpvResponse, error) {
+	return app.SendCommandContext(context.Background(), args, async)
+}
+
+// SendCommandContext sends command to mpv. The app lock is not held while waiting for the response,
+// so a hung mpv doesn't block other requests.
+func (app *App) SendCommandContext(ctx context.Context, args []any, async bool) (mpv.MpvResponse, error) {
+	conn, err := app.MPV()
+	if err != nil {
+		return mpv.MpvResponse{}, err
+	}
+	return conn.SendCommandContext(ctx, args, async)
 }
 
 func (app *App) IsConnectedToMPV() bool {
@@ -315,49 +537,167 @@ func (app *App) IsConnectedToMPV() bool {
 	return app.mpv != nil
 }
 
-func (app *App) StartupEvents() []any {
+func (app *App) StartupEvents() []propertyEvent {
 	app.m.Lock()
 	defer app.m.Unlock()
 
-	events := []any{
+	return app.startupEvents()
+}
+
+func (app *App) startupEvents() []propertyEvent {
+	events := []propertyEvent{
 		app.makeGlobalPropertyEvent("connected", app.mpv != nil),
+		app.makeGlobalPropertyEvent("connection-state", app.connState),
 	}
 
 	for propertyName, value := range app.globals.properties {
 		events = append(events, app.makeGlobalPropertyEvent(propertyName, value))
 	}
 
+	events = append(events,
+		app.makeGlobalPropertyEvent("intro", app.chapters.intro),
+		app.makeGlobalPropertyEvent("auto-skip-intro", app.chapters.autoSkipIntro),
+		app.makeGlobalPropertyEvent("sleep-timer", app.sleepTimerStatus(time.Now())),
+	)
+
+	if app.config.History.Resume == config.ResumePrompt {
+		events = append(events, app.makeGlobalPropertyEvent("resume", app.watch.resume))
+	}
+
 	events = append(events, app.makeGlobalPropertyEvent("ready", true))
 
 	return events
 }
 
-func (app *App) NewEventListener() *AppEventListener {
+// SendStartupEvents sends current state to listener. Events received by listener after them are newer than the state.
+func (app *App) SendStartupEvents(listener *AppEventListener) {
 	app.m.Lock()
 	defer app.m.Unlock()
 
-	app.eventListenerCounter++
-	listener := &AppEventListener{
-		Events: make(chan []byte),
-		ID:     app.eventListenerCounter,
+	app.sendStartupEvents(listener)
+}
+
+// SendGlobalPropertyEvents sends current values of global properties from names to listener,
+// other properties are ignored.
+func (app *App) SendGlobalPropertyEvents(listener *AppEventListener, names []string) {
+	app.m.Lock()
+	defer app.m.Unlock()
+
+	now := time.Now()
+	for _, event := range app.startupEvents() {
+		if slices.Contains(names, event.PropertyName) && !app.pushEvent(listener, event, now) {
+			return
+		}
+	}
+}
+
+// ResumeEvents sends events after event lastID to listener. If some of them are not kept anymore,
+// current state is sent instead. It returns true if events were resumed.
+func (app *App) ResumeEvents(listener *AppEventListener, lastID uint64) bool {
+	app.m.Lock()
+	defer app.m.Unlock()
+
+	events, ok := app.eventHistory.since(lastID)
+	if !ok {
+		app.sendStartupEvents(listener)
+		return false
 	}
+
+	now := time.Now()
+	for _, event := range events {
+		if !listener.wants(event) {
+			continue
+		}
+		if err := listener.push(event, now); err != nil {
+			slog.Warn("dropping event listener", "id", listener.ID, "err", err)
+			app.removeEventListener(listener, err)
+			break
+		}
+	}
+	return true
+}
+
+func (app *App) sendStartupEvents(listener *AppEventListener) {
+	now := time.Now()
+	events := app.startupEvents()
+	// Values of subscribed properties are part of the state, so they are sent before "ready".
+	events = slices.Insert(events, len(events)-1, app.subscribedPropertyEvents(listener)...)
+	for _, event := range events {
+		if !app.pushEvent(listener, event, now) {
+			return
+		}
+	}
+}
+
+// pushEvent queues event of current state for listener, it must be called with app.m locked.
+// It returns false if listener was dropped.
+func (app *App) pushEvent(listener *AppEventListener, event propertyEvent, now time.Time) bool {
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("failed to marshal event", "err", err, "event", event)
+		return true
+	}
+
+	appEvent := event.appEvent(eventJSON)
+	appEvent.ID = app.eventHistory.lastID
+	if err := listener.push(appEvent, now); err != nil {
+		slog.Warn("dropping event listener", "id", listener.ID, "err", err)
+		app.removeEventListener(listener, err)
+		return false
+	}
+	return true
+}
+
+// EventEpoch returns string which is different for every run of the app. Event IDs are only comparable within one epoch.
+func (app *App) EventEpoch() string {
+	return app.eventEpoch
+}
+
+// NewEventListener creates listener for client authenticated with token tokenID.
+func (app *App) NewEventListener(tokenID string) *AppEventListener {
+	app.m.Lock()
+	defer app.m.Unlock()
+
+	app.eventListenerCounter++
+	listener := newAppEventListener(app.eventListenerCounter)
+	listener.TokenID = tokenID
 	app.eventListeners = append(app.eventListeners, listener)
 
 	slog.Debug("created event listener", "id", listener.ID)
 	return listener
 }
 
-func (app *App) CloseEventListener(closeListener *AppEventListener) {
+// CloseEventListener stops sending events to listener and unsubscribes it from properties.
+// It is safe to call for dropped listener.
+func (app *App) CloseEventListener(listener *AppEventListener) {
+	app.m.Lock()
+	app.removeEventListener(listener, nil)
+	properties := slices.Collect(maps.Keys(listener.properties))
+	app.m.Unlock()
+
+	if len(properties) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), app.server.commandTimeout)
+		defer cancel()
+		app.UnsubscribeProperties(ctx, listener, properties)
+	}
+	slog.Debug("closed event listener", "id", listener.ID)
+}
+
+// CloseTokenEventListeners drops listeners opened with token tokenID, so revoked device stops receiving events.
+func (app *App) CloseTokenEventListeners(tokenID string) {
 	app.m.Lock()
 	defer app.m.Unlock()
 
-	index := slices.Index(app.eventListeners, closeListener)
-	if index == -1 {
-		panic(fmt.Sprintf("unknown listener %v", closeListener))
+	for _, listener := range slices.Clone(app.eventListeners) {
+		if listener.TokenID == tokenID {
+			app.removeEventListener(listener, errTokenRevoked)
+		}
 	}
+}
 
-	app.eventListeners = slices.Delete(app.eventListeners, index, index+1)
-	close(closeListener.Events)
-
-	slog.Debug("closed event listener", "id", closeListener.ID)
+func (app *App) removeEventListener(listener *AppEventListener, err error) {
+	if index := slices.Index(app.eventListeners, listener); index != -1 {
+		app.eventListeners = slices.Delete(app.eventListeners, index, index+1)
+	}
+	listener.close(err)
 }
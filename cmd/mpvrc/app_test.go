@@ -0,0 +1,738 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/miere43/mpvrc/internal/config"
+	"github.com/miere43/mpvrc/internal/mpv/mpvtest"
+	"github.com/stretchr/testify/suite"
+)
+
+type appSuite struct {
+	suite.Suite
+	fake  *mpvtest.Server
+	app   *App
+	token string
+}
+
+func TestApp(t *testing.T) {
+	suite.Run(t, new(appSuite))
+}
+
+func (s *appSuite) SetupTest() {
+	s.fake = mpvtest.NewServer()
+	s.app = newApp(config.Default(), s.fake.Dialer())
+	go s.app.handleEvents()
+}
+
+func (s *appSuite) TearDownTest() {
+	s.fake.Close()
+}
+
+// listen collects events sent to a new event listener.
+func (s *appSuite) listen() chan []byte {
+	return s.collect(s.app.NewEventListener(""))
+}
+
+func (s *appSuite) collect(listener *AppEventListener) chan []byte {
+	events := make(chan []byte, 100)
+	go func() {
+		for {
+			select {
+			case <-listener.Ready():
+				for _, event := range listener.Take() {
+					events <- event.Data
+				}
+			case <-listener.Done():
+				return
+			}
+		}
+	}()
+	s.T().Cleanup(func() { s.app.CloseEventListener(listener) })
+	return events
+}
+
+func (s *appSuite) waitForEvent(events chan []byte, propertyName string, value any) {
+	s.waitForEventFunc(events, propertyName, func(v any) bool { return v == value })
+}
+
+func (s *appSuite) waitForConnectionState(events chan []byte, state string) map[string]any {
+	var result map[string]any
+	s.waitForEventFunc(events, "connection-state", func(v any) bool {
+		result, _ = v.(map[string]any)
+		return result["state"] == state
+	})
+	return result
+}
+
+func (s *appSuite) waitForEventFunc(events chan []byte, propertyName string, match func(value any) bool) {
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case eventJSON := <-events:
+			var event propertyEvent
+			s.Require().NoError(json.Unmarshal(eventJSON, &event))
+			if event.PropertyName == propertyName && match(event.Value) {
+				return
+			}
+		case <-timeout:
+			s.FailNow("timed out waiting for event", "%s", propertyName)
+		}
+	}
+}
+
+// startHTTP starts HTTP server and pairs with it. Requests sent with do are authenticated.
+func (s *appSuite) startHTTP() *httptest.Server {
+	srv := httptest.NewServer(s.app.server.srv.Handler)
+	s.T().Cleanup(srv.Close)
+
+	pin, err := s.app.auth.PIN()
+	s.Require().NoError(err)
+	s.token, _, err = s.app.auth.Pair(pin, "test")
+	s.Require().NoError(err)
+	return srv
+}
+
+func (s *appSuite) do(request *http.Request) *http.Response {
+	request.Header.Set("Authorization", "Bearer "+s.token)
+	response, err := http.DefaultClient.Do(request)
+	s.Require().NoError(err)
+	return response
+}
+
+func (s *appSuite) postCommand(srv *httptest.Server, command string) *http.Response {
+	body := url.Values{"command": {command}}.Encode()
+	request, err := http.NewRequest(http.MethodPost, srv.URL+"/command", strings.NewReader(body))
+	s.Require().NoError(err)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return s.do(request)
+}
+
+func (s *appSuite) TestConnectObservesGlobals() {
+	r := s.Require()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	s.True(s.app.IsConnectedToMPV())
+
+	observed := map[string]bool{}
+	for _, command := range s.fake.Commands() {
+		if command[0] == "observe_property" {
+			observed[command[2].(string)] = true
+		}
+	}
+	for propertyName := range NewGlobals().properties {
+		s.True(observed[propertyName], "property %q is not observed", propertyName)
+	}
+}
+
+func (s *appSuite) TestPropertyChangeIsBroadcast() {
+	events := s.listen()
+	s.Require().NoError(s.app.ConnectToMPV(time.Second))
+
+	s.fake.SetProperty("volume", 50.0)
+
+	// Value is forwarded as raw JSON and decoded by the test as float64.
+	s.waitForEvent(events, "volume", 50.0)
+}
+
+// commandCount returns how many times mpv received command with the last argument arg. Any argument matches nil arg.
+// never asserts that condition stays false for a while. Condition may still run after the test ends and
+// the suite is set up for the next test, so it gets fake mpv of the current test instead of using the suite.
+func (s *appSuite) never(condition func(fake *mpvtest.Server) bool) {
+	fake := s.fake
+	s.Never(func() bool { return condition(fake) }, 50*time.Millisecond, time.Millisecond)
+}
+
+func (s *appSuite) commandCount(name string, arg any) int {
+	count := 0
+	for _, command := range s.fake.Commands() {
+		if command[0] == name && (arg == nil || command[len(command)-1] == arg) {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *appSuite) TestPropertySubscriptions() {
+	r := s.Require()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	ctx := context.Background()
+
+	first := s.app.NewEventListener("")
+	firstEvents := s.collect(first)
+	second := s.app.NewEventListener("")
+	secondEvents := s.collect(second)
+	other := s.listen()
+
+	r.NoError(s.app.SubscribeProperties(ctx, first, []string{"percent-pos"}))
+	r.NoError(s.app.SubscribeProperties(ctx, second, []string{"percent-pos"}))
+	s.Equal(1, s.commandCount("observe_property", "percent-pos"), "property must be observed once")
+
+	s.fake.SetProperty("percent-pos", 0.5)
+	s.waitForEvent(firstEvents, "percent-pos", 0.5)
+	s.waitForEvent(secondEvents, "percent-pos", 0.5)
+
+	s.app.UnsubscribeProperties(ctx, first, []string{"percent-pos"})
+	s.Equal(0, s.commandCount("unobserve_property", nil))
+
+	s.fake.SetProperty("percent-pos", 1.0)
+	s.waitForEvent(secondEvents, "percent-pos", 1.0)
+
+	// Closing the last subscriber unobserves the property.
+	s.app.CloseEventListener(second)
+	s.Equal(1, s.commandCount("unobserve_property", nil))
+
+	s.fake.SetProperty("volume", 10.0)
+	s.waitForEvent(firstEvents, "volume", 10.0)
+	s.waitForEvent(other, "volume", 10.0)
+	for _, events := range []chan []byte{firstEvents, other} {
+	drain:
+		for {
+			select {
+			case eventJSON := <-events:
+				var event propertyEvent
+				r.NoError(json.Unmarshal(eventJSON, &event))
+				s.NotEqual("percent-pos", event.PropertyName, "event must only be sent to subscribers")
+			default:
+				break drain
+			}
+		}
+	}
+}
+
+func (s *appSuite) TestSubscriptionsAreObservedAfterReconnect() {
+	r := s.Require()
+	listener := s.app.NewEventListener("")
+	events := s.collect(listener)
+	r.NoError(s.app.SubscribeProperties(context.Background(), listener, []string{"time-pos"}))
+	s.Equal(0, s.commandCount("observe_property", "time-pos"))
+
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	s.Equal(1, s.commandCount("observe_property", "time-pos"))
+
+	s.fake.SetProperty("time-pos", 0.25)
+	s.waitForEvent(events, "time-pos", 0.25)
+}
+
+func (s *appSuite) TestPauseOverHTTP() {
+	events := s.listen()
+	s.Require().NoError(s.app.ConnectToMPV(time.Second))
+
+	srv := s.startHTTP()
+
+	response := s.postCommand(srv, `["set_property","pause",true]`)
+	defer response.Body.Close()
+	s.Equal(http.StatusOK, response.StatusCode)
+
+	s.Equal(true, s.fake.Property("pause"))
+	s.waitForEvent(events, "pause", true)
+}
+
+func (s *appSuite) TestCommandErrorOverHTTP() {
+	s.Require().NoError(s.app.ConnectToMPV(time.Second))
+	s.fake.FailCommand("set_property", "property unavailable")
+
+	srv := s.startHTTP()
+
+	response := s.postCommand(srv, `["set_property","pause",true]`)
+	defer response.Body.Close()
+	s.Equal(http.StatusBadRequest, response.StatusCode)
+}
+
+func (s *appSuite) TestForbiddenCommandOverHTTP() {
+	s.Require().NoError(s.app.ConnectToMPV(time.Second))
+
+	srv := s.startHTTP()
+
+	response := s.postCommand(srv, `["run","calc.exe"]`)
+	defer response.Body.Close()
+	s.Equal(http.StatusForbidden, response.StatusCode)
+
+	for _, command := range s.fake.Commands() {
+		s.NotEqual("run", command[0])
+	}
+}
+
+func (s *appSuite) TestUnauthenticatedRequestsAreRejected() {
+	srv := s.startHTTP()
+
+	for _, path := range []string{"/events", "/file-system", "/auth/tokens"} {
+		response, err := http.Get(srv.URL + path)
+		s.Require().NoError(err)
+		response.Body.Close()
+		s.Equal(http.StatusUnauthorized, response.StatusCode, path)
+	}
+
+	response, err := http.PostForm(srv.URL+"/command", url.Values{"command": {`["set_property","pause",true]`}})
+	s.Require().NoError(err)
+	response.Body.Close()
+	s.Equal(http.StatusUnauthorized, response.StatusCode)
+	s.Empty(s.fake.Commands())
+}
+
+func (s *appSuite) TestPairing() {
+	r := s.Require()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+
+	response, err := http.Post(srv.URL+"/pair/pin", "", nil)
+	r.NoError(err)
+	response.Body.Close()
+	r.Equal(http.StatusNoContent, response.StatusCode)
+
+	// PIN is shown in mpv.
+	commands := s.fake.Commands()
+	r.NotEmpty(commands)
+	showText := commands[len(commands)-1]
+	r.Equal("show-text", showText[0])
+	pin, ok := strings.CutPrefix(showText[1].(string), "mpvrc pairing PIN: ")
+	r.True(ok)
+
+	response, err = http.PostForm(srv.URL+"/pair", url.Values{"pin": {"wrong"}})
+	r.NoError(err)
+	response.Body.Close()
+	s.Equal(http.StatusUnauthorized, response.StatusCode)
+
+	response, err = http.PostForm(srv.URL+"/pair", url.Values{"pin": {pin}, "name": {"phone"}})
+	r.NoError(err)
+	response.Body.Close()
+	r.Equal(http.StatusOK, response.StatusCode)
+
+	cookies := response.Cookies()
+	r.Len(cookies, 1)
+	s.True(cookies[0].HttpOnly)
+
+	request, err := http.NewRequest(http.MethodGet, srv.URL+"/auth/tokens", nil)
+	r.NoError(err)
+	request.AddCookie(cookies[0])
+	response, err = http.DefaultClient.Do(request)
+	r.NoError(err)
+	defer response.Body.Close()
+	r.Equal(http.StatusOK, response.StatusCode)
+
+	var tokens []tokenInfoResponse
+	r.NoError(json.NewDecoder(response.Body).Decode(&tokens))
+	r.Len(tokens, 2)
+	s.Equal("phone", tokens[1].Name)
+	s.True(tokens[1].Current)
+
+	// Streams opened with the token are closed when it's revoked.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	request, err = http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events", nil)
+	r.NoError(err)
+	request.AddCookie(cookies[0])
+	stream, err := http.DefaultClient.Do(request)
+	r.NoError(err)
+	defer stream.Body.Close()
+	r.Equal(http.StatusOK, stream.StatusCode)
+	conn, _, err := websocket.Dial(ctx, srv.URL+"/ws", &websocket.DialOptions{
+		HTTPHeader: http.Header{"Cookie": {cookies[0].String()}},
+	})
+	r.NoError(err)
+	defer conn.CloseNow()
+
+	// Revoked token can't be used anymore.
+	request, err = http.NewRequest(http.MethodDelete, srv.URL+"/auth/tokens/"+tokens[1].ID, nil)
+	r.NoError(err)
+	response = s.do(request)
+	response.Body.Close()
+	r.Equal(http.StatusNoContent, response.StatusCode)
+
+	_, err = io.Copy(io.Discard, stream.Body)
+	s.NoError(err)
+	_, _, err = conn.Read(ctx)
+	s.Equal(websocket.StatusPolicyViolation, websocket.CloseStatus(err))
+
+	request, err = http.NewRequest(http.MethodGet, srv.URL+"/auth", nil)
+	r.NoError(err)
+	request.AddCookie(cookies[0])
+	response, err = http.DefaultClient.Do(request)
+	r.NoError(err)
+	response.Body.Close()
+	s.Equal(http.StatusUnauthorized, response.StatusCode)
+}
+
+func (s *appSuite) TestTLS() {
+	r := s.Require()
+	s.app.config.TLS = config.TLS{
+		Enabled:        true,
+		CertDir:        s.T().TempDir(),
+		RedirectListen: "127.0.0.1:8081",
+	}
+	r.NoError(s.app.server.setupTLS())
+
+	srv := httptest.NewUnstartedServer(s.app.server.srv.Handler)
+	srv.TLS = s.app.server.srv.TLSConfig
+	srv.StartTLS()
+	defer srv.Close()
+
+	// Generated CA is served, so it can be trusted by the client.
+	roots := x509.NewCertPool()
+	r.True(roots.AppendCertsFromPEM(s.app.server.caCert))
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+
+	response, err := client.Get(strings.Replace(srv.URL, "127.0.0.1", "localhost", 1) + "/ca.crt")
+	r.NoError(err)
+	response.Body.Close()
+	s.Equal(http.StatusOK, response.StatusCode)
+
+	recorder := httptest.NewRecorder()
+	s.app.server.redirect.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://media-pc:8081/file-system?path=x", nil))
+	s.Equal(http.StatusPermanentRedirect, recorder.Code)
+	s.Equal("https://media-pc:8080/file-system?path=x", recorder.Header().Get("Location"))
+}
+
+func (s *appSuite) TestWebSocket() {
+	r := s.Require()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, srv.URL+"/ws", &websocket.DialOptions{
+		HTTPHeader: http.Header{"Authorization": {"Bearer " + s.token}},
+	})
+	r.NoError(err)
+	defer conn.CloseNow()
+
+	// read returns next reply or event.
+	read := func() map[string]any {
+		var message map[string]any
+		r.NoError(wsjson.Read(ctx, conn, &message))
+		return message
+	}
+
+	r.NoError(wsjson.Write(ctx, conn, wsRequest{ID: json.RawMessage(`"sub"`), Type: "subscribe", Properties: []string{"pause"}}))
+
+	// Reply and current value can arrive in any order.
+	var gotReply, gotValue bool
+	for !gotReply || !gotValue {
+		message := read()
+		if message["id"] == "sub" {
+			s.Equal(float64(http.StatusOK), message["status"])
+			gotReply = true
+		} else {
+			s.Equal("pause", message["propertyName"])
+			s.Equal(false, message["value"])
+			gotValue = true
+		}
+	}
+
+	r.NoError(wsjson.Write(ctx, conn, wsRequest{ID: json.RawMessage(`1`), Type: "command", Command: []any{"set_property", "pause", true}}))
+	r.NoError(wsjson.Write(ctx, conn, wsRequest{ID: json.RawMessage(`2`), Type: "command", Command: []any{"run", "calc.exe"}}))
+
+	gotReply, gotValue = false, false
+	for !gotReply || !gotValue {
+		message := read()
+		switch message["id"] {
+		case float64(1):
+			s.Equal(float64(http.StatusOK), message["status"])
+		case float64(2):
+			s.Equal(float64(http.StatusForbidden), message["status"])
+			s.Contains(message["error"], "not allowed")
+			gotReply = true
+		default:
+			s.Equal("pause", message["propertyName"])
+			s.Equal(true, message["value"])
+			gotValue = true
+		}
+	}
+
+	// Only subscribed properties are sent.
+	s.fake.SetProperty("volume", 50)
+	s.fake.SetProperty("pause", false)
+	message := read()
+	s.Equal("pause", message["propertyName"])
+	s.Equal(false, message["value"])
+
+	// Subscribing again only sends values of new properties.
+	r.NoError(wsjson.Write(ctx, conn, wsRequest{ID: json.RawMessage(`"sub2"`), Type: "subscribe", Properties: []string{"pause", "volume"}}))
+	gotReply, gotValue = false, false
+	for !gotReply || !gotValue {
+		message := read()
+		if message["id"] == "sub2" {
+			gotReply = true
+		} else {
+			s.Equal("volume", message["propertyName"])
+			gotValue = true
+		}
+	}
+	s.fake.SetProperty("pause", true)
+	message = read()
+	s.Equal("pause", message["propertyName"])
+	s.Equal(true, message["value"])
+}
+
+func (s *appSuite) TestSlowListenerDoesNotBlockCommands() {
+	r := s.Require()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+
+	// Listener which never reads events.
+	slow := s.app.NewEventListener("")
+	defer s.app.CloseEventListener(slow)
+
+	for i := range 100 {
+		s.fake.SetProperty("playback-time", float64(i))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := s.app.SendCommandContext(ctx, []any{"get_property", "pause"}, false)
+	r.NoError(err)
+
+	// Updates were coalesced into the latest value.
+	s.Eventually(func() bool {
+		events := slow.Take()
+		return len(events) > 0 && string(events[len(events)-1].Data) == `{"event":"set-global-property","propertyName":"playback-time","value":99}`
+	}, time.Second, 10*time.Millisecond)
+}
+
+func (s *appSuite) TestHungMPVTimesOutOverHTTP() {
+	s.Require().NoError(s.app.ConnectToMPV(time.Second))
+	s.fake.SetLatency(time.Second)
+	s.app.server.commandTimeout = 20 * time.Millisecond
+
+	srv := s.startHTTP()
+
+	response := s.postCommand(srv, `["get_property","pause"]`)
+	defer response.Body.Close()
+	s.Equal(http.StatusGatewayTimeout, response.StatusCode)
+
+	// The app must stay responsive while mpv is hung.
+	s.True(s.app.IsConnectedToMPV())
+}
+
+func (s *appSuite) TestEventStreamStartsWithSnapshot() {
+	s.Require().NoError(s.app.ConnectToMPV(time.Second))
+
+	srv := s.startHTTP()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events", nil)
+	s.Require().NoError(err)
+	response := s.do(request)
+	defer response.Body.Close()
+
+	s.Equal("text/event-stream", response.Header.Get("Content-Type"))
+
+	scanner := bufio.NewScanner(response.Body)
+	var names []string
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+
+		var event propertyEvent
+		s.Require().NoError(json.Unmarshal([]byte(data), &event))
+		names = append(names, event.PropertyName)
+		if event.PropertyName == "ready" {
+			break
+		}
+	}
+
+	s.Equal("connected", names[0])
+	s.Equal("ready", names[len(names)-1])
+	// connected, connection-state, all globals, intro, auto-skip-intro, sleep-timer and ready.
+	s.Len(names, len(NewGlobals().properties)+6)
+}
+
+// openEventStream connects to /events, subscribes to properties and returns scanner over its lines.
+func (s *appSuite) openEventStream(ctx context.Context, srv *httptest.Server, lastEventID string, properties ...string) *bufio.Scanner {
+	query := url.Values{}
+	if len(properties) > 0 {
+		query.Set("properties", strings.Join(properties, ","))
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events?"+query.Encode(), nil)
+	s.Require().NoError(err)
+	if lastEventID != "" {
+		request.Header.Set("Last-Event-ID", lastEventID)
+	}
+	response := s.do(request)
+	s.T().Cleanup(func() { response.Body.Close() })
+	return bufio.NewScanner(response.Body)
+}
+
+// nextStreamEvent returns ID and event of the next event in stream.
+func (s *appSuite) nextStreamEvent(scanner *bufio.Scanner) (string, propertyEvent) {
+	var id string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "id: "); ok {
+			id = value
+		} else if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var event propertyEvent
+			s.Require().NoError(json.Unmarshal([]byte(data), &event))
+			return id, event
+		}
+	}
+	s.FailNow("event stream ended", "%v", scanner.Err())
+	return "", propertyEvent{}
+}
+
+func (s *appSuite) TestEventStreamResumes() {
+	r := s.Require()
+	events := s.listen()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	scanner := s.openEventStream(ctx, srv, "")
+	var lastID string
+	for {
+		id, event := s.nextStreamEvent(scanner)
+		lastID = id
+		if event.PropertyName == "ready" {
+			break
+		}
+	}
+	cancel()
+
+	// Change is missed while disconnected.
+	s.fake.SetProperty("volume", 50)
+	s.waitForEvent(events, "volume", float64(50))
+
+	scanner = s.openEventStream(context.Background(), srv, lastID)
+	id, event := s.nextStreamEvent(scanner)
+	s.Equal("volume", event.PropertyName)
+	s.Equal(float64(50), event.Value)
+	s.NotEqual(lastID, id)
+
+	// ID of previous run of the app results in a snapshot.
+	scanner = s.openEventStream(context.Background(), srv, "previous-10")
+	_, event = s.nextStreamEvent(scanner)
+	s.Equal("connected", event.PropertyName)
+}
+
+func (s *appSuite) TestEventStreamSubscribesToProperties() {
+	r := s.Require()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+
+	request, err := http.NewRequest(http.MethodGet, srv.URL+"/events?properties=input-ipc-server", nil)
+	r.NoError(err)
+	response := s.do(request)
+	response.Body.Close()
+	s.Equal(http.StatusForbidden, response.StatusCode)
+
+	scanner := s.openEventStream(context.Background(), srv, "", "percent-pos")
+	for {
+		_, event := s.nextStreamEvent(scanner)
+		if event.PropertyName == "ready" {
+			break
+		}
+	}
+
+	s.fake.SetProperty("percent-pos", 0.5)
+	for {
+		_, event := s.nextStreamEvent(scanner)
+		if event.PropertyName == "percent-pos" && event.Value == 0.5 {
+			s.Equal(propertyChangeEvent, event.Event)
+			break
+		}
+	}
+}
+
+func (s *appSuite) TestEventStreamHeartbeat() {
+	s.app.server.sseHeartbeat = 10 * time.Millisecond
+	srv := s.startHTTP()
+
+	scanner := s.openEventStream(context.Background(), srv, "")
+	r := s.Require()
+	r.True(scanner.Scan())
+	r.Equal("retry: 3000", scanner.Text())
+
+	for scanner.Scan() {
+		if scanner.Text() == ": heartbeat" {
+			return
+		}
+	}
+	s.Fail("no heartbeat")
+}
+
+func (s *appSuite) TestDisconnectAndReconnect() {
+	r := s.Require()
+	events := s.listen()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+
+	s.fake.Disconnect()
+	s.waitForEvent(events, "connected", false)
+	s.Eventually(func() bool { return !s.app.IsConnectedToMPV() }, time.Second, 10*time.Millisecond)
+
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	s.True(s.app.IsConnectedToMPV())
+
+	s.fake.SetProperty("speed", 2.0)
+	s.waitForEvent(events, "speed", 2.0)
+}
+
+func (s *appSuite) TestConnectFailsWhenMPVIsNotRunning() {
+	s.fake.Close()
+	s.Error(s.app.ConnectToMPV(0))
+	s.False(s.app.IsConnectedToMPV())
+}
+
+func (s *appSuite) TestConnectFailsWhenMPVDoesNotRespond() {
+	s.fake.SetLatency(200 * time.Millisecond)
+	s.Error(s.app.ConnectToMPV(20 * time.Millisecond))
+	s.Eventually(func() bool { return !s.app.IsConnectedToMPV() }, time.Second, 10*time.Millisecond)
+
+	s.fake.SetLatency(0)
+	s.Require().NoError(s.app.ConnectToMPV(time.Second))
+	s.True(s.app.IsConnectedToMPV())
+}
+
+func (s *appSuite) startSupervisor() {
+	s.app.reconnectMinDelay = 10 * time.Millisecond
+	s.app.reconnectMaxDelay = 40 * time.Millisecond
+	go s.app.superviseMPV()
+	s.T().Cleanup(s.app.RequestQuit)
+}
+
+func (s *appSuite) TestSupervisorReconnects() {
+	events := s.listen()
+	s.startSupervisor()
+	s.waitForConnectionState(events, connectionStateConnected)
+
+	s.fake.Disconnect()
+	s.waitForEvent(events, "connected", false)
+
+	// Snapshot is replayed after reconnecting. Events before it may be coalesced.
+	s.waitForEvent(events, "ready", true)
+	s.True(s.app.IsConnectedToMPV())
+	s.Equal(connectionStateConnected, s.app.ConnectionState().State)
+
+	// Properties are observed again on the new connection.
+	s.fake.SetProperty("pause", true)
+	s.waitForEvent(events, "pause", true)
+}
+
+func (s *appSuite) TestSupervisorReportsFailures() {
+	events := s.listen()
+	s.fake.Close()
+	s.startSupervisor()
+
+	state := s.waitForConnectionState(events, connectionStateFailed)
+	s.Contains(state["lastError"], "fake mpv is not running")
+
+	s.fake.Reopen()
+	s.waitForConnectionState(events, connectionStateConnected)
+	s.Equal(connectionStateConnected, s.app.ConnectionState().State)
+	s.Empty(s.app.ConnectionState().LastError)
+}
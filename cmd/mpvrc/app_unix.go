@@ -0,0 +1,17 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+var uniqueInstancePipeName = filepath.Join(os.TempDir(), "mpvrc-unique.sock")
+
+func reportStartupError(err error) {
+	slog.Error("failed to start mpvrc", "err", err)
+	fmt.Fprintln(os.Stderr, "mpvrc:", err)
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/miere43/mpvrc/internal/winapi"
+)
+
+const uniqueInstancePipeName = "\\\\.\\pipe\\mpvrc-unique"
+
+// reportStartupError shows message box because release build has no console window.
+func reportStartupError(err error) {
+	slog.Error("failed to start mpvrc", "err", err)
+	if err := winapi.MessageBox("mpvrc", err.Error(), winapi.MB_OK|winapi.MB_ICONERROR); err != nil {
+		slog.Error("failed to show message box", "err", err)
+	}
+}
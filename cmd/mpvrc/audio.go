@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/jsonfile"
+	"github.com/miere43/mpvrc/internal/mpv"
+)
+
+// audioState tracks the active audio device, it is guarded by App.m.
+type audioState struct {
+	device string
+	// volumes are volumes last used with audio devices, by device name.
+	volumes map[string]float64
+	// volumesFile saves volumes, it is nil if volumes are not saved.
+	volumesFile *jsonfile.Writer
+}
+
+// audioVolumesSaveDelay is how long changes of volume are collected before they are saved, so every step
+// of volume slider or fade-out is not written to disk.
+const audioVolumesSaveDelay = 5 * time.Second
+
+// loadAudioVolumes reads volumes of audio devices from path.
+func (app *App) loadAudioVolumes(path string) error {
+	app.audio.volumesFile = jsonfile.NewWriter(path, audioVolumesSaveDelay)
+	if _, err := jsonfile.Read(path, &app.audio.volumes); err != nil {
+		return fmt.Errorf("read audio device volumes: %w", err)
+	}
+	if app.audio.volumes == nil {
+		app.audio.volumes = make(map[string]float64)
+	}
+	return nil
+}
+
+// trackAudioDevice remembers volume of the active audio device and restores volume of the device which
+// becomes active. It must be called with app.m locked.
+func (app *App) trackAudioDevice(name string, value json.RawMessage) {
+	if !app.config.Audio.RememberVolume {
+		return
+	}
+
+	switch name {
+	case "audio-device":
+		// mpv omits data of unavailable properties, e.g. when audio output is reset.
+		var device string
+		if len(value) > 0 {
+			if err := json.Unmarshal(value, &device); err != nil {
+				slog.Error("failed to unmarshal audio device", "value", value, "err", err)
+				return
+			}
+		}
+		previous := app.audio.device
+		app.audio.device = device
+		// Volume is only restored on switch, so mpvrc doesn't override volume when it connects to mpv.
+		if previous == "" || device == "" || device == previous {
+			return
+		}
+
+		volume, ok := app.audio.volumes[device]
+		if !ok {
+			// Device keeps the current volume, it is remembered so switching back restores it.
+			var current float64
+			if err := json.Unmarshal(app.globals.properties["volume"], &current); err == nil {
+				app.rememberVolume(device, current)
+			}
+			return
+		}
+		if app.mpv == nil {
+			return
+		}
+
+		conn := app.mpv
+		go func() {
+			ctx, cancel := context.WithTimeout(conn.Context(), app.server.commandTimeout)
+			defer cancel()
+			if err := conn.SetVolume(ctx, volume); err != nil {
+				slog.Error("failed to restore volume of audio device", "device", device, "volume", volume, "err", err)
+			}
+		}()
+
+	case "volume":
+		var volume float64
+		// Volume lowered by fade-out of sleep timer is not remembered, so the device doesn't stay silent.
+		if app.audio.device == "" || app.sleep.fading != nil || json.Unmarshal(value, &volume) != nil {
+			return
+		}
+		app.rememberVolume(app.audio.device, volume)
+	}
+}
+
+// rememberVolume must be called with app.m locked.
+func (app *App) rememberVolume(device string, volume float64) {
+	if current, ok := app.audio.volumes[device]; ok && current == volume {
+		return
+	}
+	app.audio.volumes[device] = volume
+
+	if app.audio.volumesFile != nil {
+		app.audio.volumesFile.Schedule(maps.Clone(app.audio.volumes))
+	}
+}
+
+// flushAudioVolumes saves changes of volumes which were not saved yet.
+func (app *App) flushAudioVolumes() {
+	if app.audio.volumesFile == nil {
+		return
+	}
+	if err := app.audio.volumesFile.Flush(); err != nil {
+		slog.Error("failed to save audio device volumes", "err", err)
+	}
+}
+
+// HasAudioDevice reports whether mpv has listed audio device with name.
+func (app *App) HasAudioDevice(name string) bool {
+	app.m.Lock()
+	defer app.m.Unlock()
+
+	var devices []mpv.AudioDevice
+	if err := json.Unmarshal(app.globals.properties["audio-device-list"], &devices); err != nil {
+		slog.Error("failed to unmarshal audio device list", "err", err)
+		return false
+	}
+	return slices.ContainsFunc(devices, func(device mpv.AudioDevice) bool { return device.Name == name })
+}
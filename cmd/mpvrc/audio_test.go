@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func (s *appSuite) TestAudioDevices() {
+	r := s.Require()
+	s.app.config.Audio.RememberVolume = true
+	volumesPath := filepath.Join(s.T().TempDir(), "audio-volumes.json")
+	r.NoError(s.app.loadAudioVolumes(volumesPath))
+	s.fake.SetProperty("audio-device-list", []any{
+		map[string]any{"name": "auto", "description": "Autoselect device"},
+		map[string]any{"name": "wasapi/hdmi", "description": "TV (HDMI)"},
+		map[string]any{"name": "wasapi/headphones", "description": "Headphones"},
+	})
+
+	events := s.listen()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+	s.waitForEvent(events, "audio-device", "auto")
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/audio/device", `{"name": "wasapi/hdmi"}`).StatusCode)
+	s.waitForEvent(events, "audio-device", "wasapi/hdmi")
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/volume", `{"volume": 40}`).StatusCode)
+	s.waitForEvent(events, "volume", 40.0)
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/audio/device", `{"name": "wasapi/headphones"}`).StatusCode)
+	s.waitForEvent(events, "audio-device", "wasapi/headphones")
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/volume", `{"volume": 70}`).StatusCode)
+	s.waitForEvent(events, "volume", 70.0)
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/audio/device", `{"name": "wasapi/hdmi"}`).StatusCode)
+	s.waitForEvent(events, "volume", 40.0)
+
+	// Volumes are saved in background, flushing saves them now.
+	s.app.flushAudioVolumes()
+	data, err := os.ReadFile(volumesPath)
+	r.NoError(err)
+	var volumes map[string]float64
+	r.NoError(json.Unmarshal(data, &volumes))
+	s.Equal(40.0, volumes["wasapi/hdmi"])
+	s.Equal(70.0, volumes["wasapi/headphones"])
+
+	response := s.api(srv, "POST", "/api/audio/device", `{"name": "alsa/default"}`)
+	r.Equal(http.StatusBadRequest, response.StatusCode)
+	s.Equal("bad_request", s.decodeAPIError(response).Code)
+
+	// Device list is published again when mpv reports that devices changed.
+	s.fake.SetProperty("audio-device-list", []any{
+		map[string]any{"name": "auto", "description": "Autoselect device"},
+		map[string]any{"name": "wasapi/hdmi", "description": "TV (HDMI)"},
+	})
+	s.waitForEventFunc(events, "audio-device-list", func(value any) bool { return len(value.([]any)) == 2 })
+
+	response = s.api(srv, "GET", "/api/state", "")
+	r.Equal(http.StatusOK, response.StatusCode)
+	var state playbackState
+	r.NoError(json.NewDecoder(response.Body).Decode(&state))
+	r.NotNil(state.AudioDevice)
+	s.Equal("wasapi/hdmi", *state.AudioDevice)
+	s.Len(state.AudioDevices, 2)
+	s.Equal("TV (HDMI)", state.AudioDevices[1].Description)
+
+	// Device is unavailable while audio output is reset.
+	s.fake.SetProperty("audio-device", nil)
+	s.waitForEvent(events, "audio-device", nil)
+	s.fake.SetProperty("audio-device", "wasapi/hdmi")
+	s.waitForEvent(events, "audio-device", "wasapi/hdmi")
+}
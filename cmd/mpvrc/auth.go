@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/auth"
+)
+
+const tokenCookieName = "mpvrc-token"
+
+// requireAuth rejects requests without a token of a paired device.
+func (s *httpServer) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := s.authenticate(r); !ok {
+			slog.Warn("rejected unauthenticated request", "path", r.URL.Path, "remoteAddr", r.RemoteAddr)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authenticate looks for token in cookie or in "Authorization: Bearer" header.
+func (s *httpServer) authenticate(r *http.Request) (auth.Token, bool) {
+	var secret string
+	if cookie, err := r.Cookie(tokenCookieName); err == nil {
+		secret = cookie.Value
+	}
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		secret = bearer
+	}
+	return s.app.auth.Verify(secret)
+}
+
+func (s *httpServer) authStatus(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.authenticate(r); !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestPIN shows pairing PIN in mpv and in the log.
+func (s *httpServer) requestPIN(w http.ResponseWriter, r *http.Request) {
+	pin, err := s.app.auth.PIN()
+	if errors.Is(err, auth.ErrPairingLocked) {
+		s.handleErrorStatus(w, http.StatusTooManyRequests, err)
+		return
+	} else if err != nil {
+		s.handleErrorStatus(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	slog.Info("pairing requested", "pin", pin, "remoteAddr", r.RemoteAddr)
+
+	if conn, err := s.app.MPV(); err != nil {
+		slog.Warn("failed to show pairing PIN in mpv", "err", err)
+	} else {
+		ctx, cancel := context.WithTimeout(r.Context(), s.commandTimeout)
+		defer cancel()
+		duration := auth.PINLifetime.Milliseconds()
+		if err := conn.Command(ctx, "show-text", "mpvrc pairing PIN: "+pin, duration); err != nil {
+			slog.Warn("failed to show pairing PIN in mpv", "err", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *httpServer) pair(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	if name == "" {
+		name = r.UserAgent()
+	}
+
+	secret, token, err := s.app.auth.Pair(r.FormValue("pin"), name)
+	if errors.Is(err, auth.ErrInvalidPIN) {
+		slog.Warn("rejected pairing", "remoteAddr", r.RemoteAddr, "err", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		s.handleErrorStatus(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	slog.Info("paired device", "id", token.ID, "name", token.Name, "remoteAddr", r.RemoteAddr)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     tokenCookieName,
+		Value:    secret,
+		Path:     "/",
+		MaxAge:   int((10 * 365 * 24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	s.writeJSON(w, tokenInfo(token, true))
+}
+
+type tokenInfoResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	Current   bool      `json:"current"`
+}
+
+func tokenInfo(token auth.Token, current bool) tokenInfoResponse {
+	return tokenInfoResponse{
+		ID:        token.ID,
+		Name:      token.Name,
+		CreatedAt: token.CreatedAt,
+		Current:   current,
+	}
+}
+
+func (s *httpServer) tokens(w http.ResponseWriter, r *http.Request) {
+	current, _ := s.authenticate(r)
+
+	tokens := make([]tokenInfoResponse, 0)
+	for _, token := range s.app.auth.Tokens() {
+		tokens = append(tokens, tokenInfo(token, token.ID == current.ID))
+	}
+	s.writeJSON(w, tokens)
+}
+
+func (s *httpServer) revokeToken(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	err := s.app.auth.Revoke(id)
+	if errors.Is(err, auth.ErrTokenNotFound) {
+		s.handleErrorStatus(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		s.handleErrorStatus(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	slog.Info("revoked token", "id", id, "remoteAddr", r.RemoteAddr)
+	s.app.CloseTokenEventListeners(id)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/miere43/mpvrc/internal/mpv"
+)
+
+var errNotInIntro = errors.New("current chapter is not an intro")
+
+// chapterState is guarded by App.m.
+type chapterState struct {
+	// intro is set while the current chapter is an intro, it is sent to listeners as "intro" global property.
+	intro         bool
+	autoSkipIntro bool
+	// skipped are intro chapters of the playing file which were skipped automatically,
+	// so seeking back into intro doesn't skip it again.
+	skipped map[int]bool
+}
+
+// currentChapter returns chapters of the playing file and index of the current chapter, or -1 if playback
+// is before the first chapter or there are no chapters. It must be called with app.m locked.
+func (app *App) currentChapter() ([]mpv.Chapter, int) {
+	var chapters []mpv.Chapter
+	if err := json.Unmarshal(app.globals.properties["chapter-list"], &chapters); err != nil {
+		slog.Error("failed to unmarshal chapter list", "err", err)
+	}
+	index := -1
+	if err := json.Unmarshal(app.globals.properties["chapter"], &index); err != nil {
+		slog.Error("failed to unmarshal chapter", "err", err)
+	}
+	if index < 0 || index >= len(chapters) {
+		return chapters, -1
+	}
+	return chapters, index
+}
+
+// introEnd returns index of the chapter after intro if the current chapter is an intro. Intro which is
+// the last chapter is not skippable. It must be called with app.m locked.
+func (app *App) introEnd() (int, bool) {
+	chapters, index := app.currentChapter()
+	if index == -1 || index+1 >= len(chapters) || !app.introPattern.MatchString(chapters[index].Title) {
+		return 0, false
+	}
+	return index + 1, true
+}
+
+// trackChapters publishes whether intro is playing and skips it if auto-skip is enabled.
+// It must be called with app.m locked.
+func (app *App) trackChapters(name string) {
+	switch name {
+	case "path":
+		app.chapters.skipped = nil
+		return
+	case "chapter", "chapter-list":
+	default:
+		return
+	}
+
+	next, intro := app.introEnd()
+	if intro != app.chapters.intro {
+		app.chapters.intro = intro
+		app.sendEvent(app.makeGlobalPropertyEvent("intro", intro))
+	}
+	if !intro || !app.chapters.autoSkipIntro || app.mpv == nil || app.chapters.skipped[next-1] {
+		return
+	}
+
+	if app.chapters.skipped == nil {
+		app.chapters.skipped = make(map[int]bool)
+	}
+	app.chapters.skipped[next-1] = true
+
+	conn := app.mpv
+	go func() {
+		ctx, cancel := context.WithTimeout(conn.Context(), app.server.commandTimeout)
+		defer cancel()
+		if err := skipIntro(ctx, conn, next); err != nil {
+			slog.Error("failed to skip intro", "err", err)
+		}
+	}()
+}
+
+// IntroEnd returns index of the chapter after intro, or errNotInIntro if the current chapter is not an intro.
+func (app *App) IntroEnd() (int, error) {
+	app.m.Lock()
+	defer app.m.Unlock()
+
+	next, ok := app.introEnd()
+	if !ok {
+		return 0, errNotInIntro
+	}
+	return next, nil
+}
+
+// SetAutoSkipIntro toggles skipping of intro chapters. Intro which is playing now is skipped too.
+func (app *App) SetAutoSkipIntro(enabled bool) {
+	app.m.Lock()
+	defer app.m.Unlock()
+
+	if app.chapters.autoSkipIntro == enabled {
+		return
+	}
+	app.chapters.autoSkipIntro = enabled
+	app.sendEvent(app.makeGlobalPropertyEvent("auto-skip-intro", enabled))
+	app.trackChapters("chapter")
+}
+
+func skipIntro(ctx context.Context, conn *mpv.Conn, next int) error {
+	if err := conn.SetChapter(ctx, next); err != nil {
+		return err
+	}
+	return conn.ShowText(ctx, "Skipped intro")
+}
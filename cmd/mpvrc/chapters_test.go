@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/config"
+)
+
+var testChapters = []any{
+	map[string]any{"title": "Opening", "time": 0.0},
+	map[string]any{"title": "Part A", "time": 90.0},
+	map[string]any{"title": "Ending", "time": 1300.0},
+}
+
+func (s *appSuite) TestIntroPattern() {
+	pattern := regexp.MustCompile(config.Default().Chapters.IntroPattern)
+	for title, intro := range map[string]bool{
+		"Opening":          true,
+		"OP":               true,
+		"OP2":              true,
+		"Intro":            true,
+		"Opening Credits":  true,
+		"Chapter 1":        false,
+		"Prologue":         false,
+		"Stop the Machine": false,
+		"Operation":        false,
+		"":                 false,
+	} {
+		s.Equal(intro, pattern.MatchString(title), title)
+	}
+}
+
+func (s *appSuite) TestChapters() {
+	r := s.Require()
+	events := s.listen()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/load", `{"path": "/video/a.mkv"}`).StatusCode)
+	s.fake.SetProperty("chapter-list", testChapters)
+	s.fake.SetProperty("chapter", 0.0)
+	s.waitForEvent(events, "intro", true)
+
+	response := s.api(srv, "GET", "/api/state", "")
+	r.Equal(http.StatusOK, response.StatusCode)
+	var state playbackState
+	r.NoError(json.NewDecoder(response.Body).Decode(&state))
+	s.Len(state.Chapters, 3)
+	s.Equal("Opening", state.Chapters[0].Title)
+	s.Equal(0, state.Chapter)
+	s.True(state.Intro)
+	s.False(state.AutoSkipIntro)
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/chapters/skip-intro", "").StatusCode)
+	s.waitForEvent(events, "intro", false)
+	s.Equal(1.0, s.fake.Property("chapter"))
+
+	response = s.api(srv, "POST", "/api/chapters/skip-intro", "")
+	r.Equal(http.StatusConflict, response.StatusCode)
+	s.Equal("unavailable", s.decodeAPIError(response).Code)
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/chapters/next", "").StatusCode)
+	s.Equal(2.0, s.fake.Property("chapter"))
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/chapters/prev", "").StatusCode)
+	s.Equal(1.0, s.fake.Property("chapter"))
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/chapters/0/play", "").StatusCode)
+	s.waitForEvent(events, "intro", true)
+
+	r.Equal(http.StatusBadRequest, s.api(srv, "POST", "/api/chapters/first/play", "").StatusCode)
+}
+
+func (s *appSuite) TestAutoSkipIntro() {
+	r := s.Require()
+	events := s.listen()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/chapters/auto-skip-intro", `{"enabled": true}`).StatusCode)
+	s.waitForEvent(events, "auto-skip-intro", true)
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/load", `{"path": "/video/a.mkv"}`).StatusCode)
+	s.fake.SetProperty("chapter-list", testChapters)
+	s.fake.SetProperty("chapter", 0.0)
+	s.waitForEvent(events, "chapter", 1.0)
+	s.Eventually(func() bool { return s.commandCount("show-text", "Skipped intro") == 1 }, time.Second, time.Millisecond)
+
+	// Intro is skipped once, so it can be watched by seeking back.
+	s.fake.SetProperty("chapter", 0.0)
+	s.waitForEvent(events, "intro", true)
+	s.Never(func() bool { return s.fake.Property("chapter") == 1.0 }, 50*time.Millisecond, time.Millisecond)
+
+	// Intro is skipped again in the next file.
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/load", `{"path": "/video/b.mkv"}`).StatusCode)
+	s.waitForEvent(events, "path", "/video/b.mkv")
+	s.fake.SetProperty("chapter", 0.0)
+	s.waitForEvent(events, "chapter", 1.0)
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/chapters/auto-skip-intro", `{"enabled": false}`).StatusCode)
+	s.waitForEvent(events, "auto-skip-intro", false)
+	r.Equal(http.StatusBadRequest, s.api(srv, "POST", "/api/chapters/auto-skip-intro", `{}`).StatusCode)
+}
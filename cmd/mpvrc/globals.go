@@ -13,13 +13,26 @@ func NewGlobals() *Globals {
 	null := json.RawMessage("null")
 	return &Globals{
 		properties: map[string]json.RawMessage{
-			"playback-time": null,
-			"duration":      null,
-			"pause":         json.RawMessage("false"),
-			"volume":        json.RawMessage("100.000000"),
-			"path":          null,
-			"speed":         json.RawMessage("1.000000"),
-			"track-list":    null,
+			"playback-time":     null,
+			"duration":          null,
+			"pause":             json.RawMessage("false"),
+			"volume":            json.RawMessage("100.000000"),
+			"path":              null,
+			"speed":             json.RawMessage("1.000000"),
+			"track-list":        null,
+			"playlist":          null,
+			"chapter-list":      null,
+			"chapter":           null,
+			"audio-device":      null,
+			"audio-device-list": null,
+			"vid":               json.RawMessage("false"),
+			"aid":               json.RawMessage("false"),
+			"sid":               json.RawMessage("false"),
+			"secondary-sid":     json.RawMessage("false"),
+			"sub-delay":         json.RawMessage("0.000000"),
+			"audio-delay":       json.RawMessage("0.000000"),
+			"sub-scale":         json.RawMessage("1.000000"),
+			"sub-pos":           json.RawMessage("100"),
 		},
 	}
 }
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/config"
+	"github.com/miere43/mpvrc/internal/mpv"
+)
+
+var errNothingToResume = errors.New("nothing to resume")
+
+// resumeOffer is position from which the playing file can be resumed. With "prompt" resume mode it is
+// sent to listeners as "resume" global property until playback is resumed or the file changes.
+type resumeOffer struct {
+	Path     string  `json:"path"`
+	Position float64 `json:"position"`
+}
+
+// watchState tracks the playing file for watch history, it is guarded by App.m.
+type watchState struct {
+	path     string
+	duration float64
+	// loaded is set when file is loaded and reset by start-file and end-file events, mpv can't seek before that.
+	loaded bool
+	resume *resumeOffer
+}
+
+// trackWatchProgress records change of global property in watch history, it must be called with app.m locked.
+func (app *App) trackWatchProgress(name string, value json.RawMessage) {
+	switch name {
+	case "path":
+		// mpv omits data of unavailable properties, e.g. when nothing is playing.
+		var path string
+		if len(value) > 0 {
+			if err := json.Unmarshal(value, &path); err != nil {
+				slog.Error("failed to unmarshal path", "value", value, "err", err)
+			}
+		}
+		app.watchFile(path)
+
+	case "duration":
+		app.watch.duration = 0
+		if len(value) > 0 {
+			if err := json.Unmarshal(value, &app.watch.duration); err != nil {
+				slog.Error("failed to unmarshal duration", "value", value, "err", err)
+			}
+		}
+
+	case "playback-time":
+		var position *float64
+		if err := json.Unmarshal(value, &position); err != nil || position == nil || app.watch.path == "" {
+			return
+		}
+		app.history.Update(app.watch.path, *position, app.watch.duration)
+		// Playback time is only available for loaded files. It also covers files which were loaded
+		// before mpvrc connected to mpv, e.g. files from the command line.
+		if !app.watch.loaded {
+			app.watch.loaded = true
+			app.autoResume()
+		}
+	}
+}
+
+// watchFile starts tracking path, empty path means nothing is playing. It must be called with app.m locked.
+func (app *App) watchFile(path string) {
+	if path == app.watch.path {
+		return
+	}
+
+	offered := app.watch.resume != nil
+	// Path may change after file-loaded event, so loaded state is kept.
+	app.watch = watchState{path: path, loaded: app.watch.loaded}
+
+	mode := app.config.History.Resume
+	if path != "" && mode != config.ResumeOff {
+		if position, ok := app.history.ResumePosition(path); ok {
+			app.watch.resume = &resumeOffer{Path: path, Position: position}
+		}
+	}
+	if mode == config.ResumePrompt && (offered || app.watch.resume != nil) {
+		app.sendEvent(app.makeGlobalPropertyEvent("resume", app.watch.resume))
+	}
+	app.autoResume()
+}
+
+// handleFileEvent updates watch state on file events, it must be called with app.m locked.
+func (app *App) handleFileEvent(event mpv.Event) {
+	switch e := event.(type) {
+	case mpv.StartFile:
+		app.watch.loaded = false
+
+	case mpv.FileLoaded:
+		app.watch.loaded = true
+		app.autoResume()
+
+	case mpv.EndFile:
+		app.watch.loaded = false
+		if e.Reason != mpv.EndFileReasonEOF {
+			return
+		}
+		// Path may already belong to the next file, so finished file is found by its playlist entry.
+		if path := app.playlistEntryFilename(e.PlaylistEntryID); path != "" {
+			app.history.Complete(path)
+		}
+	}
+}
+
+func (app *App) playlistEntryFilename(id int) string {
+	var playlist []mpv.PlaylistEntry
+	if err := json.Unmarshal(app.globals.properties["playlist"], &playlist); err != nil {
+		slog.Error("failed to unmarshal playlist", "err", err)
+		return ""
+	}
+	for _, entry := range playlist {
+		if entry.ID == id {
+			return entry.Filename
+		}
+	}
+	return ""
+}
+
+// autoResume resumes loaded file in background if resume mode is "auto", it must be called with app.m locked.
+func (app *App) autoResume() {
+	if app.config.History.Resume != config.ResumeAuto || !app.watch.loaded || app.watch.resume == nil || app.mpv == nil {
+		return
+	}
+	offer := *app.watch.resume
+	app.watch.resume = nil
+
+	conn := app.mpv
+	go func() {
+		ctx, cancel := context.WithTimeout(conn.Context(), app.server.commandTimeout)
+		defer cancel()
+
+		// mpv might have started the file from some position by itself, e.g. with --start or watch later.
+		if position, err := mpv.GetProperty[float64](ctx, conn, "playback-time"); err == nil && position > 1 {
+			slog.Debug("not resuming file which doesn't start from the beginning", "path", offer.Path, "position", position)
+			return
+		}
+		if err := resumePlayback(ctx, conn, offer); err != nil {
+			slog.Error("failed to resume playback", "path", offer.Path, "err", err)
+		}
+	}()
+}
+
+// ResumeOffer returns offer to resume the playing file and forgets it, so playback is resumed only once.
+func (app *App) ResumeOffer() (resumeOffer, error) {
+	app.m.Lock()
+	defer app.m.Unlock()
+
+	if app.watch.resume == nil {
+		return resumeOffer{}, errNothingToResume
+	}
+	offer := *app.watch.resume
+	app.watch.resume = nil
+	if app.config.History.Resume == config.ResumePrompt {
+		app.sendEvent(app.makeGlobalPropertyEvent("resume", app.watch.resume))
+	}
+	return offer, nil
+}
+
+func resumePlayback(ctx context.Context, conn *mpv.Conn, offer resumeOffer) error {
+	position := time.Duration(offer.Position * float64(time.Second))
+	if err := conn.Seek(ctx, position, mpv.SeekAbsolute); err != nil {
+		return err
+	}
+	return conn.ShowText(ctx, "Resumed from "+formatPosition(position))
+}
+
+// formatPosition formats position as HH:MM:SS like the web UI does.
+func formatPosition(position time.Duration) string {
+	seconds := int(position.Seconds())
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/config"
+	"github.com/miere43/mpvrc/internal/history"
+	"github.com/miere43/mpvrc/internal/mpv"
+)
+
+func (s *appSuite) historyEntries(srv *httptest.Server, query string) []history.Entry {
+	response := s.api(srv, "GET", "/api/history"+query, "")
+	s.Require().Equal(http.StatusOK, response.StatusCode)
+	var entries []history.Entry
+	s.Require().NoError(json.NewDecoder(response.Body).Decode(&entries))
+	return entries
+}
+
+func (s *appSuite) TestWatchHistoryResumesAutomatically() {
+	r := s.Require()
+	events := s.listen()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+	s.app.history.Update("/video/a.mkv", 600, 1200)
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/load", `{"path": "/video/a.mkv"}`).StatusCode)
+	s.waitForEvent(events, "playback-time", 600.0)
+	s.Eventually(func() bool { return s.commandCount("show-text", "Resumed from 00:10:00") == 1 }, time.Second, time.Millisecond)
+
+	s.fake.SetProperty("duration", 1200.0)
+	s.fake.SetProperty("playback-time", 700.0)
+	s.waitForEvent(events, "playback-time", 700.0)
+	entries := s.historyEntries(srv, "?unfinished=true")
+	r.Len(entries, 1)
+	s.Equal("/video/a.mkv", entries[0].Path)
+	s.Equal(700.0, entries[0].Position)
+	s.Equal(1200.0, entries[0].Duration)
+
+	var playlist []mpv.PlaylistEntry
+	r.NoError(json.NewDecoder(s.api(srv, "GET", "/api/playlist", "").Body).Decode(&playlist))
+	r.Len(playlist, 1)
+	s.fake.Emit("end-file", map[string]any{"reason": "eof", "playlist_entry_id": playlist[0].ID})
+	s.Eventually(func() bool {
+		entry, _ := s.app.history.Entry("/video/a.mkv")
+		return entry.Completed
+	}, time.Second, time.Millisecond)
+	s.Empty(s.historyEntries(srv, "?unfinished=true"))
+	s.Len(s.historyEntries(srv, ""), 1)
+
+	// Watched file is played from the beginning.
+	seeks := s.commandCount("seek", nil)
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/load", `{"path": "/video/b.mkv"}`).StatusCode)
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/load", `{"path": "/video/a.mkv"}`).StatusCode)
+	s.waitForEvent(events, "path", "/video/a.mkv")
+	s.Equal(seeks, s.commandCount("seek", nil))
+
+	r.Equal(http.StatusNoContent, s.api(srv, "DELETE", "/api/history?path=/video/b.mkv", "").StatusCode)
+	r.Equal(http.StatusNotFound, s.api(srv, "DELETE", "/api/history?path=/video/b.mkv", "").StatusCode)
+	r.Equal(http.StatusNoContent, s.api(srv, "DELETE", "/api/history", "").StatusCode)
+	s.Empty(s.historyEntries(srv, ""))
+}
+
+func (s *appSuite) TestWatchHistoryPromptsToResume() {
+	r := s.Require()
+	s.app.config.History.Resume = config.ResumePrompt
+	events := s.listen()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+	s.app.history.Update("/video/a.mkv", 90.5, 1200)
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/load", `{"path": "/video/a.mkv"}`).StatusCode)
+	s.waitForEventFunc(events, "resume", func(value any) bool {
+		return value != nil && value.(map[string]any)["position"] == 90.5
+	})
+	s.Equal(0, s.commandCount("seek", nil), "playback is not resumed until client asks")
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/history/resume", "").StatusCode)
+	s.waitForEvent(events, "resume", nil)
+	s.Equal(90.5, s.fake.Property("playback-time"))
+
+	response := s.api(srv, "POST", "/api/history/resume", "")
+	r.Equal(http.StatusNotFound, response.StatusCode)
+	s.Equal("not_found", s.decodeAPIError(response).Code)
+}
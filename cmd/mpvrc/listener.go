@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// maxListenerQueue is how many events can be queued for a listener before it is dropped.
+	maxListenerQueue = 256
+	// maxListenerLag is how long a listener can leave events unread before it is dropped.
+	maxListenerLag = 10 * time.Second
+	// maxEventHistory is how many recent events are kept for resuming event streams.
+	maxEventHistory = 256
+)
+
+var (
+	errListenerTooSlow = errors.New("event listener is too slow")
+	errTokenRevoked    = errors.New("token was revoked")
+)
+
+// AppEventListener receives app events. Sending events never blocks: events are queued,
+// repeated events of the same property are coalesced, and listener which falls behind is dropped.
+type AppEventListener struct {
+	ID int
+	// TokenID is ID of the token which opened the listener, it is empty for listeners of the app itself.
+	TokenID string
+	// properties are subscribed properties, guarded by App.m.
+	properties map[string]bool
+
+	m            sync.Mutex
+	queue        []AppEvent
+	pendingSince time.Time
+	ready        chan struct{}
+	done         chan struct{}
+	err          error
+}
+
+// AppEvent is JSON encoded event. Events sent to all listeners have increasing IDs.
+// Events of a state snapshot have ID of the latest event sent before the snapshot.
+type AppEvent struct {
+	ID   uint64
+	Key  string
+	Data []byte
+	// Property is set for events of subscribed property, they are only sent to its subscribers.
+	Property string
+}
+
+func newAppEventListener(id int) *AppEventListener {
+	return &AppEventListener{
+		ID:         id,
+		properties: make(map[string]bool),
+		ready:      make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+}
+
+// Ready is signaled when there are events to Take.
+func (l *AppEventListener) Ready() <-chan struct{} {
+	return l.ready
+}
+
+// Done is closed when listener was closed or dropped, Err returns the reason.
+func (l *AppEventListener) Done() <-chan struct{} {
+	return l.done
+}
+
+func (l *AppEventListener) Err() error {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.err
+}
+
+// wants reports whether event must be sent to listener, it must be called with App.m locked.
+func (l *AppEventListener) wants(event AppEvent) bool {
+	return event.Property == "" || l.properties[event.Property]
+}
+
+// Take returns queued events in order they were sent.
+func (l *AppEventListener) Take() []AppEvent {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	events := l.queue
+	l.queue = nil
+	return events
+}
+
+// push queues event. Queued event with the same key is replaced, so only the latest value of a property is sent.
+// It returns errListenerTooSlow if listener must be dropped.
+func (l *AppEventListener) push(event AppEvent, now time.Time) error {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if len(l.queue) == 0 {
+		l.pendingSince = now
+	} else if now.Sub(l.pendingSince) > maxListenerLag {
+		return errListenerTooSlow
+	}
+
+	if index := slices.IndexFunc(l.queue, func(queued AppEvent) bool { return queued.Key == event.Key }); index != -1 {
+		l.queue = slices.Delete(l.queue, index, index+1)
+	}
+	if len(l.queue) >= maxListenerQueue {
+		return errListenerTooSlow
+	}
+	l.queue = append(l.queue, event)
+
+	select {
+	case l.ready <- struct{}{}:
+	default: // Already signaled.
+	}
+	return nil
+}
+
+// close marks listener as done. It is safe to call multiple times.
+func (l *AppEventListener) close(err error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	select {
+	case <-l.done:
+	default:
+		l.err = err
+		close(l.done)
+	}
+}
+
+// eventHistory keeps recent events, so listener can resume from the last event it has received.
+type eventHistory struct {
+	events []AppEvent
+	lastID uint64
+}
+
+// add appends event with the next ID and returns the event.
+func (h *eventHistory) add(event AppEvent) AppEvent {
+	h.lastID++
+	event.ID = h.lastID
+
+	if len(h.events) == maxEventHistory {
+		h.events = slices.Delete(h.events, 0, 1)
+	}
+	h.events = append(h.events, event)
+	return event
+}
+
+// since returns events after event id. It returns false if some of them are not kept anymore.
+func (h *eventHistory) since(id uint64) ([]AppEvent, bool) {
+	if id > h.lastID {
+		return nil, false
+	}
+	if id == h.lastID {
+		return nil, true
+	}
+	if len(h.events) == 0 || h.events[0].ID > id+1 {
+		return nil, false
+	}
+
+	index := slices.IndexFunc(h.events, func(event AppEvent) bool { return event.ID > id })
+	return slices.Clone(h.events[index:]), true
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type listenerSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+func TestListener(t *testing.T) {
+	suite.Run(t, new(listenerSuite))
+}
+
+func (s *listenerSuite) SetupTest() {
+	s.now = time.Now()
+}
+
+func (s *listenerSuite) TestCoalesce() {
+	listener := newAppEventListener(1)
+	s.Require().NoError(listener.push(AppEvent{ID: 1, Key: "playback-time", Data: []byte("1")}, s.now))
+	s.Require().NoError(listener.push(AppEvent{ID: 2, Key: "pause", Data: []byte("true")}, s.now))
+	s.Require().NoError(listener.push(AppEvent{ID: 3, Key: "playback-time", Data: []byte("2")}, s.now))
+
+	select {
+	case <-listener.Ready():
+	default:
+		s.Fail("listener is not ready")
+	}
+
+	s.Equal([]AppEvent{
+		{ID: 2, Key: "pause", Data: []byte("true")},
+		{ID: 3, Key: "playback-time", Data: []byte("2")},
+	}, listener.Take())
+	s.Empty(listener.Take())
+}
+
+func (s *listenerSuite) TestDropWhenLagging() {
+	listener := newAppEventListener(1)
+	s.Require().NoError(listener.push(AppEvent{Key: "pause"}, s.now))
+	s.Require().NoError(listener.push(AppEvent{Key: "pause"}, s.now.Add(maxListenerLag)))
+	s.ErrorIs(listener.push(AppEvent{Key: "volume"}, s.now.Add(maxListenerLag+time.Second)), errListenerTooSlow)
+
+	// Lag is counted from the oldest unread event.
+	listener.Take()
+	s.NoError(listener.push(AppEvent{Key: "volume"}, s.now.Add(2*maxListenerLag)))
+}
+
+func (s *listenerSuite) TestDropWhenQueueIsFull() {
+	listener := newAppEventListener(1)
+	for i := range maxListenerQueue {
+		s.Require().NoError(listener.push(AppEvent{Key: fmt.Sprint(i)}, s.now))
+	}
+	s.ErrorIs(listener.push(AppEvent{Key: "overflow"}, s.now), errListenerTooSlow)
+}
+
+func (s *listenerSuite) TestHistory() {
+	var history eventHistory
+
+	events, ok := history.since(0)
+	s.True(ok)
+	s.Empty(events)
+
+	for i := range maxEventHistory + 10 {
+		event := history.add(AppEvent{Key: "playback-time", Data: []byte(fmt.Sprint(i))})
+		s.Equal(uint64(i+1), event.ID)
+	}
+
+	events, ok = history.since(history.lastID - 2)
+	s.True(ok)
+	s.Len(events, 2)
+	s.Equal(history.lastID, events[1].ID)
+
+	_, ok = history.since(5)
+	s.False(ok, "events after 5 are not kept")
+
+	_, ok = history.since(history.lastID + 1)
+	s.False(ok, "event from the future")
+}
@@ -1,29 +1,84 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+
+	"github.com/miere43/mpvrc/internal/config"
 )
 
 func main() {
-	setupLogging()
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:]))
+	}
+
+	cfg, files, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		reportStartupError(err)
+		os.Exit(2)
+	}
 
-	app, ok := NewApp()
+	setupLogging(cfg)
+	slog.Info("loaded configuration", "file", cfg.File)
+
+	app, ok, err := NewApp(cfg, files)
+	if err != nil {
+		reportStartupError(err)
+		os.Exit(1)
+	}
 	if ok {
 		<-app.Done()
 	}
 }
 
-func setupLogging() {
-	exePath, err := os.Executable()
+// runConfigCommand implements "mpvrc config print [flags]" and returns exit code.
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: mpvrc config print [flags]")
+		return 2
+	}
+
+	cfg, _, err := config.Load(args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "mpvrc:", err)
+		return 1
+	}
+
+	cfgJSON, err := json.MarshalIndent(cfg, "", "    ")
 	if err != nil {
-		slog.Error("failed to get executable path", "err", err)
-		return
+		fmt.Fprintln(os.Stderr, "mpvrc:", err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "# configuration file: %s\n", cfg.File)
+	fmt.Println(string(cfgJSON))
+	return 0
+}
+
+func setupLogging(cfg config.Config) {
+	// Config was validated, so level is always valid here.
+	level, _ := cfg.SlogLevel()
+
+	logPath := cfg.LogPath
+	if logPath == "" {
+		exePath, err := os.Executable()
+		if err != nil {
+			slog.Error("failed to get executable path", "err", err)
+			return
+		}
+		logPath = filepath.Join(filepath.Dir(exePath), "mpvrc.log")
 	}
 
-	logPath := filepath.Join(filepath.Dir(exePath), "mpvrc.log")
 	file, err := os.Create(logPath)
 	if err != nil {
 		slog.Error("failed to create log file", "logPath", logPath, "err", err)
@@ -36,7 +91,7 @@ func setupLogging() {
 	}
 
 	logger := slog.New(slog.NewJSONHandler(writer, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
+		Level: level,
 	}))
 	slog.SetDefault(logger)
 }
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+
+	"github.com/miere43/mpvrc/internal/mpv"
+	"github.com/miere43/mpvrc/internal/prefs"
+)
+
+// trackPreferences applies preferences when path of the loaded file changes, it must be called with app.m locked.
+func (app *App) trackPreferences(name string) {
+	if name == "path" {
+		app.applyPreferences()
+	}
+}
+
+// handlePreferencesEvent applies preferences when file is loaded, it must be called with app.m locked.
+func (app *App) handlePreferencesEvent(event mpv.Event) {
+	switch event.(type) {
+	case mpv.StartFile:
+		// The same file can be played again, e.g. when playlist loops.
+		app.prefsApplied = ""
+	case mpv.FileLoaded:
+		app.applyPreferences()
+	}
+}
+
+// applyPreferences applies remembered preferences to the loaded file once, in background.
+// It must be called with app.m locked.
+func (app *App) applyPreferences() {
+	path := app.watch.path
+	if !app.config.Preferences.Remember || !app.watch.loaded || path == "" || path == app.prefsApplied || app.mpv == nil {
+		return
+	}
+	app.prefsApplied = path
+
+	p := app.prefs.Lookup(path)
+	// Volume of audio device takes precedence, otherwise the one which is set last would depend on event order.
+	if app.config.Audio.RememberVolume {
+		p.Volume = nil
+	}
+	if p.IsZero() {
+		return
+	}
+
+	conn := app.mpv
+	go func() {
+		ctx, cancel := context.WithTimeout(conn.Context(), app.server.commandTimeout)
+		defer cancel()
+		if err := applyPreferences(ctx, conn, p); err != nil {
+			slog.Error("failed to apply preferences", "path", path, "err", err)
+		}
+	}()
+}
+
+// preferenceTrackTypes are track types which are remembered in preferences.
+var preferenceTrackTypes = []mpv.TrackType{mpv.TrackAudio, mpv.TrackSub, mpv.TrackSecondarySub}
+
+// applyPreferences selects tracks matching rules of p and sets remembered options. Tracks are left as mpv
+// chose them if the file has no matching track.
+func applyPreferences(ctx context.Context, conn *mpv.Conn, p prefs.Preferences) error {
+	var errs []error
+	if p.Audio != nil || p.Sub != nil || p.SecondarySub != nil {
+		tracks, err := conn.Tracks(ctx)
+		if err != nil {
+			return err
+		}
+		for _, trackType := range preferenceTrackTypes {
+			rule := *p.Track(trackType)
+			switch {
+			case rule == nil:
+			case rule.Off:
+				errs = append(errs, conn.DisableTrack(ctx, trackType))
+			default:
+				if id, ok := rule.Match(tracks, trackListType(trackType)); ok {
+					errs = append(errs, conn.SelectTrack(ctx, trackType, id))
+				}
+			}
+		}
+	}
+
+	if p.SubDelay != nil {
+		errs = append(errs, conn.SetSubDelay(ctx, *p.SubDelay))
+	}
+	if p.AudioDelay != nil {
+		errs = append(errs, conn.SetAudioDelay(ctx, *p.AudioDelay))
+	}
+	if p.Volume != nil {
+		errs = append(errs, conn.SetVolume(ctx, *p.Volume))
+	}
+	return errors.Join(errs...)
+}
+
+// trackListType returns type of tracks in "track-list" which trackType selects from.
+func trackListType(trackType mpv.TrackType) mpv.TrackType {
+	if trackType == mpv.TrackSecondarySub {
+		return mpv.TrackSub
+	}
+	return trackType
+}
+
+// preferenceProperties returns properties which are changed when p is applied.
+func preferenceProperties(p prefs.Preferences) []string {
+	var properties []string
+	for _, trackType := range preferenceTrackTypes {
+		if *p.Track(trackType) != nil {
+			property, _ := mpv.TrackProperty(trackType)
+			properties = append(properties, property)
+		}
+	}
+	if p.SubDelay != nil {
+		properties = append(properties, "sub-delay")
+	}
+	if p.AudioDelay != nil {
+		properties = append(properties, "audio-delay")
+	}
+	if p.Volume != nil {
+		properties = append(properties, "volume")
+	}
+	return properties
+}
+
+// RememberTrack remembers track choice for the playing file and its directory. Zero id means tracks were turned off.
+func (app *App) RememberTrack(trackType mpv.TrackType, id int) {
+	app.m.Lock()
+	defer app.m.Unlock()
+
+	if !slices.Contains(preferenceTrackTypes, trackType) {
+		return
+	}
+
+	rule := prefs.TrackRule{Off: true}
+	if id != 0 {
+		var tracks []mpv.Track
+		if err := json.Unmarshal(app.globals.properties["track-list"], &tracks); err != nil {
+			slog.Error("failed to unmarshal track list", "err", err)
+			return
+		}
+		listType := string(trackListType(trackType))
+		index := slices.IndexFunc(tracks, func(track mpv.Track) bool { return track.Type == listType && track.ID == id })
+		if index == -1 {
+			return
+		}
+		rule = prefs.RuleFor(tracks[index])
+	}
+	app.rememberPreferences(func(p *prefs.Preferences) { *p.Track(trackType) = &rule })
+}
+
+// RememberPreferences changes preferences of the playing file and its directory with fn.
+func (app *App) RememberPreferences(fn func(p *prefs.Preferences)) {
+	app.m.Lock()
+	defer app.m.Unlock()
+
+	app.rememberPreferences(fn)
+}
+
+// rememberPreferences must be called with app.m locked.
+func (app *App) rememberPreferences(fn func(p *prefs.Preferences)) {
+	if !app.config.Preferences.Remember || app.watch.path == "" {
+		return
+	}
+	app.prefs.Remember(app.watch.path, fn)
+}
+
+// PreferencesPath returns path, or path of the playing file if path is empty.
+func (app *App) PreferencesPath(path string) (string, error) {
+	if path == "" {
+		app.m.Lock()
+		path = app.watch.path
+		app.m.Unlock()
+		if path == "" {
+			return "", fmt.Errorf("%w: path is required when nothing is playing", errBadRequest)
+		}
+	}
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("%w: path %q must be absolute", errBadRequest, path)
+	}
+	return path, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/mpv/mpvtest"
+	"github.com/miere43/mpvrc/internal/prefs"
+)
+
+var testSeriesTracks = []any{
+	map[string]any{"id": 1, "type": "audio", "lang": "eng"},
+	map[string]any{"id": 2, "type": "audio", "lang": "jpn"},
+	map[string]any{"id": 1, "type": "sub", "lang": "eng", "title": "Signs & Songs"},
+	map[string]any{"id": 2, "type": "sub", "lang": "eng", "title": "Full Subtitles"},
+}
+
+func (s *appSuite) TestPreferencesAreRememberedForSeries() {
+	r := s.Require()
+	events := s.listen()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+
+	s.fake.SetProperty("track-list", testSeriesTracks)
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/load", `{"path": "/series/Show - 01.mkv"}`).StatusCode)
+	s.waitForEventFunc(events, "track-list", func(value any) bool { return len(value.([]any)) == 4 })
+	s.waitForEvent(events, "path", "/series/Show - 01.mkv")
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/tracks/audio/2", "").StatusCode)
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/tracks/sub/2", "").StatusCode)
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/tracks/adjust", `{"subDelay": -0.5, "subScale": 1.2}`).StatusCode)
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/volume", `{"volume": 80}`).StatusCode)
+
+	response := s.api(srv, "GET", "/api/preferences", "")
+	r.Equal(http.StatusOK, response.StatusCode)
+	var result struct {
+		Path      string             `json:"path"`
+		File      *prefs.Preferences `json:"file"`
+		Directory *prefs.Preferences `json:"directory"`
+	}
+	r.NoError(json.NewDecoder(response.Body).Decode(&result))
+	s.Equal("/series/Show - 01.mkv", result.Path)
+	r.NotNil(result.File)
+	r.NotNil(result.Directory)
+	s.Equal(*result.File, *result.Directory)
+	s.Equal(&prefs.TrackRule{Lang: "jpn"}, result.Directory.Audio)
+	s.Equal(&prefs.TrackRule{Lang: "eng", Title: "^Full Subtitles$"}, result.Directory.Sub)
+	s.Nil(result.Directory.SecondarySub)
+	r.NotNil(result.Directory.SubDelay)
+	s.Equal(-0.5, *result.Directory.SubDelay)
+	r.NotNil(result.Directory.Volume)
+	s.Equal(80.0, *result.Directory.Volume)
+
+	// The next episode starts with mpv defaults and gets preferences of the series.
+	for name, value := range map[string]any{"aid": 1.0, "sid": 1.0, "sub-delay": 0.0, "volume": 100.0} {
+		s.fake.SetProperty(name, value)
+	}
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/load", `{"path": "/series/Show - 02.mkv"}`).StatusCode)
+	s.Eventually(func() bool {
+		return s.fake.Property("aid") == 2.0 && s.fake.Property("sid") == 2.0 &&
+			s.fake.Property("sub-delay") == -0.5 && s.fake.Property("volume") == 80.0
+	}, time.Second, time.Millisecond)
+
+	// Files in other directories are not affected.
+	s.fake.SetProperty("aid", 1.0)
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/load", `{"path": "/movies/Movie.mkv"}`).StatusCode)
+	s.waitForEvent(events, "path", "/movies/Movie.mkv")
+	s.never(func(fake *mpvtest.Server) bool { return fake.Property("aid") == 2.0 })
+}
+
+func (s *appSuite) TestDeviceVolumeOverridesPreferences() {
+	r := s.Require()
+	s.app.config.Audio.RememberVolume = true
+	r.NoError(s.app.loadAudioVolumes(filepath.Join(s.T().TempDir(), "audio-volumes.json")))
+	events := s.listen()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+
+	s.fake.SetProperty("track-list", testSeriesTracks)
+	r.Equal(http.StatusNoContent, s.api(srv, "PUT", "/api/preferences", `{
+		"path": "/series/Show - 01.mkv",
+		"scope": "directory",
+		"preferences": {"sub": {"lang": "eng", "excludeTitle": "(?i)signs"}, "volume": 30}
+	}`).StatusCode)
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/load", `{"path": "/series/Show - 01.mkv"}`).StatusCode)
+	s.waitForEvent(events, "path", "/series/Show - 01.mkv")
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/volume", `{"volume": 70}`).StatusCode)
+
+	p, ok := s.app.prefs.Get("/series/Show - 01.mkv", prefs.ScopeFile)
+	s.True(!ok || p.Volume == nil, "volume is remembered for audio device only")
+
+	// Other preferences are applied, volume of the device stays.
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/load", `{"path": "/series/Show - 02.mkv"}`).StatusCode)
+	s.Eventually(func() bool { return s.fake.Property("sid") == 2.0 }, time.Second, time.Millisecond)
+	s.never(func(fake *mpvtest.Server) bool { return fake.Property("volume") != 70.0 })
+}
+
+func (s *appSuite) TestSetPreferences() {
+	r := s.Require()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+
+	s.fake.SetProperty("track-list", testSeriesTracks)
+	r.Equal(http.StatusNoContent, s.api(srv, "PUT", "/api/preferences", `{
+		"path": "/series/Show - 01.mkv",
+		"scope": "directory",
+		"preferences": {"sub": {"lang": "eng", "excludeTitle": "(?i)signs"}, "secondarySub": {"off": true}}
+	}`).StatusCode)
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/load", `{"path": "/series/Show - 03.mkv"}`).StatusCode)
+	s.Eventually(func() bool {
+		return s.fake.Property("sid") == 2.0 && s.fake.Property("secondary-sid") == "no"
+	}, time.Second, time.Millisecond)
+
+	query := "?path=" + url.QueryEscape("/series/Show - 01.mkv") + "&scope=directory"
+	r.Equal(http.StatusNoContent, s.api(srv, "DELETE", "/api/preferences"+query, "").StatusCode)
+	response := s.api(srv, "DELETE", "/api/preferences"+query, "")
+	r.Equal(http.StatusNotFound, response.StatusCode)
+	s.Equal("not_found", s.decodeAPIError(response).Code)
+
+	for _, test := range []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"PUT", "/api/preferences", `{"scope": "series", "preferences": {}}`},
+		{"PUT", "/api/preferences", `{"scope": "file", "preferences": {"sub": {"title": "("}}}`},
+		{"PUT", "/api/preferences", `{"path": "Show.mkv", "scope": "file", "preferences": {}}`},
+		{"DELETE", "/api/preferences?scope=file&path=" + url.QueryEscape("/series/Show - 03.mkv") + "x", ""},
+		{"GET", "/api/preferences?path=Show.mkv", ""},
+	} {
+		response := s.api(srv, test.method, test.path, test.body)
+		s.Less(response.StatusCode, 500, "%s %s", test.method, test.path)
+		s.GreaterOrEqual(response.StatusCode, 400, "%s %s", test.method, test.path)
+	}
+}
@@ -2,27 +2,42 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
-	"syscall"
 	"time"
 
+	"github.com/miere43/mpvrc/internal/certs"
+	"github.com/miere43/mpvrc/internal/media"
+	"github.com/miere43/mpvrc/internal/policy"
 	"github.com/miere43/mpvrc/internal/util"
 	"github.com/miere43/mpvrc/winres"
 )
 
 type httpServer struct {
-	srv         *http.Server
-	app         *App
-	appDir      string
-	shutdownSSE chan struct{}
+	srv            *http.Server
+	app            *App
+	appDir         string
+	shutdownSSE    chan struct{}
+	commandTimeout time.Duration
+	policy         *policy.Policy
+	sseRetry       time.Duration
+	sseHeartbeat   time.Duration
+
+	// redirect is HTTP server which redirects to HTTPS, it is nil if redirect is disabled.
+	redirect *http.Server
+	// caCert is PEM encoded CA certificate which signed generated server certificate.
+	caCert []byte
 }
 
 func newHttpServer(app *App) *httpServer {
@@ -34,19 +49,31 @@ func newHttpServer(app *App) *httpServer {
 	h := http.NewServeMux()
 	s := &httpServer{
 		srv: &http.Server{
-			Addr:    "0.0.0.0:8080",
+			Addr:    app.config.Listen,
 			Handler: h,
 		},
-		app:         app,
-		appDir:      filepath.Dir(exePath),
-		shutdownSSE: make(chan struct{}),
+		app:            app,
+		appDir:         filepath.Dir(exePath),
+		shutdownSSE:    make(chan struct{}),
+		commandTimeout: 10 * time.Second,
+		policy:         policy.New(app.config.Policy),
+		sseRetry:       3 * time.Second,
+		sseHeartbeat:   15 * time.Second,
 	}
 
 	h.Handle("GET /", s.index())
 	h.HandleFunc("GET /favicon.png", s.favicon)
-	h.HandleFunc("GET /events", s.events)
-	h.HandleFunc("POST /command", s.command)
-	h.HandleFunc("GET /file-system", s.fileSystem)
+	h.HandleFunc("GET /ca.crt", s.caCertificate)
+	h.HandleFunc("GET /auth", s.authStatus)
+	h.HandleFunc("POST /pair/pin", s.requestPIN)
+	h.HandleFunc("POST /pair", s.pair)
+	h.HandleFunc("GET /auth/tokens", s.requireAuth(s.tokens))
+	h.HandleFunc("DELETE /auth/tokens/{id}", s.requireAuth(s.revokeToken))
+	h.HandleFunc("GET /events", s.requireAuth(s.events))
+	h.HandleFunc("GET /ws", s.requireAuth(s.websocket))
+	h.HandleFunc("POST /command", s.requireAuth(s.command))
+	h.HandleFunc("GET /file-system", s.requireAuth(s.fileSystem))
+	s.registerAPI(h)
 
 	return s
 }
@@ -59,6 +86,81 @@ func (s *httpServer) Shutdown() {
 	if err := s.srv.Shutdown(ctx); err != nil {
 		slog.Error("failed to shutdown HTTP server", "err", err)
 	}
+	if s.redirect != nil {
+		if err := s.redirect.Shutdown(ctx); err != nil {
+			slog.Error("failed to shutdown HTTPS redirect server", "err", err)
+		}
+	}
+}
+
+// setupTLS configures HTTPS if it is enabled. Self-signed certificate is generated if certificate files are not set.
+func (s *httpServer) setupTLS() error {
+	cfg := s.app.config
+	if !cfg.TLS.Enabled {
+		return nil
+	}
+
+	var cert tls.Certificate
+	var err error
+	if cfg.TLS.CertFile != "" {
+		cert, err = certs.LoadFiles(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	} else {
+		dir := cfg.CertDir()
+		if dir == "" {
+			return errors.New("TLS certificate directory is not set")
+		}
+
+		cert, err = certs.Ensure(dir, certs.LocalHosts())
+		if err == nil {
+			s.caCert, err = os.ReadFile(certs.CAFile(dir))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	s.srv.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.TLS.RedirectListen != "" {
+		h := http.NewServeMux()
+		h.HandleFunc("GET /ca.crt", s.caCertificate)
+		h.HandleFunc("/", s.redirectToHTTPS)
+		s.redirect = &http.Server{
+			Addr:    cfg.TLS.RedirectListen,
+			Handler: h,
+		}
+	}
+	return nil
+}
+
+func (s *httpServer) redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	_, port, _ := net.SplitHostPort(s.srv.Addr)
+
+	target := url.URL{
+		Scheme:   "https",
+		Host:     net.JoinHostPort(host, port),
+		Path:     r.URL.Path,
+		RawQuery: r.URL.RawQuery,
+	}
+	http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+}
+
+// caCertificate serves generated CA certificate, so it can be installed on devices.
+func (s *httpServer) caCertificate(w http.ResponseWriter, r *http.Request) {
+	if s.caCert == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+	w.Header().Set("Content-Disposition", `attachment; filename="mpvrc-ca.crt"`)
+	w.Write(s.caCert)
 }
 
 func (s *httpServer) favicon(w http.ResponseWriter, r *http.Request) {
@@ -66,44 +168,97 @@ func (s *httpServer) favicon(w http.ResponseWriter, r *http.Request) {
 	w.Write(winres.Icon)
 }
 
+// events streams app events. Client which reconnects with Last-Event-ID header receives only events it has missed.
 func (s *httpServer) events(w http.ResponseWriter, r *http.Request) {
+	var properties []string
+	if query := r.URL.Query().Get("properties"); query != "" {
+		properties = strings.Split(query, ",")
+	}
+	if err := s.checkProperties(properties); err != nil {
+		slog.Warn("rejected event stream", "remoteAddr", r.RemoteAddr, "err", err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 
-	listener := s.app.NewEventListener()
+	token, _ := s.authenticate(r)
+	listener := s.app.NewEventListener(token.ID)
+	defer s.app.CloseEventListener(listener)
 
-	for _, event := range s.app.StartupEvents() {
-		eventJSON, err := json.Marshal(event)
-		if err != nil {
-			slog.Error("failed to marshal startup event", "err", err, "event", event)
-			continue
-		}
+	if err := s.app.SubscribeProperties(r.Context(), listener, properties); err != nil {
+		slog.Warn("failed to subscribe to properties", "properties", properties, "err", err)
+	}
 
-		fmt.Fprintf(w, "data: %s\n\n", eventJSON)
+	if lastID, ok := s.parseEventID(r.Header.Get("Last-Event-ID")); ok && s.app.ResumeEvents(listener, lastID) {
+		slog.Debug("resumed event stream", "lastEventId", lastID, "remoteAddr", r.RemoteAddr)
+	} else {
+		s.app.SendStartupEvents(listener)
 	}
+
+	fmt.Fprintf(w, "retry: %d\n\n", s.sseRetry.Milliseconds())
 	w.(http.Flusher).Flush()
 
-	loop := true
-	for loop {
+	heartbeat := time.NewTicker(s.sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
 		select {
-		case event := <-listener.Events:
-			fmt.Fprintf(w, "data: %s\n\n", event)
+		case <-listener.Ready():
+			for _, event := range listener.Take() {
+				fmt.Fprintf(w, "id: %s-%d\ndata: %s\n\n", s.app.EventEpoch(), event.ID, event.Data)
+			}
+			w.(http.Flusher).Flush()
+
+		case <-heartbeat.C:
+			// Comment keeps proxies from closing idle connection.
+			fmt.Fprint(w, ": heartbeat\n\n")
 			w.(http.Flusher).Flush()
 
+		case <-listener.Done():
+			slog.Warn("closing event stream", "remoteAddr", r.RemoteAddr, "err", listener.Err())
+			return
+
 		case <-r.Context().Done():
 			slog.Debug("Context done!")
-			loop = false
-			s.app.CloseEventListener(listener)
+			return
 
 		case <-s.shutdownSSE:
 			slog.Debug("Shutdown SSE!")
-			loop = false
-			s.app.CloseEventListener(listener)
+			return
 		}
 	}
 }
 
+// checkProperties returns an error if client is not allowed to subscribe to some of properties.
+// Global properties are sent to every client, so they are always allowed.
+func (s *httpServer) checkProperties(properties []string) error {
+	for _, property := range properties {
+		if s.app.IsGlobalProperty(property) {
+			continue
+		}
+		if err := s.policy.CheckProperty(property); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseEventID parses event ID sent by events. IDs of previous runs of the app are rejected.
+func (s *httpServer) parseEventID(eventID string) (uint64, bool) {
+	epoch, id, ok := strings.Cut(eventID, "-")
+	if !ok || epoch != s.app.EventEpoch() {
+		return 0, false
+	}
+	lastID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return lastID, true
+}
+
 func (s *httpServer) command(w http.ResponseWriter, r *http.Request) {
 	commandJSON := r.FormValue("command")
 	var command []any
@@ -112,13 +267,36 @@ func (s *httpServer) command(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response, err := s.app.SendCommand(command, false)
-	if err != nil {
-		s.handleError(w, err)
+	data, status, err := s.runCommand(r.Context(), command, r.RemoteAddr)
+	if status == http.StatusForbidden {
+		http.Error(w, err.Error(), status)
+		return
+	} else if err != nil {
+		s.handleErrorStatus(w, status, err)
 		return
 	}
 
-	s.writeJSON(w, response.Data)
+	s.writeJSON(w, data)
+}
+
+// runCommand checks command against the policy and sends it to mpv. Errors come with HTTP status code.
+func (s *httpServer) runCommand(ctx context.Context, command []any, remoteAddr string) (json.RawMessage, int, error) {
+	if err := s.policy.Check(command); err != nil {
+		slog.Warn("rejected command", "command", command, "remoteAddr", remoteAddr, "err", err)
+		return nil, http.StatusForbidden, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.commandTimeout)
+	defer cancel()
+
+	response, err := s.app.SendCommandContext(ctx, command, false)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, http.StatusGatewayTimeout, err
+	} else if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	return response.Data, http.StatusOK, nil
 }
 
 func (s *httpServer) fileSystem(w http.ResponseWriter, r *http.Request) {
@@ -140,21 +318,26 @@ func (s *httpServer) fileSystem(w http.ResponseWriter, r *http.Request) {
 		path = filepath.Dir(path)
 	}
 
+	roots := s.policy.Roots()
+	if len(roots) == 0 {
+		roots = fileSystemRoots()
+	}
+
 	if path == "." {
-		// Get names of all drives (Windows)
-		for _, drive := range "ABCDEFGHIJKLMNOPQRSTUVWXYZ" {
-			drivePath := fmt.Sprintf("%c:/", drive)
-			if _, err := os.Stat(drivePath); err == nil {
-				entries = append(entries, Entry{
-					Name:  drivePath,
-					Path:  drivePath,
-					IsDir: true,
-				})
-			}
+		for _, root := range roots {
+			entries = append(entries, Entry{
+				Name:  root,
+				Path:  root,
+				IsDir: true,
+			})
 		}
 	} else if !filepath.IsAbs(path) {
 		s.handleError(w, fmt.Errorf("path %q must be absolute", path))
 		return
+	} else if err := s.policy.CheckPath(path); err != nil {
+		slog.Warn("rejected file system path", "path", path, "remoteAddr", r.RemoteAddr, "err", err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
 	} else {
 		dirEntries, err := os.ReadDir(path)
 		if err != nil {
@@ -163,7 +346,7 @@ func (s *httpServer) fileSystem(w http.ResponseWriter, r *http.Request) {
 		}
 
 		prevPath := filepath.Dir(path)
-		if prevPath == path {
+		if prevPath == path || slices.Contains(roots, path) {
 			prevPath = ""
 		}
 		entries = append(entries, Entry{
@@ -181,7 +364,7 @@ func (s *httpServer) fileSystem(w http.ResponseWriter, r *http.Request) {
 		}
 
 		entries = slices.DeleteFunc(entries, func(entry Entry) bool {
-			return entry.Name != ".." && s.isHiddenFile(entry.Path)
+			return entry.Name != ".." && media.IsHidden(entry.Path)
 		})
 	}
 
@@ -192,7 +375,7 @@ func (s *httpServer) fileSystem(w http.ResponseWriter, r *http.Request) {
 		if !a.IsDir && b.IsDir {
 			return 1
 		}
-		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		return media.Compare(a.Name, b.Name)
 	})
 
 	for i, entry := range entries {
@@ -211,8 +394,12 @@ func (s *httpServer) fileSystem(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *httpServer) handleError(w http.ResponseWriter, err error) {
-	slog.Error("failed to handle http", "err", err)
-	w.WriteHeader(http.StatusBadRequest)
+	s.handleErrorStatus(w, http.StatusBadRequest, err)
+}
+
+func (s *httpServer) handleErrorStatus(w http.ResponseWriter, status int, err error) {
+	slog.Error("failed to handle http", "err", err, "status", status)
+	w.WriteHeader(status)
 	w.Write([]byte(err.Error()))
 }
 
@@ -224,21 +411,3 @@ func (s *httpServer) writeJSON(w http.ResponseWriter, data any) {
 	}
 	w.Write(output)
 }
-
-func (s *httpServer) isHiddenFile(path string) bool {
-	pathW, err := syscall.UTF16PtrFromString(path)
-	if err != nil {
-		slog.Error("failed to convert path to utf16", "err", err)
-		return false
-	}
-
-	attrs, err := syscall.GetFileAttributes(pathW)
-	if err != nil {
-		slog.Error("failed to get win32 file attributes", "err", err)
-		return false
-	}
-
-	hidden := (attrs & syscall.FILE_ATTRIBUTE_HIDDEN) != 0
-
-	return hidden
-}
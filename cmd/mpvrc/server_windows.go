@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// fileSystemRoots returns names of all available drives.
+func fileSystemRoots() []string {
+	var roots []string
+	for _, drive := range "ABCDEFGHIJKLMNOPQRSTUVWXYZ" {
+		drivePath := fmt.Sprintf("%c:/", drive)
+		if _, err := os.Stat(drivePath); err == nil {
+			roots = append(roots, drivePath)
+		}
+	}
+	return roots
+}
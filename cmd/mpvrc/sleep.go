@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/mpv"
+)
+
+var errNoSleepTimer = errors.New("sleep timer is not set")
+
+// sleepAction is what sleep timer does when it runs out.
+type sleepAction string
+
+const (
+	sleepPause sleepAction = "pause"
+	sleepStop  sleepAction = "stop"
+	// sleepQuit exits mpv and mpvrc with it.
+	sleepQuit sleepAction = "quit"
+)
+
+// sleepActions maps actions to commands which they send to mpv, policy must allow the command to set timer.
+var sleepActions = map[sleepAction][]any{
+	sleepPause: {"set_property", "pause", true},
+	sleepStop:  {"stop"},
+	sleepQuit:  {"quit"},
+}
+
+// sleepNotices is remaining time at which countdown is shown in mpv OSD.
+var sleepNotices = []time.Duration{10 * time.Minute, 5 * time.Minute, time.Minute, 30 * time.Second, 10 * time.Second}
+
+// sleepTimer runs action after a timeout, or when some files finish playing if After is zero.
+type sleepTimer struct {
+	Action sleepAction
+	After  time.Duration
+	// Files is number of files which must play to the end, the playing file is the first one.
+	Files int
+	// FadeOut lowers volume before the action. Volume is restored after playback is paused or stopped.
+	FadeOut bool
+}
+
+// sleepTimerStatus is sent to listeners as "sleep-timer" global property, it is null when timer is not set.
+type sleepTimerStatus struct {
+	Action sleepAction `json:"action"`
+	// Deadline is set for timers with timeout.
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// Files is number of files left to play to the end for timers without timeout.
+	Files int `json:"files,omitempty"`
+	// Remaining is seconds left until the action, null if it's not known yet.
+	Remaining *float64 `json:"remaining"`
+	FadeOut   bool     `json:"fadeOut"`
+}
+
+// sleepState is guarded by App.m.
+type sleepState struct {
+	// tick is how often countdown and fade-out are updated.
+	tick time.Duration
+	// fade is how long volume is lowered before the action.
+	fade  time.Duration
+	timer *activeSleepTimer
+	// fading is timer which lowered volume until the volume is restored, changes of volume are not
+	// remembered for audio device then. It stays set after quitting, so the lowered volume is never remembered.
+	fading *activeSleepTimer
+}
+
+type activeSleepTimer struct {
+	sleepTimer
+	// deadline is zero for timers without timeout.
+	deadline time.Time
+	// volume is volume before fade-out started, nil if it didn't start yet.
+	volume *float64
+	// notice is the last countdown shown in OSD, zero if none.
+	notice time.Duration
+	// done is closed when timer runs out or is cancelled.
+	done chan struct{}
+	// fading waits for the volume change of fade-out which is being sent, so it doesn't override restored volume.
+	fading sync.WaitGroup
+}
+
+// SetSleepTimer replaces sleep timer with timer.
+func (app *App) SetSleepTimer(timer sleepTimer) {
+	app.m.Lock()
+	defer app.m.Unlock()
+
+	app.stopSleepTimer()
+
+	t := &activeSleepTimer{sleepTimer: timer, done: make(chan struct{})}
+	now := time.Now()
+	if timer.After > 0 {
+		t.deadline = now.Add(timer.After)
+	}
+	// Countdown which is already passed is not shown.
+	if remaining, ok := app.sleepRemaining(t, now); ok {
+		t.notice = sleepNotice(remaining)
+	}
+	app.sleep.timer = t
+	go app.runSleepTimer(t)
+
+	app.sendEvent(app.makeGlobalPropertyEvent("sleep-timer", app.sleepTimerStatus(now)))
+	app.showText("Sleep timer: " + describeSleepTimer(timer))
+}
+
+// SleepTimer returns status of sleep timer, or errNoSleepTimer if it's not set.
+func (app *App) SleepTimer() (*sleepTimerStatus, error) {
+	app.m.Lock()
+	defer app.m.Unlock()
+
+	status := app.sleepTimerStatus(time.Now())
+	if status == nil {
+		return nil, errNoSleepTimer
+	}
+	return status, nil
+}
+
+// CancelSleepTimer stops sleep timer and restores volume if it was fading out.
+func (app *App) CancelSleepTimer() error {
+	app.m.Lock()
+	defer app.m.Unlock()
+
+	if app.sleep.timer == nil {
+		return errNoSleepTimer
+	}
+	app.stopSleepTimer()
+	app.sendEvent(app.makeGlobalPropertyEvent("sleep-timer", nil))
+	app.showText("Sleep timer cancelled")
+	return nil
+}
+
+// stopSleepTimer forgets sleep timer and restores volume, it must be called with app.m locked.
+func (app *App) stopSleepTimer() {
+	t := app.sleep.timer
+	if t == nil {
+		return
+	}
+	app.sleep.timer = nil
+	close(t.done)
+
+	if t.volume == nil {
+		return
+	}
+	if app.mpv == nil {
+		app.endSleepFade(t)
+		return
+	}
+	conn, volume := app.mpv, *t.volume
+	go func() {
+		t.fading.Wait()
+		ctx, cancel := context.WithTimeout(conn.Context(), app.server.commandTimeout)
+		defer cancel()
+		if err := conn.SetVolume(ctx, volume); err != nil {
+			slog.Error("failed to restore volume", "err", err)
+		}
+
+		app.m.Lock()
+		defer app.m.Unlock()
+		app.endSleepFade(t)
+	}()
+}
+
+// endSleepFade lets changes of volume be remembered again after volume lowered by t is restored.
+// It must be called with app.m locked.
+func (app *App) endSleepFade(t *activeSleepTimer) {
+	if app.sleep.fading == t {
+		app.sleep.fading = nil
+	}
+}
+
+// sleepTimerStatus must be called with app.m locked.
+func (app *App) sleepTimerStatus(now time.Time) *sleepTimerStatus {
+	t := app.sleep.timer
+	if t == nil {
+		return nil
+	}
+	status := &sleepTimerStatus{Action: t.Action, Files: t.Files, FadeOut: t.FadeOut}
+	if !t.deadline.IsZero() {
+		status.Deadline = &t.deadline
+	}
+	if remaining, ok := app.sleepRemaining(t, now); ok {
+		seconds := max(remaining, 0).Seconds()
+		status.Remaining = &seconds
+	}
+	return status
+}
+
+// sleepRemaining returns time left until timer runs out. Time is only known for timers with timeout and
+// for timers which wait for the playing file only. It must be called with app.m locked.
+func (app *App) sleepRemaining(t *activeSleepTimer, now time.Time) (time.Duration, bool) {
+	if !t.deadline.IsZero() {
+		return t.deadline.Sub(now), true
+	}
+	if t.Files != 1 || !app.watch.loaded {
+		return 0, false
+	}
+
+	var position, duration *float64
+	speed := 1.0
+	for name, value := range map[string]any{"playback-time": &position, "duration": &duration, "speed": &speed} {
+		if err := json.Unmarshal(app.globals.properties[name], value); err != nil {
+			slog.Error("failed to unmarshal property", "name", name, "err", err)
+			return 0, false
+		}
+	}
+	if position == nil || duration == nil || speed <= 0 {
+		return 0, false
+	}
+	return time.Duration((*duration - *position) / speed * float64(time.Second)), true
+}
+
+func (app *App) runSleepTimer(t *activeSleepTimer) {
+	ticker := time.NewTicker(app.sleep.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case now := <-ticker.C:
+			var fade func()
+			app.m.Lock()
+			if app.sleep.timer == t {
+				fade = app.updateSleepTimer(t, now)
+			}
+			app.m.Unlock()
+			// Volume is changed here instead of in background, so fade-out steps are sent in order.
+			if fade != nil {
+				fade()
+			}
+		}
+	}
+}
+
+// updateSleepTimer shows countdown and runs out timer with timeout. It returns function which lowers volume
+// if timer is fading out, the function must be called with app.m unlocked. It must be called with app.m locked.
+func (app *App) updateSleepTimer(t *activeSleepTimer, now time.Time) (fade func()) {
+	remaining, ok := app.sleepRemaining(t, now)
+	if !ok {
+		return nil
+	}
+	// Timers without timeout run out when the file ends.
+	if remaining <= 0 && !t.deadline.IsZero() {
+		app.fireSleepTimer()
+		return nil
+	}
+
+	if notice := sleepNotice(remaining); notice != 0 && (t.notice == 0 || notice < t.notice) {
+		t.notice = notice
+		app.showText(fmt.Sprintf("Sleep timer: %s in %s", sleepActionVerbs[t.Action], formatSleepRemaining(remaining)))
+	}
+
+	if !t.FadeOut || remaining >= app.sleep.fade || app.mpv == nil {
+		return nil
+	}
+	if t.volume == nil {
+		var volume float64
+		if err := json.Unmarshal(app.globals.properties["volume"], &volume); err != nil {
+			slog.Error("failed to unmarshal volume", "err", err)
+			return nil
+		}
+		t.volume = &volume
+		app.sleep.fading = t
+	}
+	// Volume is set to what it should be at the next tick, so the first step is already lower and the step
+	// before the action is silent.
+	volume := math.Floor(*t.volume*max(remaining-app.sleep.tick, 0).Seconds()/app.sleep.fade.Seconds()*10) / 10
+
+	conn := app.mpv
+	t.fading.Add(1)
+	return func() {
+		defer t.fading.Done()
+		ctx, cancel := context.WithTimeout(conn.Context(), app.server.commandTimeout)
+		defer cancel()
+		if err := conn.SetVolume(ctx, volume); err != nil {
+			slog.Error("failed to fade out volume", "err", err)
+		}
+	}
+}
+
+// handleSleepTimerEvent counts files which were played to the end, it must be called with app.m locked.
+func (app *App) handleSleepTimerEvent(event mpv.EndFile) {
+	t := app.sleep.timer
+	if t == nil || !t.deadline.IsZero() || event.Reason != mpv.EndFileReasonEOF {
+		return
+	}
+	t.Files--
+	if t.Files <= 0 {
+		app.fireSleepTimer()
+		return
+	}
+	t.notice = 0
+	app.sendEvent(app.makeGlobalPropertyEvent("sleep-timer", app.sleepTimerStatus(time.Now())))
+}
+
+// fireSleepTimer runs action of sleep timer in background, it must be called with app.m locked.
+func (app *App) fireSleepTimer() {
+	t := app.sleep.timer
+	app.sleep.timer = nil
+	close(t.done)
+	app.sendEvent(app.makeGlobalPropertyEvent("sleep-timer", nil))
+
+	if app.mpv == nil {
+		slog.Error("failed to run sleep timer", "action", t.Action, "err", errNotConnected)
+		app.endSleepFade(t)
+		return
+	}
+	conn := app.mpv
+	go func() {
+		t.fading.Wait()
+		ctx, cancel := context.WithTimeout(conn.Context(), app.server.commandTimeout)
+		defer cancel()
+		err := runSleepAction(ctx, conn, t.Action, t.volume)
+		if err != nil {
+			slog.Error("failed to run sleep timer", "action", t.Action, "err", err)
+		}
+		// Volume is not restored when mpv quits.
+		if t.Action != sleepQuit || err != nil {
+			app.m.Lock()
+			defer app.m.Unlock()
+			app.endSleepFade(t)
+		}
+	}()
+}
+
+// runSleepAction runs action and restores volume to the one before fade-out, if it's not nil.
+func runSleepAction(ctx context.Context, conn *mpv.Conn, action sleepAction, volume *float64) error {
+	var err error
+	switch action {
+	case sleepPause:
+		err = conn.Pause(ctx)
+	case sleepStop:
+		err = conn.Stop(ctx)
+	case sleepQuit:
+		return conn.Quit(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	if volume != nil {
+		if err := conn.SetVolume(ctx, *volume); err != nil {
+			return err
+		}
+	}
+	return conn.ShowText(ctx, "Sleep timer: "+sleepActionResults[action])
+}
+
+// showText shows text in mpv OSD in background, it must be called with app.m locked.
+func (app *App) showText(text string) {
+	if app.mpv == nil {
+		return
+	}
+	conn := app.mpv
+	go func() {
+		ctx, cancel := context.WithTimeout(conn.Context(), app.server.commandTimeout)
+		defer cancel()
+		if err := conn.ShowText(ctx, text); err != nil {
+			slog.Error("failed to show text", "text", text, "err", err)
+		}
+	}()
+}
+
+var sleepActionResults = map[sleepAction]string{
+	sleepPause: "paused",
+	sleepStop:  "stopped",
+}
+
+var sleepActionVerbs = map[sleepAction]string{
+	sleepPause: "pausing",
+	sleepStop:  "stopping",
+	sleepQuit:  "quitting",
+}
+
+// sleepNotice returns the shortest countdown from sleepNotices which is not shorter than remaining, or zero.
+func sleepNotice(remaining time.Duration) time.Duration {
+	var notice time.Duration
+	for _, d := range sleepNotices {
+		if remaining <= d {
+			notice = d
+		}
+	}
+	return notice
+}
+
+// formatSleepRemaining formats d rounded up to minutes, or to seconds if it's shorter than a minute.
+func formatSleepRemaining(d time.Duration) string {
+	if d > 59*time.Second {
+		return plural(int(math.Ceil(d.Minutes())), "minute")
+	}
+	return plural(int(math.Ceil(d.Seconds())), "second")
+}
+
+func describeSleepTimer(timer sleepTimer) string {
+	action := string(timer.Action)
+	switch {
+	case timer.After > 0:
+		return fmt.Sprintf("%s in %s", action, formatSleepRemaining(timer.After))
+	case timer.Files == 1:
+		return action + " at end of file"
+	default:
+		return fmt.Sprintf("%s after %s", action, plural(timer.Files, "file"))
+	}
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/mpv/mpvtest"
+	"github.com/miere43/mpvrc/internal/policy"
+)
+
+func (s *appSuite) TestSleepTimerAfterTimeout() {
+	r := s.Require()
+	s.app.m.Lock()
+	s.app.sleep = sleepState{tick: 5 * time.Millisecond, fade: 200 * time.Millisecond}
+	s.app.m.Unlock()
+
+	events := s.listen()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+
+	s.fake.SetProperty("volume", 80.0)
+	s.waitForEvent(events, "volume", 80.0)
+
+	r.Equal(http.StatusNoContent, s.api(srv, "PUT", "/api/sleep-timer", `{"action": "pause", "minutes": 0.005, "fadeOut": true}`).StatusCode)
+	s.waitForEventFunc(events, "sleep-timer", func(value any) bool { return value != nil })
+
+	response := s.api(srv, "GET", "/api/sleep-timer", "")
+	r.Equal(http.StatusOK, response.StatusCode)
+	var status sleepTimerStatus
+	r.NoError(json.NewDecoder(response.Body).Decode(&status))
+	s.Equal(sleepPause, status.Action)
+	s.True(status.FadeOut)
+	r.NotNil(status.Deadline)
+	r.NotNil(status.Remaining)
+	s.LessOrEqual(*status.Remaining, 0.3)
+
+	s.waitForEvent(events, "sleep-timer", nil)
+	s.Eventually(func() bool { return s.commandCount("show-text", "Sleep timer: paused") == 1 }, time.Second, time.Millisecond)
+	s.Equal(true, s.fake.Property("pause"))
+	s.Equal(80.0, s.fake.Property("volume"))
+
+	// Volume was lowered to zero before playback was paused and restored after it.
+	var faded []float64
+	paused := false
+	for _, command := range s.fake.Commands() {
+		switch {
+		case len(command) == 3 && command[0] == "set_property" && command[1] == "pause":
+			paused = true
+		case len(command) == 3 && command[0] == "set_property" && command[1] == "volume":
+			volume := command[2].(float64)
+			if paused {
+				s.Equal(80.0, volume)
+				continue
+			}
+			if len(faded) > 0 {
+				s.LessOrEqual(volume, faded[len(faded)-1])
+			}
+			faded = append(faded, volume)
+		}
+	}
+	r.True(paused)
+	r.GreaterOrEqual(len(faded), 2)
+	s.Less(faded[0], 80.0)
+	s.Equal(0.0, faded[len(faded)-1])
+
+	response = s.api(srv, "GET", "/api/sleep-timer", "")
+	s.Equal(http.StatusNotFound, response.StatusCode)
+}
+
+func (s *appSuite) TestSleepTimerAfterFiles() {
+	r := s.Require()
+	events := s.listen()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+
+	r.Equal(http.StatusNoContent, s.api(srv, "PUT", "/api/sleep-timer", `{"action": "stop", "files": 2}`).StatusCode)
+	s.waitForEventFunc(events, "sleep-timer", func(value any) bool { return value != nil })
+	s.Eventually(func() bool { return s.commandCount("show-text", "Sleep timer: stop after 2 files") == 1 }, time.Second, time.Millisecond)
+
+	// Files which were stopped before the end are not counted.
+	s.fake.Emit("end-file", map[string]any{"reason": "stop", "playlist_entry_id": 1})
+	s.fake.Emit("end-file", map[string]any{"reason": "eof", "playlist_entry_id": 2})
+	s.waitForEventFunc(events, "sleep-timer", func(value any) bool {
+		status, _ := value.(map[string]any)
+		return status["files"] == 1.0
+	})
+	s.Zero(s.commandCount("stop", nil))
+
+	s.fake.Emit("end-file", map[string]any{"reason": "eof", "playlist_entry_id": 3})
+	s.waitForEvent(events, "sleep-timer", nil)
+	s.Eventually(func() bool { return s.commandCount("stop", nil) == 1 }, time.Second, time.Millisecond)
+}
+
+func (s *appSuite) TestSleepTimerQuit() {
+	r := s.Require()
+	s.app.m.Lock()
+	s.app.sleep = sleepState{tick: 5 * time.Millisecond, fade: 200 * time.Millisecond}
+	s.app.m.Unlock()
+	s.app.config.Audio.RememberVolume = true
+	volumesPath := filepath.Join(s.T().TempDir(), "audio-volumes.json")
+	r.NoError(s.app.loadAudioVolumes(volumesPath))
+	cfg := s.app.config.Policy
+	cfg.Commands = append(slices.Clone(cfg.Commands), "quit")
+	s.app.server.policy = policy.New(cfg)
+
+	events := s.listen()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+	s.waitForEvent(events, "audio-device", "auto")
+	s.fake.SetProperty("volume", 80.0)
+	s.waitForEvent(events, "volume", 80.0)
+
+	r.Equal(http.StatusNoContent, s.api(srv, "PUT", "/api/sleep-timer", `{"action": "quit", "minutes": 0.005, "fadeOut": true}`).StatusCode)
+	s.waitForEventFunc(events, "sleep-timer", func(value any) bool { return value != nil })
+	s.Eventually(func() bool { return s.commandCount("quit", nil) == 1 }, time.Second, time.Millisecond)
+	s.Equal(0.0, s.fake.Property("volume"))
+
+	// Volume of the device is the one before fade-out, so the next session doesn't start silent.
+	s.app.flushAudioVolumes()
+	data, err := os.ReadFile(volumesPath)
+	r.NoError(err)
+	var volumes map[string]float64
+	r.NoError(json.Unmarshal(data, &volumes))
+	s.Equal(80.0, volumes["auto"])
+}
+
+func (s *appSuite) TestCancelSleepTimer() {
+	r := s.Require()
+	events := s.listen()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+
+	r.Equal(http.StatusNoContent, s.api(srv, "PUT", "/api/sleep-timer", `{"action": "pause", "endOfFile": true}`).StatusCode)
+	s.waitForEventFunc(events, "sleep-timer", func(value any) bool { return value != nil })
+
+	// Timer is part of the state which is sent to listeners when they connect again.
+	var snapshot any
+	for _, event := range s.app.StartupEvents() {
+		if event.PropertyName == "sleep-timer" {
+			snapshot = event.Value
+		}
+	}
+	s.Equal(&sleepTimerStatus{Action: sleepPause, Files: 1}, snapshot)
+
+	r.Equal(http.StatusNoContent, s.api(srv, "DELETE", "/api/sleep-timer", "").StatusCode)
+	s.waitForEvent(events, "sleep-timer", nil)
+	response := s.api(srv, "DELETE", "/api/sleep-timer", "")
+	r.Equal(http.StatusNotFound, response.StatusCode)
+	s.Equal("not_found", s.decodeAPIError(response).Code)
+
+	s.fake.Emit("end-file", map[string]any{"reason": "eof", "playlist_entry_id": 1})
+	s.never(func(fake *mpvtest.Server) bool { return fake.Property("pause") == true })
+}
+
+func (s *appSuite) TestSetSleepTimerErrors() {
+	srv := s.startHTTP()
+
+	for body, status := range map[string]int{
+		`{"minutes": 30}`:                                       http.StatusBadRequest,
+		`{"action": "sleep", "minutes": 30}`:                    http.StatusBadRequest,
+		`{"action": "pause"}`:                                   http.StatusBadRequest,
+		`{"action": "pause", "minutes": 0}`:                     http.StatusBadRequest,
+		`{"action": "pause", "minutes": 5000}`:                  http.StatusBadRequest,
+		`{"action": "pause", "files": 0}`:                       http.StatusBadRequest,
+		`{"action": "pause", "minutes": 30, "files": 2}`:        http.StatusBadRequest,
+		`{"action": "pause", "minutes": 30, "endOfFile": true}`: http.StatusBadRequest,
+		// quit command is not allowed by the default policy.
+		`{"action": "quit", "minutes": 30}`: http.StatusForbidden,
+	} {
+		s.Equal(status, s.api(srv, "PUT", "/api/sleep-timer", body).StatusCode, body)
+	}
+	s.Equal(http.StatusNotFound, s.api(srv, "GET", "/api/sleep-timer", "").StatusCode)
+}
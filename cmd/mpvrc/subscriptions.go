@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/mpv"
+)
+
+// propertyChangeEvent is event of property which listeners have subscribed to with SubscribeProperties.
+const propertyChangeEvent = "property-change"
+
+// subscribedProperty is mpv property which is observed while at least one listener is subscribed to it.
+type subscribedProperty struct {
+	refs  int
+	value json.RawMessage
+	// conn is connection on which property is observed with observation id, nil if it is not observed yet.
+	conn *mpv.Conn
+	id   int32
+}
+
+// isGlobalProperty reports whether property is always sent to all listeners, so there is no need to subscribe to it.
+func (app *App) isGlobalProperty(name string) bool {
+	switch name {
+	case "connected", "connection-state", "ready", "resume", "intro", "auto-skip-intro", "sleep-timer":
+		return true
+	}
+	_, ok := app.globals.properties[name]
+	return ok
+}
+
+// IsGlobalProperty reports whether property is always sent to all listeners.
+func (app *App) IsGlobalProperty(name string) bool {
+	app.m.Lock()
+	defer app.m.Unlock()
+	return app.isGlobalProperty(name)
+}
+
+// SubscribeProperties sends changes of mpv properties to listener, starting with their current values.
+// Property is observed in mpv while at least one listener is subscribed to it. Global properties are ignored.
+func (app *App) SubscribeProperties(ctx context.Context, listener *AppEventListener, names []string) error {
+	app.subscriptionsM.Lock()
+	defer app.subscriptionsM.Unlock()
+
+	app.m.Lock()
+	var observe []string
+	now := time.Now()
+	for _, name := range names {
+		if app.isGlobalProperty(name) || listener.properties[name] {
+			continue
+		}
+		listener.properties[name] = true
+
+		sub := app.subscriptions[name]
+		if sub == nil {
+			sub = &subscribedProperty{}
+			app.subscriptions[name] = sub
+		}
+		sub.refs++
+		if sub.refs == 1 {
+			observe = append(observe, name)
+		} else if sub.value != nil {
+			app.pushEvent(listener, app.makePropertyChangeEvent(name, sub.value), now)
+		}
+	}
+	conn := app.mpv
+	app.m.Unlock()
+
+	if conn == nil {
+		return nil // Properties are observed after connecting to mpv.
+	}
+	for _, name := range observe {
+		if err := app.observeProperty(ctx, conn, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnsubscribeProperties stops sending changes of properties to listener.
+func (app *App) UnsubscribeProperties(ctx context.Context, listener *AppEventListener, names []string) {
+	app.subscriptionsM.Lock()
+	defer app.subscriptionsM.Unlock()
+
+	app.m.Lock()
+	var unobserve []*subscribedProperty
+	for _, name := range names {
+		if !listener.properties[name] {
+			continue
+		}
+		delete(listener.properties, name)
+
+		sub := app.subscriptions[name]
+		sub.refs--
+		if sub.refs == 0 {
+			delete(app.subscriptions, name)
+			if sub.conn != nil {
+				unobserve = append(unobserve, sub)
+			}
+		}
+	}
+	app.m.Unlock()
+
+	for _, sub := range unobserve {
+		if err := sub.conn.UnobserveProperty(ctx, sub.id); err != nil && sub.conn.Context().Err() == nil {
+			slog.Warn("failed to unobserve property", "id", sub.id, "err", err)
+		}
+	}
+}
+
+// observeSubscribedProperties observes properties which were subscribed to before conn was established.
+func (app *App) observeSubscribedProperties(ctx context.Context, conn *mpv.Conn) {
+	app.subscriptionsM.Lock()
+	defer app.subscriptionsM.Unlock()
+
+	app.m.Lock()
+	var names []string
+	for name, sub := range app.subscriptions {
+		if sub.conn != conn {
+			names = append(names, name)
+		}
+	}
+	app.m.Unlock()
+
+	for _, name := range names {
+		if err := app.observeProperty(ctx, conn, name); err != nil {
+			slog.Warn("failed to observe subscribed property", "name", name, "err", err)
+		}
+	}
+}
+
+// observeProperty must be called with app.subscriptionsM locked.
+func (app *App) observeProperty(ctx context.Context, conn *mpv.Conn, name string) error {
+	id, err := conn.ObservePropertyContext(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	app.m.Lock()
+	defer app.m.Unlock()
+	if sub := app.subscriptions[name]; sub != nil {
+		sub.conn = conn
+		sub.id = id
+	}
+	return nil
+}
+
+// setSubscribedPropertyValue sends new value to subscribers, it must be called with app.m locked.
+func (app *App) setSubscribedPropertyValue(name string, value json.RawMessage) {
+	sub := app.subscriptions[name]
+	if sub == nil {
+		return // Nobody is subscribed anymore.
+	}
+	if value == nil {
+		value = json.RawMessage("null")
+	}
+	if string(sub.value) == string(value) {
+		return
+	}
+	sub.value = value
+	app.sendEvent(app.makePropertyChangeEvent(name, value))
+}
+
+func (app *App) makePropertyChangeEvent(name string, value any) propertyEvent {
+	return propertyEvent{
+		Event:        propertyChangeEvent,
+		PropertyName: name,
+		Value:        value,
+	}
+}
+
+// subscribedPropertyEvents returns current values of properties listener is subscribed to.
+func (app *App) subscribedPropertyEvents(listener *AppEventListener) []propertyEvent {
+	var events []propertyEvent
+	for _, name := range slices.Sorted(maps.Keys(listener.properties)) {
+		if sub := app.subscriptions[name]; sub != nil && sub.value != nil {
+			events = append(events, app.makePropertyChangeEvent(name, sub.value))
+		}
+	}
+	return events
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+const (
+	connectionStateConnecting = "connecting"
+	connectionStateConnected  = "connected"
+	connectionStateFailed     = "failed"
+)
+
+type connectionState struct {
+	State     string `json:"state"`
+	LastError string `json:"lastError,omitempty"`
+	Attempt   int    `json:"attempt,omitempty"`
+}
+
+// superviseMPV keeps connection to mpv alive. It redials with exponential backoff whenever
+// the connection is lost and replays full state to event listeners after reconnecting.
+func (app *App) superviseMPV() {
+	delay := app.reconnectMinDelay
+	attempt := 0
+	lastError := ""
+
+	for {
+		attempt++
+		app.setConnectionState(connectionState{State: connectionStateConnecting, LastError: lastError, Attempt: attempt})
+
+		err := app.ConnectToMPV(app.reconnectMinDelay)
+		if err != nil {
+			lastError = err.Error()
+			slog.Warn("failed to connect to mpv", "attempt", attempt, "retryIn", delay, "err", err)
+			app.setConnectionState(connectionState{State: connectionStateFailed, LastError: lastError, Attempt: attempt})
+
+			select {
+			case <-time.After(delay):
+			case <-app.quitApp:
+				return
+			}
+
+			delay = min(delay*2, app.reconnectMaxDelay)
+			continue
+		}
+
+		conn, err := app.MPV()
+		if err != nil {
+			continue // Connection was lost right after it was established.
+		}
+
+		slog.Info("connected to mpv", "attempt", attempt)
+		delay = app.reconnectMinDelay
+		attempt = 0
+		lastError = ""
+
+		app.setConnectionState(connectionState{State: connectionStateConnected})
+		app.broadcastStartupEvents()
+
+		select {
+		case <-conn.Context().Done():
+			slog.Warn("lost connection to mpv, reconnecting...")
+			app.handleDisconnect(conn)
+		case <-app.quitApp:
+			return
+		}
+	}
+}
+
+func (app *App) setConnectionState(state connectionState) {
+	app.m.Lock()
+	defer app.m.Unlock()
+
+	app.connState = state
+	app.sendEvent(app.makeGlobalPropertyEvent("connection-state", state))
+}
+
+func (app *App) ConnectionState() connectionState {
+	app.m.Lock()
+	defer app.m.Unlock()
+
+	return app.connState
+}
+
+// broadcastStartupEvents sends the same state snapshot to all listeners as new listeners receive.
+func (app *App) broadcastStartupEvents() {
+	app.m.Lock()
+	defer app.m.Unlock()
+
+	for _, event := range app.startupEvents() {
+		app.sendEvent(event)
+	}
+}
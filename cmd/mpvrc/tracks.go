@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/miere43/mpvrc/internal/mpv"
+)
+
+// trackSelection is IDs of selected tracks, nil means track of that type is turned off.
+type trackSelection struct {
+	Video        *int `json:"video"`
+	Audio        *int `json:"audio"`
+	Sub          *int `json:"sub"`
+	SecondarySub *int `json:"secondarySub"`
+}
+
+// selectedTracks reads selected tracks from global properties, it must be called with app.m locked.
+func (app *App) selectedTracks() trackSelection {
+	var selection trackSelection
+	for trackType, id := range map[mpv.TrackType]**int{
+		mpv.TrackVideo:        &selection.Video,
+		mpv.TrackAudio:        &selection.Audio,
+		mpv.TrackSub:          &selection.Sub,
+		mpv.TrackSecondarySub: &selection.SecondarySub,
+	} {
+		property, _ := mpv.TrackProperty(trackType)
+		// mpv reports turned off track as false, which doesn't unmarshal into int.
+		var value int
+		if json.Unmarshal(app.globals.properties[property], &value) == nil {
+			*id = &value
+		}
+	}
+	return selection
+}
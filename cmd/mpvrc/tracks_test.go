@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+func (s *appSuite) TestTracks() {
+	r := s.Require()
+	events := s.listen()
+	r.NoError(s.app.ConnectToMPV(time.Second))
+	srv := s.startHTTP()
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/playback/load", `{"path": "/video/a.mkv"}`).StatusCode)
+	s.fake.SetProperty("track-list", []any{
+		map[string]any{"id": 1, "type": "video", "selected": true},
+		map[string]any{"id": 1, "type": "sub", "lang": "eng", "selected": true},
+		map[string]any{"id": 2, "type": "sub", "lang": "jpn"},
+	})
+	s.fake.SetProperty("vid", 1.0)
+	s.fake.SetProperty("sid", 1.0)
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/tracks/secondary-sub/2", "").StatusCode)
+	s.waitForEvent(events, "secondary-sid", 2.0)
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/tracks/add", `{"type": "audio", "path": "/video/a.commentary.mka"}`).StatusCode)
+	s.waitForEvent(events, "aid", 1.0)
+	s.Equal(1, s.commandCount("audio-add", "select"))
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/tracks/adjust", `{"subDelay": -0.5, "subPos": 90}`).StatusCode)
+	s.waitForEvent(events, "sub-delay", -0.5)
+	s.waitForEvent(events, "sub-pos", 90.0)
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/tracks/adjust", `{"audioDelay": 0.2, "subScale": 1.5}`).StatusCode)
+	s.waitForEvent(events, "sub-scale", 1.5)
+
+	response := s.api(srv, "GET", "/api/state", "")
+	r.Equal(http.StatusOK, response.StatusCode)
+	var state playbackState
+	r.NoError(json.NewDecoder(response.Body).Decode(&state))
+	s.Len(state.Tracks, 4)
+	s.True(state.Tracks[3].External)
+	r.NotNil(state.Selected.Video)
+	r.NotNil(state.Selected.Audio)
+	r.NotNil(state.Selected.Sub)
+	r.NotNil(state.Selected.SecondarySub)
+	s.Equal(1, *state.Selected.Video)
+	s.Equal(1, *state.Selected.Audio)
+	s.Equal(1, *state.Selected.Sub)
+	s.Equal(2, *state.Selected.SecondarySub)
+	s.Equal(-0.5, state.SubDelay)
+	s.Equal(0.2, state.AudioDelay)
+	s.Equal(1.5, state.SubScale)
+	s.Equal(90.0, state.SubPos)
+
+	r.Equal(http.StatusNoContent, s.api(srv, "POST", "/api/tracks/secondary-sub/no", "").StatusCode)
+	s.waitForEvent(events, "secondary-sid", "no")
+	s.Nil(s.app.PlaybackState().Selected.SecondarySub)
+
+	for _, test := range []struct {
+		path string
+		body string
+	}{
+		{"/api/tracks/add", `{"type": "video", "path": "/video/b.mkv"}`},
+		{"/api/tracks/add", `{"type": "sub", "path": "b.srt"}`},
+		{"/api/tracks/adjust", `{}`},
+		{"/api/tracks/adjust", `{"subScale": 0}`},
+		{"/api/tracks/adjust", `{"subPos": 200}`},
+		{"/api/tracks/adjust", `{"subDelay": "1s"}`},
+	} {
+		response := s.api(srv, "POST", test.path, test.body)
+		s.NotEqual(http.StatusNoContent, response.StatusCode, test.body)
+		s.Less(response.StatusCode, 500, test.body)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// wsRequest is sent by WebSocket client. Every request is answered with wsReply with the same ID.
+type wsRequest struct {
+	ID   json.RawMessage `json:"id"`
+	Type string          `json:"type"`
+	// Command is mpv command for "command" request.
+	Command []any `json:"command"`
+	// Properties are property names for "subscribe" and "unsubscribe" requests.
+	Properties []string `json:"properties"`
+}
+
+type wsReply struct {
+	ID     json.RawMessage `json:"id"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Status int             `json:"status"`
+}
+
+type wsSession struct {
+	s        *httpServer
+	conn     *websocket.Conn
+	listener *AppEventListener
+
+	m          sync.Mutex
+	subscribed map[string]bool
+}
+
+// websocket handles control channel which carries commands, replies and events of subscribed properties.
+func (s *httpServer) websocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		slog.Error("failed to accept WebSocket", "err", err)
+		return
+	}
+	defer conn.CloseNow()
+
+	token, _ := s.authenticate(r)
+	session := &wsSession{
+		s:          s,
+		conn:       conn,
+		listener:   s.app.NewEventListener(token.ID),
+		subscribed: make(map[string]bool),
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		session.forwardEvents(ctx)
+	}()
+
+	err = session.readRequests(ctx, r.RemoteAddr)
+	cancel()
+	<-done
+
+	if status := websocket.CloseStatus(err); status == websocket.StatusNormalClosure || status == websocket.StatusGoingAway {
+		slog.Debug("WebSocket closed", "remoteAddr", r.RemoteAddr)
+	} else if err != nil && !errors.Is(err, context.Canceled) {
+		slog.Warn("WebSocket failed", "remoteAddr", r.RemoteAddr, "err", err)
+	}
+}
+
+// forwardEvents sends events of subscribed properties until ctx is done or server shuts down.
+func (session *wsSession) forwardEvents(ctx context.Context) {
+	defer session.s.app.CloseEventListener(session.listener)
+
+	for {
+		select {
+		case <-session.listener.Ready():
+			for _, event := range session.listener.Take() {
+				if err := session.forwardEvent(ctx, event.Data); err != nil {
+					session.conn.CloseNow()
+					return
+				}
+			}
+
+		case <-session.listener.Done():
+			err := session.listener.Err()
+			slog.Warn("closing WebSocket", "err", err)
+			if errors.Is(err, errTokenRevoked) {
+				session.conn.Close(websocket.StatusPolicyViolation, "token was revoked")
+			} else {
+				session.conn.Close(websocket.StatusTryAgainLater, "too slow")
+			}
+			return
+
+		case <-ctx.Done():
+			return
+
+		case <-session.s.shutdownSSE:
+			session.conn.Close(websocket.StatusGoingAway, "server is shutting down")
+			return
+		}
+	}
+}
+
+func (session *wsSession) forwardEvent(ctx context.Context, eventJSON []byte) error {
+	var event propertyEvent
+	if err := json.Unmarshal(eventJSON, &event); err != nil {
+		slog.Error("failed to unmarshal event", "err", err)
+		return nil
+	}
+	if !session.isSubscribed(event.PropertyName) {
+		return nil
+	}
+	return session.conn.Write(ctx, websocket.MessageText, eventJSON)
+}
+
+func (session *wsSession) readRequests(ctx context.Context, remoteAddr string) error {
+	for {
+		var request wsRequest
+		if err := wsjson.Read(ctx, session.conn, &request); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				session.conn.Close(websocket.StatusUnsupportedData, "invalid request")
+			}
+			return err
+		}
+
+		reply := session.handleRequest(ctx, request, remoteAddr)
+		if err := wsjson.Write(ctx, session.conn, reply); err != nil {
+			return err
+		}
+	}
+}
+
+func (session *wsSession) handleRequest(ctx context.Context, request wsRequest, remoteAddr string) wsReply {
+	reply := wsReply{ID: request.ID, Status: http.StatusOK}
+
+	switch request.Type {
+	case "command":
+		data, status, err := session.s.runCommand(ctx, request.Command, remoteAddr)
+		reply.Data = data
+		reply.Status = status
+		if err != nil {
+			reply.Error = err.Error()
+		}
+
+	case "subscribe":
+		if err := session.s.checkProperties(request.Properties); err != nil {
+			reply.Status = http.StatusForbidden
+			reply.Error = err.Error()
+			break
+		}
+		added := session.setSubscribed(request.Properties, true)
+		if err := session.s.app.SubscribeProperties(ctx, session.listener, request.Properties); err != nil {
+			reply.Status = http.StatusBadGateway
+			reply.Error = err.Error()
+			break
+		}
+		// Values of other properties are sent by SubscribeProperties. They go through the same queue
+		// as other events, so they can't arrive after a newer value.
+		session.s.app.SendGlobalPropertyEvents(session.listener, added)
+
+	case "unsubscribe":
+		session.setSubscribed(request.Properties, false)
+		session.s.app.UnsubscribeProperties(ctx, session.listener, request.Properties)
+
+	default:
+		reply.Status = http.StatusBadRequest
+		reply.Error = fmt.Sprintf("unknown request type %q", request.Type)
+	}
+
+	return reply
+}
+
+// setSubscribed changes subscription to properties and returns properties which were not subscribed before.
+func (session *wsSession) setSubscribed(properties []string, subscribed bool) []string {
+	session.m.Lock()
+	defer session.m.Unlock()
+
+	var added []string
+	for _, property := range properties {
+		if subscribed {
+			if !session.subscribed[property] {
+				added = append(added, property)
+			}
+			session.subscribed[property] = true
+		} else {
+			delete(session.subscribed, property)
+		}
+	}
+	return added
+}
+
+func (session *wsSession) isSubscribed(property string) bool {
+	session.m.Lock()
+	defer session.m.Unlock()
+	return session.subscribed[property]
+}
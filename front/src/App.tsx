@@ -1,7 +1,8 @@
 import { createSignal, For, onCleanup, Setter, Show, type Component } from 'solid-js';
 
 import styles from './App.module.css';
-import { DurationInSeconds, formatDuration, formatTrack, Track } from './mpv';
+import { ControlChannel } from './control';
+import { AudioDevice, Chapter, DurationInSeconds, externalTrackType, fileName, formatChapter, formatDelay, formatDuration, formatPlaylistEntry, formatSleepTimer, formatTrack, HistoryEntry, PlaylistEntry, ResumeOffer, SleepTimer, Track, TrackType } from './mpv';
 
 interface SetGlobalPropertyBackendEvent {
     event: 'set-global-property';
@@ -11,8 +12,15 @@ interface SetGlobalPropertyBackendEvent {
 
 type BackendEvent = SetGlobalPropertyBackendEvent;
 
+interface ConnectionState {
+    state: 'connecting' | 'connected' | 'failed';
+    lastError?: string;
+    attempt?: number;
+}
+
 const App: Component<{ root: HTMLElement }> = ({ root }) => {
     const [connected, setConnected] = createSignal(false);
+    const [connectionState, setConnectionState] = createSignal<ConnectionState>({ state: 'connecting' });
     const [playbackTime, setPlaybackTime] = createSignal<DurationInSeconds | null>(null);
     const [duration, setDuration] = createSignal<DurationInSeconds | null>(null);
     const [pause, setPause] = createSignal(false);
@@ -21,20 +29,34 @@ const App: Component<{ root: HTMLElement }> = ({ root }) => {
     const [speed, setSpeed] = createSignal(1);
     const [ready, setReady] = createSignal(false);
     const [trackList, setTrackList] = createSignal<Track[] | null>(null);
-
-    function selectedSubtitleTrackFromTrackList(trackList: Track[] | null): string {
-        return formatTrack(trackList?.find(track => track.type === 'sub' && track.selected));
-    }
-
-    function selectedAudioTrackFromTrackList(trackList: Track[] | null): string {
-        return formatTrack(trackList?.find(track => track.type === 'audio' && track.selected));
-    }
-
-    const selectedSubtitleTrack = (): string => selectedSubtitleTrackFromTrackList(trackList());
-    const selectedAudioTrack = (): string => selectedAudioTrackFromTrackList(trackList());
+    const [playlist, setPlaylist] = createSignal<PlaylistEntry[] | null>(null);
+    const [resume, setResume] = createSignal<ResumeOffer | null>(null);
+    const [chapterList, setChapterList] = createSignal<Chapter[] | null>(null);
+    const [chapter, setChapter] = createSignal<number | null>(null);
+    const [intro, setIntro] = createSignal(false);
+    const [autoSkipIntro, setAutoSkipIntro] = createSignal(false);
+    const [audioDevice, setAudioDevice] = createSignal<string | null>(null);
+    const [audioDeviceList, setAudioDeviceList] = createSignal<AudioDevice[] | null>(null);
+    // Selected track IDs are false when tracks of that type are turned off.
+    const [aid, setAid] = createSignal<number | false>(false);
+    const [sid, setSid] = createSignal<number | false>(false);
+    const [secondarySid, setSecondarySid] = createSignal<number | false>(false);
+    const [subDelay, setSubDelay] = createSignal(0);
+    const [audioDelay, setAudioDelay] = createSignal(0);
+    const [subScale, setSubScale] = createSignal(1);
+    const [subPos, setSubPos] = createSignal(100);
+    const [sleepTimer, setSleepTimer] = createSignal<SleepTimer | null>(null);
+    const [sleepAction, setSleepAction] = createSignal<SleepTimer['action']>('pause');
+    const [sleepFadeOut, setSleepFadeOut] = createSignal(true);
+    // now is updated every second to count down sleep timer.
+    const [now, setNow] = createSignal(new Date());
+    const clock = setInterval(() => setNow(new Date()), 1000);
+
+    const tracksOfType = (type: 'audio' | 'sub'): Track[] => trackList()?.filter(track => track.type === type) ?? [];
 
     const globalProperties = new Map<string, Setter<unknown>>([
         ['connected', setConnected as any],
+        ['connection-state', setConnectionState as any],
         ['playback-time', setPlaybackTime],
         ['duration', setDuration],
         ['pause', setPause],
@@ -42,7 +64,23 @@ const App: Component<{ root: HTMLElement }> = ({ root }) => {
         ['path', setPath],
         ['speed', setSpeed],
         ['ready', setReady],
-        ['track-list', setTrackList]
+        ['track-list', setTrackList],
+        ['playlist', setPlaylist],
+        ['resume', setResume],
+        ['chapter-list', setChapterList],
+        ['chapter', setChapter],
+        ['intro', setIntro],
+        ['auto-skip-intro', setAutoSkipIntro],
+        ['audio-device', setAudioDevice],
+        ['audio-device-list', setAudioDeviceList],
+        ['aid', setAid],
+        ['sid', setSid],
+        ['secondary-sid', setSecondarySid],
+        ['sub-delay', setSubDelay],
+        ['audio-delay', setAudioDelay],
+        ['sub-scale', setSubScale],
+        ['sub-pos', setSubPos],
+        ['sleep-timer', setSleepTimer],
     ]);
 
     function setGlobalProperty(propertyName: string, value: any): void {
@@ -68,39 +106,40 @@ const App: Component<{ root: HTMLElement }> = ({ root }) => {
         }
     }
 
-    const eventSource = new EventSource('/events');
-    eventSource.onmessage = (event) => {
-        console.log('RECV', event.data)
+    const control = new ControlChannel([...globalProperties.keys()], (event) => {
+        console.log('RECV', event);
 
-        applyEvent(JSON.parse(event.data));
-    };
+        applyEvent(event);
+    });
 
     onCleanup(() => {
-        eventSource.close();
+        control.close();
         document.removeEventListener('fullscreenchange', onFullscreenChange);
+        clearInterval(clock);
     });
 
-    function command(args: any[]): Promise<Response> {
+    function command(args: any[]): Promise<any> {
         console.log('command args', args);
-        const body = new FormData();
-        body.append('command', JSON.stringify(args));
-        return fetch('/command', {
-            method: 'POST',
-            body: body,
-        });
+        return control.command(args);
     }
 
     async function seek(change: number): Promise<void> {
         await command(['seek', change, 'relative+exact']);
-        const playbackTimeResponse = await command(['get_property', 'playback-time']);
-        const playbackTime = await playbackTimeResponse.json();
+        const playbackTime: DurationInSeconds | null = await command(['get_property', 'playback-time']);
         await command(['show-text', formatDuration(playbackTime)]);
     }
 
     async function changeVolume(change: number): Promise<void> {
-        const newVolume = volume() + change;
-        await command(['set_property', 'volume', newVolume]);
-        await command(['show-text', `Volume: ${newVolume}%`]);
+        const newVolume = Math.max(volume() + change, 0);
+        // Volume is changed with API instead of a command, so it's remembered for the series.
+        const response = await fetch('/api/playback/volume', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ volume: newVolume }),
+        });
+        if (response.ok) {
+            await command(['show-text', `Volume: ${newVolume}%`]);
+        }
     };
 
     async function changeSpeed(change: number): Promise<void> {
@@ -138,6 +177,7 @@ const App: Component<{ root: HTMLElement }> = ({ root }) => {
 
     const [filePickerPath, setFilePickerPath] = createSignal('');
     const [filePickerEntries, setFilePickerEntries] = createSignal<FileSystemEntry[]>([]);
+    const [continueWatching, setContinueWatching] = createSignal<HistoryEntry[]>([]);
 
     let filePicker: HTMLDialogElement | undefined;
 
@@ -148,9 +188,145 @@ const App: Component<{ root: HTMLElement }> = ({ root }) => {
         setFilePickerPath(data.path);
         setFilePickerEntries(data.entries);
 
+        const historyResponse = await fetch('/api/history?unfinished=true&limit=5');
+        setContinueWatching(historyResponse.ok ? await historyResponse.json() : []);
+
         filePicker?.showModal();
     }
 
+    async function playFromHistory(entry: HistoryEntry): Promise<void> {
+        await command(['loadfile', entry.path]);
+        filePicker?.close();
+    }
+
+    async function skipIntro(): Promise<void> {
+        await fetch('/api/chapters/skip-intro', { method: 'POST' });
+    }
+
+    async function toggleAutoSkipIntro(): Promise<void> {
+        await fetch('/api/chapters/auto-skip-intro', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ enabled: !autoSkipIntro() }),
+        });
+    }
+
+    async function switchAudioDevice(name: string): Promise<void> {
+        await fetch('/api/audio/device', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ name }),
+        });
+    }
+
+    async function selectTrack(type: TrackType, id: string): Promise<void> {
+        await fetch(`/api/tracks/${type}/${id}`, { method: 'POST' });
+    }
+
+    async function addTrack(entry: FileSystemEntry, type: 'sub' | 'audio'): Promise<void> {
+        const response = await fetch('/api/tracks/add', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ type, path: entry.path }),
+        });
+        if (!response.ok) {
+            const error = await response.json();
+            await command(['show-text', `Failed to add ${entry.name}: ${error.error}`]);
+            return;
+        }
+        filePicker?.close();
+    }
+
+    // startSleepTimer sets sleep timer, e.g. { minutes: 30 } or { endOfFile: true }.
+    async function startSleepTimer(options: { minutes: number } | { endOfFile: true }): Promise<void> {
+        const response = await fetch('/api/sleep-timer', {
+            method: 'PUT',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ action: sleepAction(), fadeOut: sleepFadeOut(), ...options }),
+        });
+        if (!response.ok) {
+            const error = await response.json();
+            await command(['show-text', `Failed to set sleep timer: ${error.error}`]);
+        }
+    }
+
+    async function cancelSleepTimer(): Promise<void> {
+        await fetch('/api/sleep-timer', { method: 'DELETE' });
+    }
+
+    async function forgetSeriesPreferences(): Promise<void> {
+        const response = await fetch('/api/preferences?scope=directory', { method: 'DELETE' });
+        if (response.ok) {
+            await command(['show-text', 'Series preferences forgotten']);
+        }
+    }
+
+    // adjustTracks changes subtitle and audio options, e.g. { subDelay: 0.1 }.
+    async function adjustTracks(options: Record<string, number>, text: string): Promise<void> {
+        const response = await fetch('/api/tracks/adjust', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(options),
+        });
+        if (response.ok) {
+            await command(['show-text', text]);
+        }
+    }
+
+    function changeSubDelay(change: number): Promise<void> {
+        const delay = Math.round((subDelay() + change) * 10) / 10;
+        return adjustTracks({ subDelay: delay }, `Subtitle delay: ${formatDelay(delay)}`);
+    }
+
+    function changeAudioDelay(change: number): Promise<void> {
+        const delay = Math.round((audioDelay() + change) * 10) / 10;
+        return adjustTracks({ audioDelay: delay }, `Audio delay: ${formatDelay(delay)}`);
+    }
+
+    function changeSubScale(change: number): Promise<void> {
+        const scale = Math.max(0.1, Math.round((subScale() + change) * 10) / 10);
+        return adjustTracks({ subScale: scale }, `Subtitle scale: ${scale}`);
+    }
+
+    function changeSubPos(change: number): Promise<void> {
+        const pos = Math.min(150, Math.max(0, subPos() + change));
+        return adjustTracks({ subPos: pos }, `Subtitle position: ${pos}`);
+    }
+
+    async function resumePlayback(): Promise<void> {
+        setResume(null);
+        await fetch('/api/history/resume', { method: 'POST' });
+    }
+
+    async function queueFile(entry: FileSystemEntry): Promise<void> {
+        await command(['loadfile', entry.path, 'append-play']);
+        await command(['show-text', `Queued: ${entry.name}`]);
+    }
+
+    // playFolder replaces playlist with media files in dir, start is the file to play first.
+    async function playFolder(dir: string, start?: string): Promise<void> {
+        const response = await fetch('/api/playlist/folder', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ path: dir, start }),
+        });
+        if (!response.ok) {
+            const error = await response.json();
+            await command(['show-text', `Failed to play folder: ${error.error}`]);
+            return;
+        }
+        filePicker?.close();
+    }
+
+    async function movePlaylistEntry(index: number, change: number): Promise<void> {
+        const target = index + change;
+        if (target < 0 || target >= (playlist()?.length ?? 0)) {
+            return;
+        }
+        // mpv moves entry to the place of entry at the target index, so moving down has to skip one more entry.
+        await command(['playlist-move', index, change > 0 ? target + 1 : target]);
+    }
+
     async function pickFile(entry: FileSystemEntry): Promise<void> {
         if (entry.isDir) {
             const response = await fetch(`/file-system?path=${encodeURIComponent(entry.path)}`);
@@ -164,20 +340,6 @@ const App: Component<{ root: HTMLElement }> = ({ root }) => {
         }
     };
 
-    async function cycleSubtitleTrack(): Promise<void> {
-        await command(['cycle', 'sub']);
-        const response = await command(['get_property', 'track-list']);
-        const trackList: Track[] | null = await response.json();
-        await command(['show-text', `Subtitles: ${selectedSubtitleTrackFromTrackList(trackList)}`]);
-    }
-
-    async function cycleAudioTrack(): Promise<void> {
-        await command(['cycle', 'audio']);
-        const response = await command(['get_property', 'track-list']);
-        const trackList: Track[] | null = await response.json();
-        await command(['show-text', `Audio: ${selectedAudioTrackFromTrackList(trackList)}`]);
-    }
-
     return (
         <Show when={ready()}>
             <Show
@@ -186,9 +348,13 @@ const App: Component<{ root: HTMLElement }> = ({ root }) => {
                     <div class={styles.notConnected}>
                         mpv is not connected
 
-                        <form action="/" method="get">
-                            <button class={styles.connect} type="submit">Connect</button>
-                        </form>
+                        <div class={styles.connectionState}>
+                            {connectionState().state === 'failed' ? 'Reconnecting' : 'Connecting'}
+                            <Show when={connectionState().attempt}> (attempt {connectionState().attempt})</Show>...
+                        </div>
+                        <Show when={connectionState().lastError}>
+                            <div class={styles.connectionError}>{connectionState().lastError}</div>
+                        </Show>
                     </div>
                 }
             >
@@ -268,6 +434,21 @@ const App: Component<{ root: HTMLElement }> = ({ root }) => {
                     </div>
 
                     <dialog ref={filePicker}>
+                        <Show when={continueWatching().length > 0}>
+                            <h3 style="margin-top: 0">Continue watching</h3>
+                            <ul>
+                                <For each={continueWatching()}>{entry =>
+                                    <li>
+                                        <div
+                                            role="button"
+                                            class={styles.link}
+                                            onClick={event => { event.preventDefault(); playFromHistory(entry); }}
+                                        >{fileName(entry.path)}</div>
+                                        {' '}({formatDuration(entry.position)} / {formatDuration(entry.duration)})
+                                    </li>
+                                }</For>
+                            </ul>
+                        </Show>
                         <div>
                             <h3 style="margin-top: 0">{filePickerPath()}</h3>
                             <ul>
@@ -278,6 +459,34 @@ const App: Component<{ root: HTMLElement }> = ({ root }) => {
                                             class={styles.link}
                                             onClick={event => { event.preventDefault(); pickFile(entry); }}
                                         >{entry.name}</div>
+                                        <Show when={!entry.isDir}>
+                                            {' '}<div
+                                                role="button"
+                                                class={styles.link}
+                                                onClick={event => { event.preventDefault(); queueFile(entry); }}
+                                            >[+]</div>
+                                            {' '}<div
+                                                role="button"
+                                                class={styles.link}
+                                                onClick={event => { event.preventDefault(); playFolder(filePickerPath(), entry.path); }}
+                                            >[from here]</div>
+                                            <Show when={path() ? externalTrackType(entry.path) : null}>{type =>
+                                                <>
+                                                    {' '}<div
+                                                        role="button"
+                                                        class={styles.link}
+                                                        onClick={event => { event.preventDefault(); addTrack(entry, type()); }}
+                                                    >{type() === 'sub' ? '[+subs]' : '[+audio]'}</div>
+                                                </>
+                                            }</Show>
+                                        </Show>
+                                        <Show when={entry.isDir && entry.name !== '..'}>
+                                            {' '}<div
+                                                role="button"
+                                                class={styles.link}
+                                                onClick={event => { event.preventDefault(); playFolder(entry.path); }}
+                                            >[play]</div>
+                                        </Show>
                                     </li>
                                 }</For>
                             </ul>
@@ -286,25 +495,150 @@ const App: Component<{ root: HTMLElement }> = ({ root }) => {
                         <button type="button" onClick={() => filePicker?.close()}>Cancel</button>
                     </dialog>
 
-                    <Show when={path()}>
-                        <div>Current playback time: {formatDuration(playbackTime())} / {formatDuration(duration())}</div>
+                    <Show when={resume()}>
                         <div>
-                            Audio: <div
+                            Resume from {formatDuration(resume()?.position)}? <div
                                 role="button"
                                 class={styles.link}
-                                onClick={event => { event.preventDefault(); cycleAudioTrack(); }}
-                            >{selectedAudioTrack()}</div>
+                                onClick={event => { event.preventDefault(); resumePlayback(); }}
+                            >[resume]</div>
+                            {' '}<div
+                                role="button"
+                                class={styles.link}
+                                onClick={event => { event.preventDefault(); setResume(null); }}
+                            >[dismiss]</div>
                         </div>
+                    </Show>
+
+                    <Show when={path()}>
+                        <div>Current playback time: {formatDuration(playbackTime())} / {formatDuration(duration())}</div>
                         <div>
-                            Subtitles: <div
+                            Audio: <select
+                                value={aid() || 'no'}
+                                onChange={event => selectTrack('audio', event.currentTarget.value)}
+                            >
+                                <option value="no">no</option>
+                                <For each={tracksOfType('audio')}>{track =>
+                                    <option value={track.id}>{formatTrack(track)}</option>
+                                }</For>
+                            </select>
+                            {' '}<div role="button" class={styles.link} onClick={() => changeAudioDelay(-0.1)}>[-0.1s]</div>
+                            {' '}{formatDelay(audioDelay())}
+                            {' '}<div role="button" class={styles.link} onClick={() => changeAudioDelay(+0.1)}>[+0.1s]</div>
+                        </div>
+                        <div>
+                            Subtitles: <select
+                                value={sid() || 'no'}
+                                onChange={event => selectTrack('sub', event.currentTarget.value)}
+                            >
+                                <option value="no">no</option>
+                                <For each={tracksOfType('sub')}>{track =>
+                                    <option value={track.id}>{formatTrack(track)}</option>
+                                }</For>
+                            </select>
+                            {' '}<div role="button" class={styles.link} onClick={() => changeSubDelay(-0.1)}>[-0.1s]</div>
+                            {' '}{formatDelay(subDelay())}
+                            {' '}<div role="button" class={styles.link} onClick={() => changeSubDelay(+0.1)}>[+0.1s]</div>
+                        </div>
+                        <Show when={tracksOfType('sub').length > 1}>
+                            <div>
+                                Secondary subtitles: <select
+                                    value={secondarySid() || 'no'}
+                                    onChange={event => selectTrack('secondary-sub', event.currentTarget.value)}
+                                >
+                                    <option value="no">no</option>
+                                    <For each={tracksOfType('sub')}>{track =>
+                                        <option value={track.id}>{formatTrack(track)}</option>
+                                    }</For>
+                                </select>
+                            </div>
+                        </Show>
+                        <Show when={sid() !== false}>
+                            <div>
+                                Subtitle size: <div role="button" class={styles.link} onClick={() => changeSubScale(-0.1)}>[-]</div>
+                                {' '}{subScale()}
+                                {' '}<div role="button" class={styles.link} onClick={() => changeSubScale(+0.1)}>[+]</div>
+                                {' '}| Position: <div role="button" class={styles.link} onClick={() => changeSubPos(-5)}>[up]</div>
+                                {' '}{subPos()}
+                                {' '}<div role="button" class={styles.link} onClick={() => changeSubPos(+5)}>[down]</div>
+                            </div>
+                        </Show>
+                        <div role="button" class={styles.link} onClick={() => forgetSeriesPreferences()}>[forget series preferences]</div>
+                    </Show>
+
+                    <Show when={(chapterList()?.length ?? 0) > 0}>
+                        <div>
+                            Chapter: <div
                                 role="button"
                                 class={styles.link}
-                                onClick={event => { event.preventDefault(); cycleSubtitleTrack(); }}
-                            >{selectedSubtitleTrack()}</div>
+                                onClick={event => { event.preventDefault(); command(['add', 'chapter', -1]); }}
+                            >[prev]</div>
+                            {' '}<select
+                                value={chapter() ?? -1}
+                                onChange={event => command(['set_property', 'chapter', Number(event.currentTarget.value)])}
+                            >
+                                <For each={chapterList()}>{(entry, index) =>
+                                    <option value={index()}>{formatChapter(entry, index())}</option>
+                                }</For>
+                            </select>
+                            {' '}<div
+                                role="button"
+                                class={styles.link}
+                                onClick={event => { event.preventDefault(); command(['add', 'chapter', 1]); }}
+                            >[next]</div>
+                            <Show when={intro()}>
+                                {' '}<div
+                                    role="button"
+                                    class={styles.link}
+                                    onClick={event => { event.preventDefault(); skipIntro(); }}
+                                >[skip intro]</div>
+                            </Show>
                         </div>
+                        <label>
+                            <input type="checkbox" checked={autoSkipIntro()} onChange={() => toggleAutoSkipIntro()} /> Skip intros automatically
+                        </label>
                     </Show>
 
                     <div>Volume: {volume()}% | Speed: {speed()}</div>
+                    <Show when={(audioDeviceList()?.length ?? 0) > 1}>
+                        <div>
+                            Output: <select
+                                value={audioDevice() ?? ''}
+                                onChange={event => switchAudioDevice(event.currentTarget.value)}
+                            >
+                                <For each={audioDeviceList()}>{device =>
+                                    <option value={device.name}>{device.description || device.name}</option>
+                                }</For>
+                            </select>
+                        </div>
+                    </Show>
+                    <Show
+                        when={sleepTimer()}
+                        fallback={
+                            <div>
+                                Sleep: <select
+                                    value={sleepAction()}
+                                    onChange={event => setSleepAction(event.currentTarget.value as SleepTimer['action'])}
+                                >
+                                    <option value="pause">pause</option>
+                                    <option value="stop">stop</option>
+                                    <option value="quit">quit</option>
+                                </select>
+                                <For each={[15, 30, 60]}>{minutes =>
+                                    <>{' '}<div role="button" class={styles.link} onClick={() => startSleepTimer({ minutes })}>[{minutes} min]</div></>
+                                }</For>
+                                {' '}<div role="button" class={styles.link} onClick={() => startSleepTimer({ endOfFile: true })}>[end of file]</div>
+                                {' '}<label>
+                                    <input type="checkbox" checked={sleepFadeOut()} onChange={() => setSleepFadeOut(!sleepFadeOut())} /> fade out
+                                </label>
+                            </div>
+                        }
+                    >
+                        <div>
+                            Sleep: {formatSleepTimer(sleepTimer()!, now())}
+                            {' '}<div role="button" class={styles.link} onClick={() => cancelSleepTimer()}>[cancel]</div>
+                        </div>
+                    </Show>
                     <div>
                         Path: <div
                             role="button"
@@ -312,6 +646,30 @@ const App: Component<{ root: HTMLElement }> = ({ root }) => {
                             onClick={event => { event.preventDefault(); openFilePicker(); }}
                         >{path() || 'No file selected'}</div>
                     </div>
+
+                    <Show when={(playlist()?.length ?? 0) > 1}>
+                        <div>
+                            Playlist: <div
+                                role="button"
+                                class={styles.link}
+                                onClick={event => { event.preventDefault(); command(['playlist-shuffle']); }}
+                            >[shuffle]</div>
+                        </div>
+                        <ol class={styles.playlist}>
+                            <For each={playlist()}>{(entry, index) =>
+                                <li classList={{ [styles.current]: !!entry.current }}>
+                                    <div
+                                        role="button"
+                                        class={styles.link}
+                                        onClick={event => { event.preventDefault(); command(['playlist-play-index', index()]); }}
+                                    >{formatPlaylistEntry(entry)}</div>
+                                    {' '}<div role="button" class={styles.link} onClick={() => movePlaylistEntry(index(), -1)}>[up]</div>
+                                    {' '}<div role="button" class={styles.link} onClick={() => movePlaylistEntry(index(), +1)}>[down]</div>
+                                    {' '}<div role="button" class={styles.link} onClick={() => command(['playlist-remove', index()])}>[x]</div>
+                                </li>
+                            }</For>
+                        </ol>
+                    </Show>
                 </div>
             </Show>
         </Show>
@@ -0,0 +1,66 @@
+import { createSignal, Show, type Component } from 'solid-js';
+
+import styles from './App.module.css';
+
+const Pairing: Component<{ onPaired: () => void }> = ({ onPaired }) => {
+    const [pinRequested, setPinRequested] = createSignal(false);
+    const [pin, setPin] = createSignal('');
+    const [error, setError] = createSignal<string | null>(null);
+
+    async function requestPin(): Promise<void> {
+        const response = await fetch('/pair/pin', { method: 'POST' });
+        if (!response.ok) {
+            setError(await response.text());
+            return;
+        }
+        setError(null);
+        setPinRequested(true);
+    }
+
+    async function pair(event: SubmitEvent): Promise<void> {
+        event.preventDefault();
+
+        const body = new FormData();
+        body.append('pin', pin());
+        const response = await fetch('/pair', {
+            method: 'POST',
+            body: body,
+        });
+        if (!response.ok) {
+            setError(await response.text());
+            return;
+        }
+        onPaired();
+    }
+
+    return (
+        <div class={styles.notConnected}>
+            This device is not paired with mpvrc
+
+            <Show
+                when={pinRequested()}
+                fallback={
+                    <button type="button" class={styles.pairing} onClick={() => requestPin()}>Pair</button>
+                }
+            >
+                <form class={styles.pairing} onSubmit={pair}>
+                    <div>Enter PIN shown in mpv</div>
+                    <input
+                        type="text"
+                        inputmode="numeric"
+                        autocomplete="one-time-code"
+                        value={pin()}
+                        onInput={event => setPin(event.currentTarget.value)}
+                    />
+                    <button type="submit">OK</button>
+                </form>
+            </Show>
+
+            <Show when={error()}>
+                <div class={styles.connectionError}>{error()}</div>
+            </Show>
+        </div>
+    );
+};
+
+export default Pairing;
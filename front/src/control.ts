@@ -0,0 +1,108 @@
+interface Reply {
+    id: number;
+    data?: any;
+    error?: string;
+    status: number;
+}
+
+interface PendingRequest {
+    resolve: (data: any) => void;
+    reject: (error: Error) => void;
+}
+
+const reconnectDelayMs = 1000;
+
+/**
+ * Control channel over `/ws`. Sends commands and receives events of subscribed global properties.
+ * Commands are sent with `POST /command` while WebSocket is not connected.
+ */
+export class ControlChannel {
+    private socket: WebSocket | null = null;
+    private nextId = 1;
+    private pending = new Map<number, PendingRequest>();
+    private closed = false;
+
+    constructor(
+        private readonly properties: string[],
+        private readonly onEvent: (event: any) => void,
+    ) {
+        this.connect();
+    }
+
+    close(): void {
+        this.closed = true;
+        this.socket?.close();
+    }
+
+    async command(args: any[]): Promise<any> {
+        if (this.socket?.readyState !== WebSocket.OPEN) {
+            return this.postCommand(args);
+        }
+        return this.request({ type: 'command', command: args });
+    }
+
+    private connect(): void {
+        const protocol = location.protocol === 'https:' ? 'wss:' : 'ws:';
+        const socket = new WebSocket(`${protocol}//${location.host}/ws`);
+        this.socket = socket;
+
+        socket.onopen = () => {
+            this.request({ type: 'subscribe', properties: this.properties })
+                .catch(error => console.error('failed to subscribe', error));
+        };
+
+        socket.onmessage = (message) => {
+            const data = JSON.parse(message.data);
+            if (data.id === undefined) {
+                this.onEvent(data);
+                return;
+            }
+
+            const reply = data as Reply;
+            const pending = this.pending.get(reply.id);
+            if (!pending) {
+                return;
+            }
+            this.pending.delete(reply.id);
+
+            if (reply.error) {
+                pending.reject(new Error(reply.error));
+            } else {
+                pending.resolve(reply.data ?? null);
+            }
+        };
+
+        socket.onclose = () => {
+            for (const pending of this.pending.values()) {
+                pending.reject(new Error('connection closed'));
+            }
+            this.pending.clear();
+            this.socket = null;
+
+            if (!this.closed) {
+                setTimeout(() => this.connect(), reconnectDelayMs);
+            }
+        };
+    }
+
+    private request(request: object): Promise<any> {
+        const id = this.nextId++;
+        return new Promise((resolve, reject) => {
+            this.pending.set(id, { resolve, reject });
+            this.socket!.send(JSON.stringify({ ...request, id }));
+        });
+    }
+
+    private async postCommand(args: any[]): Promise<any> {
+        const body = new FormData();
+        body.append('command', JSON.stringify(args));
+        const response = await fetch('/command', {
+            method: 'POST',
+            body: body,
+        });
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+        return response.json();
+    }
+}
@@ -1,8 +1,10 @@
 /* @refresh reload */
+import { createResource, Match, Switch } from 'solid-js';
 import { render } from 'solid-js/web';
 
 import './index.css';
 import App from './App';
+import Pairing from './Pairing';
 
 const root = document.getElementById('root');
 
@@ -12,4 +14,22 @@ if (import.meta.env.DEV && !(root instanceof HTMLElement)) {
     );
 }
 
-render(() => <App root={root!} />, root!);
+async function fetchPaired(): Promise<boolean> {
+    const response = await fetch('/auth');
+    return response.ok;
+}
+
+render(() => {
+    const [paired, { refetch }] = createResource(fetchPaired);
+
+    return (
+        <Switch>
+            <Match when={paired() === true}>
+                <App root={root!} />
+            </Match>
+            <Match when={paired() === false}>
+                <Pairing onPaired={() => refetch()} />
+            </Match>
+        </Switch>
+    );
+}, root!);
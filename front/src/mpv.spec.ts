@@ -1,5 +1,5 @@
 import { expect, test, describe } from 'vitest';
-import { AudioTrack, formatDuration, formatTrack, SubtitleTrack } from './mpv';
+import { AudioTrack, externalTrackType, formatChapter, formatDelay, formatDuration, formatPlaylistEntry, formatSleepTimer, formatTrack, SubtitleTrack } from './mpv';
 
 describe('formatDuration', () => {
     for (const { seconds, want } of [
@@ -230,3 +230,64 @@ describe('formatTrack', ()  => {
     }
 })
 
+
+describe('formatPlaylistEntry', () => {
+    for (const { entry, want } of [
+        {
+            entry: { id: 1, filename: '/video/Show/Episode 01.mkv' },
+            want:  'Episode 01.mkv',
+        },
+        {
+            entry: { id: 2, filename: 'D:\\Video\\Episode 02.mkv' },
+            want:  'Episode 02.mkv',
+        },
+        {
+            entry: { id: 3, filename: '/video/a.mkv', title: 'Pilot' },
+            want:  'Pilot',
+        },
+    ]) {
+        test(want, () => { expect(formatPlaylistEntry(entry)).toBe(want); });
+    }
+})
+
+describe('formatChapter', () => {
+    test('with title', () => { expect(formatChapter({ title: 'Opening', time: 90.5 }, 1)).toBe('00:01:30 Opening'); });
+    test('without title', () => { expect(formatChapter({ title: '', time: 0 }, 0)).toBe('00:00:00 Chapter 1'); });
+})
+
+describe('externalTrackType', () => {
+    for (const { path, want } of [
+        { path: '/video/Show - 01.en.srt', want: 'sub' },
+        { path: 'C:\\Video\\Show - 01.ASS', want: 'sub' },
+        { path: '/video/Show - 01.commentary.mka', want: 'audio' },
+        { path: '/video/Show - 01.mkv', want: null },
+        { path: '/video/.srt', want: null },
+        { path: '/video/srt', want: null },
+    ] as const) {
+        test(path, () => { expect(externalTrackType(path)).toBe(want); });
+    }
+})
+
+describe('formatDelay', () => {
+    test('zero', () => { expect(formatDelay(0)).toBe('0s'); });
+    test('positive', () => { expect(formatDelay(0.1 + 0.2)).toBe('+0.3s'); });
+    test('negative', () => { expect(formatDelay(-1.25)).toBe('-1.25s'); });
+})
+
+describe('formatSleepTimer', () => {
+    const now = new Date('2026-01-01T23:00:00Z');
+    test('deadline', () => {
+        const timer = { action: 'pause', deadline: '2026-01-01T23:29:30Z', remaining: 1770, fadeOut: false } as const;
+        expect(formatSleepTimer(timer, now)).toBe('pause in 00:29:30');
+    });
+    test('passed deadline', () => {
+        const timer = { action: 'stop', deadline: '2026-01-01T22:59:00Z', remaining: 0, fadeOut: true } as const;
+        expect(formatSleepTimer(timer, now)).toBe('stop in 00:00:00');
+    });
+    test('end of file', () => {
+        expect(formatSleepTimer({ action: 'pause', files: 1, remaining: null, fadeOut: false }, now)).toBe('pause at end of file');
+    });
+    test('files', () => {
+        expect(formatSleepTimer({ action: 'quit', files: 3, remaining: null, fadeOut: false }, now)).toBe('quit after 3 files');
+    });
+})
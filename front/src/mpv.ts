@@ -67,3 +67,94 @@ export function formatTrack(track: Track | null | undefined): string {
     }
     return result;
 }
+
+export interface PlaylistEntry {
+    id: number;
+    filename: string;
+    title?: string;
+    current?: boolean;
+    playing?: boolean;
+}
+
+export function formatPlaylistEntry(entry: PlaylistEntry): string {
+    return entry.title || fileName(entry.filename);
+}
+
+export function fileName(path: string): string {
+    return path.split(/[\\/]/).pop() || path;
+}
+
+export interface HistoryEntry {
+    path: string;
+    position: DurationInSeconds;
+    duration: DurationInSeconds;
+    lastPlayed: string;
+    completed: boolean;
+}
+
+export interface ResumeOffer {
+    path: string;
+    position: DurationInSeconds;
+}
+
+export interface Chapter {
+    title: string;
+    time: DurationInSeconds;
+}
+
+export function formatChapter(chapter: Chapter, index: number): string {
+    return `${formatDuration(chapter.time)} ${chapter.title || `Chapter ${index + 1}`}`;
+}
+
+export interface AudioDevice {
+    name: string;
+    description: string;
+}
+
+export type TrackType = 'video' | 'audio' | 'sub' | 'secondary-sub';
+
+const subtitleExtensions = ['ass', 'idx', 'srt', 'ssa', 'sub', 'sup', 'vtt'];
+const audioExtensions = ['aac', 'ac3', 'dts', 'eac3', 'flac', 'm4a', 'mka', 'mp3', 'ogg', 'opus', 'wav'];
+
+// externalTrackType returns type of track which file can be added as, or null if it is not a subtitle or audio file.
+export function externalTrackType(path: string): 'sub' | 'audio' | null {
+    const name = fileName(path);
+    const dot = name.lastIndexOf('.');
+    if (dot <= 0) {
+        return null;
+    }
+    const ext = name.slice(dot + 1).toLowerCase();
+    if (subtitleExtensions.includes(ext)) {
+        return 'sub';
+    }
+    if (audioExtensions.includes(ext)) {
+        return 'audio';
+    }
+    return null;
+}
+
+export function formatDelay(seconds: number): string {
+    const rounded = Math.round(seconds * 1000) / 1000;
+    return `${rounded > 0 ? '+' : ''}${rounded}s`;
+}
+
+export interface SleepTimer {
+    action: 'pause' | 'stop' | 'quit';
+    // Deadline is set for timers which run out after timeout.
+    deadline?: string;
+    // Files is number of files left to play to the end for timers without timeout.
+    files?: number;
+    remaining: DurationInSeconds | null;
+    fadeOut: boolean;
+}
+
+export function formatSleepTimer(timer: SleepTimer, now: Date): string {
+    if (timer.deadline) {
+        const seconds = Math.max(0, (new Date(timer.deadline).getTime() - now.getTime()) / 1000);
+        return `${timer.action} in ${formatDuration(seconds)}`;
+    }
+    if (timer.files === 1) {
+        return `${timer.action} at end of file`;
+    }
+    return `${timer.action} after ${timer.files} files`;
+}
@@ -2,13 +2,16 @@ module github.com/miere43/mpvrc
 
 go 1.24.1
 
-require github.com/tc-hib/winres v0.3.1
+require (
+	github.com/coder/websocket v1.8.14
+	github.com/stretchr/testify v1.10.0
+	github.com/tc-hib/winres v0.3.1
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/stretchr/testify v1.10.0 // indirect
 	golang.org/x/image v0.12.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
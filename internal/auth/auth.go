@@ -0,0 +1,204 @@
+// Package auth pairs devices with mpvrc using a short PIN and keeps long-lived tokens issued to them.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/jsonfile"
+)
+
+const (
+	// PINLifetime is how long a PIN can be used for pairing.
+	PINLifetime = 2 * time.Minute
+	// MaxPINAttempts is how many wrong PINs are accepted before pairing is locked for PINLifetime.
+	MaxPINAttempts = 5
+)
+
+var (
+	ErrInvalidPIN    = errors.New("invalid or expired PIN")
+	ErrPairingLocked = errors.New("too many wrong PIN attempts, try again later")
+	ErrTokenNotFound = errors.New("token not found")
+)
+
+// Token is issued to a paired device. Only the hash of the token secret is stored.
+type Token struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Store struct {
+	m      sync.Mutex
+	path   string
+	tokens []Token
+
+	pin         string
+	pinExpires  time.Time
+	pinAttempts int
+	lockedUntil time.Time
+
+	now func() time.Time
+}
+
+// NewStore creates store which doesn't persist tokens.
+func NewStore() *Store {
+	return &Store{now: time.Now}
+}
+
+// LoadStore loads tokens from path. Changes are saved back to path.
+func LoadStore(path string) (*Store, error) {
+	s := NewStore()
+	s.path = path
+
+	if _, err := jsonfile.Read(path, &s.tokens); err != nil {
+		return nil, fmt.Errorf("read tokens: %w", err)
+	}
+	return s, nil
+}
+
+// PIN returns PIN for pairing. New PIN is generated if there is no PIN or it has expired.
+func (s *Store) PIN() (string, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	now := s.now()
+	if now.Before(s.lockedUntil) {
+		return "", ErrPairingLocked
+	}
+	if s.pin != "" && now.Before(s.pinExpires) {
+		return s.pin, nil
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", fmt.Errorf("generate PIN: %w", err)
+	}
+
+	s.pin = fmt.Sprintf("%06d", n.Int64())
+	s.pinExpires = now.Add(PINLifetime)
+	s.pinAttempts = 0
+	return s.pin, nil
+}
+
+// Pair exchanges PIN for a new token. It returns token secret which must be presented by the device.
+func (s *Store) Pair(pin, name string) (string, Token, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.pin == "" || s.now().After(s.pinExpires) {
+		return "", Token{}, ErrInvalidPIN
+	}
+	if subtle.ConstantTimeCompare([]byte(pin), []byte(s.pin)) != 1 {
+		s.pinAttempts++
+		if s.pinAttempts >= MaxPINAttempts {
+			// Locking pairing makes guessing PIN impractical.
+			slog.Warn("too many wrong PIN attempts, pairing is locked", "duration", PINLifetime)
+			s.pin = ""
+			s.lockedUntil = s.now().Add(PINLifetime)
+		}
+		return "", Token{}, ErrInvalidPIN
+	}
+	// PIN can only be used once.
+	s.pin = ""
+
+	id, err := randomString(6)
+	if err != nil {
+		return "", Token{}, err
+	}
+	secret, err := randomString(32)
+	if err != nil {
+		return "", Token{}, err
+	}
+
+	token := Token{
+		ID:        id,
+		Name:      name,
+		Hash:      hashSecret(secret),
+		CreatedAt: s.now().UTC(),
+	}
+	s.tokens = append(s.tokens, token)
+	if err := s.save(); err != nil {
+		s.tokens = s.tokens[:len(s.tokens)-1]
+		return "", Token{}, err
+	}
+	return secret, token, nil
+}
+
+// Verify returns token which matches secret.
+func (s *Store) Verify(secret string) (Token, bool) {
+	if secret == "" {
+		return Token{}, false
+	}
+	hash := hashSecret(secret)
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for _, token := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(token.Hash)) == 1 {
+			return token, true
+		}
+	}
+	return Token{}, false
+}
+
+func (s *Store) Tokens() []Token {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return slices.Clone(s.tokens)
+}
+
+func (s *Store) Revoke(id string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	index := slices.IndexFunc(s.tokens, func(token Token) bool { return token.ID == id })
+	if index == -1 {
+		return ErrTokenNotFound
+	}
+
+	tokens := s.tokens
+	s.tokens = slices.Delete(slices.Clone(tokens), index, index+1)
+	if err := s.save(); err != nil {
+		s.tokens = tokens
+		return err
+	}
+	return nil
+}
+
+// save must be called with s.m locked.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	if err := jsonfile.Write(s.path, s.tokens); err != nil {
+		return fmt.Errorf("write tokens: %w", err)
+	}
+	return nil
+}
+
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate random string: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type storeSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+func TestStore(t *testing.T) {
+	suite.Run(t, new(storeSuite))
+}
+
+func (s *storeSuite) SetupTest() {
+	s.now = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (s *storeSuite) newStore(path string) *Store {
+	store, err := LoadStore(path)
+	s.Require().NoError(err)
+	store.now = func() time.Time { return s.now }
+	return store
+}
+
+func (s *storeSuite) TestPairAndVerify() {
+	r := s.Require()
+	store := s.newStore(filepath.Join(s.T().TempDir(), "tokens.json"))
+
+	pin, err := store.PIN()
+	r.NoError(err)
+	r.Len(pin, 6)
+
+	secret, token, err := store.Pair(pin, "phone")
+	r.NoError(err)
+	s.NotContains(token.Hash, secret)
+
+	verified, ok := store.Verify(secret)
+	s.True(ok)
+	s.Equal(token, verified)
+
+	_, ok = store.Verify("wrong")
+	s.False(ok)
+
+	// PIN can only be used once.
+	_, _, err = store.Pair(pin, "laptop")
+	s.ErrorIs(err, ErrInvalidPIN)
+}
+
+func (s *storeSuite) TestTokensArePersisted() {
+	r := s.Require()
+	path := filepath.Join(s.T().TempDir(), "mpvrc", "tokens.json")
+	store := s.newStore(path)
+
+	pin, err := store.PIN()
+	r.NoError(err)
+	secret, token, err := store.Pair(pin, "phone")
+	r.NoError(err)
+
+	loaded := s.newStore(path)
+	_, ok := loaded.Verify(secret)
+	s.True(ok)
+
+	r.NoError(loaded.Revoke(token.ID))
+	s.Empty(loaded.Tokens())
+	s.ErrorIs(loaded.Revoke(token.ID), ErrTokenNotFound)
+
+	_, ok = s.newStore(path).Verify(secret)
+	s.False(ok)
+}
+
+func (s *storeSuite) TestPINExpires() {
+	store := s.newStore("")
+
+	pin, err := store.PIN()
+	s.Require().NoError(err)
+
+	again, err := store.PIN()
+	s.Require().NoError(err)
+	s.Equal(pin, again)
+
+	s.now = s.now.Add(PINLifetime + time.Second)
+	_, _, err = store.Pair(pin, "phone")
+	s.ErrorIs(err, ErrInvalidPIN)
+}
+
+func (s *storeSuite) TestTooManyAttemptsLockPairing() {
+	store := s.newStore("")
+
+	pin, err := store.PIN()
+	s.Require().NoError(err)
+
+	for range MaxPINAttempts {
+		_, _, err = store.Pair("wrong", "phone")
+		s.ErrorIs(err, ErrInvalidPIN)
+	}
+
+	_, _, err = store.Pair(pin, "phone")
+	s.ErrorIs(err, ErrInvalidPIN)
+
+	_, err = store.PIN()
+	s.ErrorIs(err, ErrPairingLocked)
+
+	s.now = s.now.Add(PINLifetime)
+	_, err = store.PIN()
+	s.NoError(err)
+}
@@ -0,0 +1,230 @@
+// Package certs manages self-signed certificate authority and server certificate used for HTTPS.
+package certs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	caValidity = 10 * 365 * 24 * time.Hour
+	// Browsers reject server certificates which are valid for longer than 398 days.
+	serverValidity = 397 * 24 * time.Hour
+	// Server certificate is regenerated when it expires sooner than renewBefore.
+	renewBefore = 30 * 24 * time.Hour
+)
+
+const (
+	caCertFile     = "ca.crt"
+	caKeyFile      = "ca.key"
+	serverCertFile = "server.crt"
+	serverKeyFile  = "server.key"
+)
+
+// CAFile returns path to CA certificate in dir. Devices which trust it don't show certificate warnings.
+func CAFile(dir string) string {
+	return filepath.Join(dir, caCertFile)
+}
+
+// Ensure returns server certificate for hosts signed by CA stored in dir. CA and server certificate are
+// generated if they are missing. Server certificate is regenerated if it expires soon or doesn't cover all hosts.
+func Ensure(dir string, hosts []string) (tls.Certificate, error) {
+	return ensure(dir, hosts, time.Now())
+}
+
+func ensure(dir string, hosts []string, now time.Time) (tls.Certificate, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return tls.Certificate{}, fmt.Errorf("create certificates directory: %w", err)
+	}
+
+	ca, caKey, err := loadKeyPair(dir, caCertFile, caKeyFile)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && now.After(ca.NotAfter)) {
+		slog.Info("generating CA certificate", "dir", dir)
+		ca, caKey, err = createCA(dir, now)
+	}
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	server, _, err := loadKeyPair(dir, serverCertFile, serverKeyFile)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && !isValidServerCert(server, ca, hosts, now)) {
+		slog.Info("generating server certificate", "dir", dir, "hosts", hosts)
+		_, _, err = createServerCert(dir, ca, caKey, hosts, now)
+	}
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return LoadFiles(filepath.Join(dir, serverCertFile), filepath.Join(dir, serverKeyFile))
+}
+
+// LoadFiles loads certificate and private key from PEM files.
+func LoadFiles(certFile, keyFile string) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return cert, fmt.Errorf("load certificate %q: %w", certFile, err)
+	}
+	return cert, nil
+}
+
+// LocalHosts returns host name and IP addresses of this machine which can be used to reach it from LAN.
+func LocalHosts() []string {
+	hosts := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil {
+		hosts = append(hosts, hostname, hostname+".local")
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		slog.Warn("failed to get network interface addresses", "err", err)
+		return hosts
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		hosts = append(hosts, ipNet.IP.String())
+	}
+	return hosts
+}
+
+func isValidServerCert(cert, ca *x509.Certificate, hosts []string, now time.Time) bool {
+	if now.Add(renewBefore).After(cert.NotAfter) {
+		return false
+	}
+	if err := cert.CheckSignatureFrom(ca); err != nil {
+		return false
+	}
+	for _, host := range hosts {
+		if err := cert.VerifyHostname(host); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func createCA(dir string, now time.Time) (*x509.Certificate, crypto.Signer, error) {
+	template := &x509.Certificate{
+		Subject:               pkix.Name{Organization: []string{"mpvrc"}, CommonName: "mpvrc CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	return createKeyPair(dir, caCertFile, caKeyFile, template, nil, nil)
+}
+
+func createServerCert(dir string, ca *x509.Certificate, caKey crypto.Signer, hosts []string, now time.Time) (*x509.Certificate, crypto.Signer, error) {
+	template := &x509.Certificate{
+		Subject:     pkix.Name{Organization: []string{"mpvrc"}, CommonName: "mpvrc"},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(serverValidity),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	return createKeyPair(dir, serverCertFile, serverKeyFile, template, ca, caKey)
+}
+
+// createKeyPair generates key and certificate signed by parent and writes them to dir.
+// Certificate is self-signed if parent is nil.
+func createKeyPair(dir, certFile, keyFile string, template, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate serial number: %w", err)
+	}
+	template.SerialNumber = serial
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse certificate: %w", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal key: %w", err)
+	}
+
+	// Key is written first, so certificate never exists without its key.
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(filepath.Join(dir, keyFile), keyPEM, 0o600); err != nil {
+		return nil, nil, fmt.Errorf("write key: %w", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	if err := os.WriteFile(filepath.Join(dir, certFile), certPEM, 0o644); err != nil {
+		return nil, nil, fmt.Errorf("write certificate: %w", err)
+	}
+
+	return cert, key, nil
+}
+
+func loadKeyPair(dir, certFile, keyFile string) (*x509.Certificate, crypto.Signer, error) {
+	certPEM, err := os.ReadFile(filepath.Join(dir, certFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(dir, keyFile))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, nil, fmt.Errorf("%s: no PEM data", certFile)
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", certFile, err)
+	}
+
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, nil, fmt.Errorf("%s: no PEM data", keyFile)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", keyFile, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: unsupported key type %T", keyFile, key)
+	}
+
+	return cert, signer, nil
+}
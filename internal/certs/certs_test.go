@@ -0,0 +1,90 @@
+package certs
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type certsSuite struct {
+	suite.Suite
+	dir string
+	now time.Time
+}
+
+func TestCerts(t *testing.T) {
+	suite.Run(t, new(certsSuite))
+}
+
+func (s *certsSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+	s.now = time.Now()
+}
+
+func (s *certsSuite) ensure(hosts ...string) *x509.Certificate {
+	cert, err := ensure(s.dir, hosts, s.now)
+	s.Require().NoError(err)
+	return cert.Leaf
+}
+
+func (s *certsSuite) verify(cert *x509.Certificate, host string) error {
+	caPEM, err := os.ReadFile(CAFile(s.dir))
+	s.Require().NoError(err)
+
+	roots := x509.NewCertPool()
+	s.Require().True(roots.AppendCertsFromPEM(caPEM))
+
+	_, err = cert.Verify(x509.VerifyOptions{
+		DNSName:     host,
+		Roots:       roots,
+		CurrentTime: s.now,
+	})
+	return err
+}
+
+func (s *certsSuite) TestGenerate() {
+	cert := s.ensure("localhost", "media-pc", "192.168.1.10")
+
+	s.NoError(s.verify(cert, "media-pc"))
+	s.NoError(s.verify(cert, "192.168.1.10"))
+	s.Error(s.verify(cert, "192.168.1.11"))
+
+	info, err := os.Stat(filepath.Join(s.dir, caKeyFile))
+	s.Require().NoError(err)
+	if os.PathSeparator == '/' {
+		s.Equal(os.FileMode(0o600), info.Mode().Perm())
+	}
+}
+
+func (s *certsSuite) TestReuse() {
+	first := s.ensure("localhost", "192.168.1.10")
+	second := s.ensure("192.168.1.10")
+	s.Equal(first.SerialNumber, second.SerialNumber)
+}
+
+func (s *certsSuite) TestNewHostKeepsCA() {
+	first := s.ensure("localhost", "192.168.1.10")
+	caPEM, err := os.ReadFile(CAFile(s.dir))
+	s.Require().NoError(err)
+
+	second := s.ensure("localhost", "192.168.1.20")
+	s.NotEqual(first.SerialNumber, second.SerialNumber)
+	s.NoError(s.verify(second, "192.168.1.20"))
+
+	newCAPEM, err := os.ReadFile(CAFile(s.dir))
+	s.Require().NoError(err)
+	s.Equal(caPEM, newCAPEM)
+}
+
+func (s *certsSuite) TestRenewBeforeExpiration() {
+	first := s.ensure("localhost")
+
+	s.now = first.NotAfter.Add(-renewBefore + time.Hour)
+	second := s.ensure("localhost")
+	s.NotEqual(first.SerialNumber, second.SerialNumber)
+	s.NoError(s.verify(second, "localhost"))
+}
@@ -0,0 +1,437 @@
+// Package config loads mpvrc configuration from configuration file, command line flags
+// and environment variables, in order of increasing priority.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/miere43/mpvrc/internal/media"
+)
+
+type Config struct {
+	// Listen is HTTP server address.
+	Listen string `json:"listen"`
+	// LogLevel is one of "debug", "info", "warn" or "error".
+	LogLevel string `json:"logLevel"`
+	// LogPath is path to log file. Empty means mpvrc.log next to the executable.
+	LogPath string `json:"logPath"`
+	// TokensFile is path to file with tokens of paired devices. Empty means tokens.json next to the configuration file.
+	TokensFile  string      `json:"tokensFile"`
+	MPV         MPV         `json:"mpv"`
+	Policy      Policy      `json:"policy"`
+	TLS         TLS         `json:"tls"`
+	Media       Media       `json:"media"`
+	History     History     `json:"history"`
+	Chapters    Chapters    `json:"chapters"`
+	Audio       Audio       `json:"audio"`
+	Preferences Preferences `json:"preferences"`
+
+	// File is path to the configuration file which was used to load the configuration.
+	File string `json:"-"`
+}
+
+type MPV struct {
+	// Path to mpv executable. Empty means PATH and common install locations are searched.
+	Path string `json:"path"`
+	// Profile is mpv profile from mpv.conf.
+	Profile string `json:"profile"`
+	// Args are extra arguments passed to mpv.
+	Args []string `json:"args"`
+	// IPCServer is named pipe or Unix domain socket path. Empty means it is generated from Profile.
+	IPCServer string `json:"ipcServer"`
+}
+
+// Policy restricts which mpv commands can be sent over HTTP.
+type Policy struct {
+	// Commands are mpv command names which are allowed.
+	Commands []string `json:"commands"`
+	// Properties are mpv property names which can be read and changed.
+	Properties []string `json:"properties"`
+	// Roots are directories from which files can be opened. Empty means any local file.
+	Roots []string `json:"roots"`
+}
+
+// TLS configures HTTPS. Self-signed certificate is generated when CertFile and KeyFile are not set.
+type TLS struct {
+	Enabled  bool   `json:"enabled"`
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// CertDir is directory for generated certificates. Empty means "certs" next to the configuration file.
+	CertDir string `json:"certDir"`
+	// RedirectListen is address of HTTP server which redirects to HTTPS. Empty means there is no redirect.
+	RedirectListen string `json:"redirectListen"`
+}
+
+// Media configures which files are played when a folder is opened.
+type Media struct {
+	// Extensions are extensions of media files without the leading dot.
+	Extensions []string `json:"extensions"`
+	// Recursive makes opened folder include files in subdirectories.
+	Recursive bool `json:"recursive"`
+}
+
+// Resume modes of History.
+const (
+	ResumeAuto   = "auto"
+	ResumePrompt = "prompt"
+	ResumeOff    = "off"
+)
+
+// History configures watch history.
+type History struct {
+	// File is path to watch history file. Empty means history.json next to the configuration file.
+	File string `json:"file"`
+	// Resume is one of "auto", "prompt" or "off". With "prompt" clients are asked whether to resume.
+	Resume string `json:"resume"`
+}
+
+// Chapters configures chapter navigation.
+type Chapters struct {
+	// IntroPattern is regular expression which matches titles of intro chapters.
+	IntroPattern string `json:"introPattern"`
+	// AutoSkipIntro makes mpvrc skip intro chapters. Clients can toggle it until mpvrc is restarted.
+	AutoSkipIntro bool `json:"autoSkipIntro"`
+}
+
+// Audio configures audio output devices.
+type Audio struct {
+	// RememberVolume restores volume which was used with audio device when switching to it.
+	RememberVolume bool `json:"rememberVolume"`
+	// VolumesFile is path to file with volumes of audio devices. Empty means audio-volumes.json next to the configuration file.
+	VolumesFile string `json:"volumesFile"`
+}
+
+// Preferences configures remembering of track choices, delays and volume per file and per directory.
+type Preferences struct {
+	// Remember makes mpvrc remember choices made with the API and apply them when a file is loaded.
+	Remember bool `json:"remember"`
+	// File is path to preferences file. Empty means preferences.json next to the configuration file.
+	File string `json:"file"`
+}
+
+func Default() Config {
+	return Config{
+		Listen:   "0.0.0.0:8080",
+		LogLevel: "info",
+		Policy: Policy{
+			Commands: []string{
+				"get_property",
+				"set_property",
+				"cycle",
+				"add",
+				"seek",
+				"show-text",
+				"loadfile",
+				"playlist-next",
+				"playlist-prev",
+				"playlist-play-index",
+				"playlist-move",
+				"playlist-remove",
+				"playlist-shuffle",
+				"playlist-clear",
+				"stop",
+				"sub-add",
+				"audio-add",
+			},
+			Properties: []string{
+				"pause",
+				"volume",
+				"mute",
+				"speed",
+				"playback-time",
+				"time-pos",
+				"percent-pos",
+				"duration",
+				"path",
+				"filename",
+				"media-title",
+				"fullscreen",
+				"track-list",
+				"sub",
+				"sid",
+				"secondary-sid",
+				"audio",
+				"aid",
+				"video",
+				"vid",
+				"playlist",
+				"playlist-pos",
+				"chapter",
+				"chapter-list",
+				"sub-delay",
+				"audio-delay",
+				"sub-scale",
+				"sub-pos",
+				"demuxer-cache-state",
+				"metadata",
+				"audio-device",
+				"audio-device-list",
+			},
+		},
+		Media: Media{
+			Extensions: slices.Clone(media.DefaultExtensions),
+		},
+		History: History{
+			Resume: ResumeAuto,
+		},
+		Chapters: Chapters{
+			IntroPattern: `(?i)\b(opening|intro|op\d*)\b`,
+		},
+		Preferences: Preferences{
+			Remember: true,
+		},
+	}
+}
+
+// DefaultFile returns path to configuration file in the user configuration directory.
+func DefaultFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("get user config dir: %w", err)
+	}
+	return filepath.Join(dir, "mpvrc", "config.json"), nil
+}
+
+// Load returns effective configuration and positional command line arguments. args exclude program name.
+// Configuration file is read from -config flag, MPVRC_CONFIG environment variable or DefaultFile.
+// Values from the file are overridden by flags, flags are overridden by environment variables.
+func Load(args []string, getenv func(string) string) (Config, []string, error) {
+	cfg := Default()
+
+	// Flags are parsed twice: first time to find configuration file, second time to apply them on top of it.
+	file, err := configFile(args, getenv)
+	if err != nil {
+		return cfg, nil, err
+	}
+
+	if err := loadFile(file, &cfg); err != nil {
+		return cfg, nil, err
+	}
+	cfg.File = file
+
+	fs := NewFlagSet(&cfg, io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	if err := applyEnv(&cfg, getenv); err != nil {
+		return cfg, nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, nil, err
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+// NewFlagSet creates flag set which writes flag values into cfg. Usage and errors are printed to output.
+func NewFlagSet(cfg *Config, output io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("mpvrc", flag.ContinueOnError)
+	fs.SetOutput(output)
+
+	fs.String("config", "", "path to configuration file (env MPVRC_CONFIG)")
+	fs.StringVar(&cfg.Listen, "listen", cfg.Listen, "HTTP server address (env MPVRC_LISTEN)")
+	fs.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "log level: debug, info, warn or error (env MPVRC_LOG_LEVEL)")
+	fs.StringVar(&cfg.LogPath, "log-path", cfg.LogPath, "path to log file, mpvrc.log next to the executable by default (env MPVRC_LOG_PATH)")
+	fs.StringVar(&cfg.MPV.Path, "mpv-path", cfg.MPV.Path, "path to mpv executable, PATH and common install locations are searched by default (env MPVRC_MPV_PATH)")
+	fs.StringVar(&cfg.MPV.Profile, "profile", cfg.MPV.Profile, "mpv profile from mpv.conf (env MPVRC_PROFILE)")
+	fs.StringVar(&cfg.MPV.IPCServer, "ipc-server", cfg.MPV.IPCServer, "mpv IPC server path, generated from profile by default (env MPVRC_IPC_SERVER)")
+	fs.BoolVar(&cfg.TLS.Enabled, "tls", cfg.TLS.Enabled, "serve HTTPS (env MPVRC_TLS)")
+	fs.StringVar(&cfg.TLS.CertFile, "tls-cert", cfg.TLS.CertFile, "path to TLS certificate, self-signed certificate is generated by default (env MPVRC_TLS_CERT)")
+	fs.StringVar(&cfg.TLS.KeyFile, "tls-key", cfg.TLS.KeyFile, "path to TLS certificate key (env MPVRC_TLS_KEY)")
+	fs.StringVar(&cfg.TLS.RedirectListen, "tls-redirect", cfg.TLS.RedirectListen, "address of HTTP server which redirects to HTTPS (env MPVRC_TLS_REDIRECT)")
+	fs.Func("mpv-arg", "extra argument passed to mpv, can be repeated, appended to arguments from configuration file (env MPVRC_MPV_ARGS)", func(arg string) error {
+		cfg.MPV.Args = append(cfg.MPV.Args, arg)
+		return nil
+	})
+
+	return fs
+}
+
+func configFile(args []string, getenv func(string) string) (string, error) {
+	var discard Config
+	fs := NewFlagSet(&discard, os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+
+	if file := getenv("MPVRC_CONFIG"); file != "" {
+		return file, nil
+	}
+	if file := fs.Lookup("config").Value.String(); file != "" {
+		return file, nil
+	}
+	return DefaultFile()
+}
+
+// loadFile reads JSON configuration file into cfg. Missing file is not an error.
+func loadFile(file string, cfg *Config) error {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		slog.Debug("configuration file does not exist", "file", file)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("read configuration file: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("parse configuration file %q: %w", file, err)
+	}
+	return nil
+}
+
+func applyEnv(cfg *Config, getenv func(string) string) error {
+	for name, value := range map[string]*string{
+		"MPVRC_LISTEN":       &cfg.Listen,
+		"MPVRC_LOG_LEVEL":    &cfg.LogLevel,
+		"MPVRC_LOG_PATH":     &cfg.LogPath,
+		"MPVRC_MPV_PATH":     &cfg.MPV.Path,
+		"MPVRC_PROFILE":      &cfg.MPV.Profile,
+		"MPVRC_IPC_SERVER":   &cfg.MPV.IPCServer,
+		"MPVRC_TLS_CERT":     &cfg.TLS.CertFile,
+		"MPVRC_TLS_KEY":      &cfg.TLS.KeyFile,
+		"MPVRC_TLS_REDIRECT": &cfg.TLS.RedirectListen,
+	} {
+		if env := getenv(name); env != "" {
+			*value = env
+		}
+	}
+
+	if env := getenv("MPVRC_MPV_ARGS"); env != "" {
+		cfg.MPV.Args = strings.Fields(env)
+	}
+
+	if env := getenv("MPVRC_TLS"); env != "" {
+		enabled, err := strconv.ParseBool(env)
+		if err != nil {
+			return fmt.Errorf("invalid MPVRC_TLS value %q: %w", env, err)
+		}
+		cfg.TLS.Enabled = enabled
+	}
+	return nil
+}
+
+func (c Config) Validate() error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		errs = append(errs, fmt.Errorf("invalid listen address %q: %w", c.Listen, err))
+	}
+
+	if _, err := c.SlogLevel(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("both TLS certificate and key files must be set"))
+	}
+
+	if c.TLS.RedirectListen != "" {
+		if !c.TLS.Enabled {
+			errs = append(errs, errors.New("HTTPS redirect requires TLS to be enabled"))
+		}
+		if _, _, err := net.SplitHostPort(c.TLS.RedirectListen); err != nil {
+			errs = append(errs, fmt.Errorf("invalid HTTPS redirect address %q: %w", c.TLS.RedirectListen, err))
+		}
+	}
+
+	if len(c.Media.Extensions) == 0 {
+		errs = append(errs, errors.New("media extensions must not be empty"))
+	}
+
+	if !slices.Contains([]string{ResumeAuto, ResumePrompt, ResumeOff}, c.History.Resume) {
+		errs = append(errs, fmt.Errorf("invalid history resume mode %q, must be one of auto, prompt or off", c.History.Resume))
+	}
+
+	if _, err := regexp.Compile(c.Chapters.IntroPattern); err != nil {
+		errs = append(errs, fmt.Errorf("invalid intro chapter pattern: %w", err))
+	}
+
+	for _, root := range c.Policy.Roots {
+		if !filepath.IsAbs(root) {
+			errs = append(errs, fmt.Errorf("policy root %q must be absolute", root))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// TokensPath returns path to file with tokens of paired devices. Empty means tokens must not be persisted.
+func (c Config) TokensPath() string {
+	if c.TokensFile != "" {
+		return c.TokensFile
+	}
+	if c.File != "" {
+		return filepath.Join(filepath.Dir(c.File), "tokens.json")
+	}
+	return ""
+}
+
+// HistoryPath returns path to watch history file. Empty means history must not be persisted.
+func (c Config) HistoryPath() string {
+	if c.History.File != "" {
+		return c.History.File
+	}
+	if c.File != "" {
+		return filepath.Join(filepath.Dir(c.File), "history.json")
+	}
+	return ""
+}
+
+// AudioVolumesPath returns path to file with volumes of audio devices. Empty means volumes must not be persisted.
+func (c Config) AudioVolumesPath() string {
+	if c.Audio.VolumesFile != "" {
+		return c.Audio.VolumesFile
+	}
+	if c.File != "" {
+		return filepath.Join(filepath.Dir(c.File), "audio-volumes.json")
+	}
+	return ""
+}
+
+// PreferencesPath returns path to preferences file. Empty means preferences must not be persisted.
+func (c Config) PreferencesPath() string {
+	if c.Preferences.File != "" {
+		return c.Preferences.File
+	}
+	if c.File != "" {
+		return filepath.Join(filepath.Dir(c.File), "preferences.json")
+	}
+	return ""
+}
+
+// CertDir returns directory for generated TLS certificates. Empty means certificates must not be persisted.
+func (c Config) CertDir() string {
+	if c.TLS.CertDir != "" {
+		return c.TLS.CertDir
+	}
+	if c.File != "" {
+		return filepath.Join(filepath.Dir(c.File), "certs")
+	}
+	return ""
+}
+
+// SlogLevel returns LogLevel as slog.Level.
+func (c Config) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return level, fmt.Errorf("invalid log level %q, must be one of debug, info, warn or error", c.LogLevel)
+	}
+	return level, nil
+}
@@ -0,0 +1,167 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miere43/mpvrc/internal/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type configSuite struct {
+	suite.Suite
+	file string
+	env  map[string]string
+}
+
+func TestConfig(t *testing.T) {
+	suite.Run(t, new(configSuite))
+}
+
+func (s *configSuite) SetupTest() {
+	s.file = filepath.Join(s.T().TempDir(), "config.json")
+	s.env = map[string]string{"MPVRC_CONFIG": s.file}
+}
+
+func (s *configSuite) getenv(name string) string {
+	return s.env[name]
+}
+
+func (s *configSuite) writeFile(content string) {
+	s.Require().NoError(os.WriteFile(s.file, []byte(content), 0o644))
+}
+
+func (s *configSuite) TestDefaults() {
+	cfg, files, err := config.Load(nil, s.getenv)
+	s.Require().NoError(err)
+
+	expected := config.Default()
+	expected.File = s.file
+	s.Equal(expected, cfg)
+	s.Empty(files)
+}
+
+func (s *configSuite) TestFile() {
+	s.writeFile(`{"listen": "127.0.0.1:9000", "mpv": {"profile": "big", "args": ["--fs"]}}`)
+
+	cfg, _, err := config.Load(nil, s.getenv)
+	s.Require().NoError(err)
+	s.Equal("127.0.0.1:9000", cfg.Listen)
+	s.Equal("info", cfg.LogLevel)
+	s.Equal("big", cfg.MPV.Profile)
+	s.Equal([]string{"--fs"}, cfg.MPV.Args)
+}
+
+func (s *configSuite) TestFlagsOverrideFile() {
+	s.writeFile(`{"listen": "127.0.0.1:9000", "mpv": {"profile": "big", "args": ["--fs"]}}`)
+
+	cfg, files, err := config.Load([]string{"-listen", ":9001", "-mpv-arg", "--mute", "a.mkv", "b.mkv"}, s.getenv)
+	s.Require().NoError(err)
+	s.Equal(":9001", cfg.Listen)
+	s.Equal("big", cfg.MPV.Profile)
+	s.Equal([]string{"--fs", "--mute"}, cfg.MPV.Args)
+	s.Equal([]string{"a.mkv", "b.mkv"}, files)
+}
+
+func (s *configSuite) TestEnvOverridesFlags() {
+	s.env["MPVRC_LISTEN"] = ":9002"
+	s.env["MPVRC_LOG_LEVEL"] = "debug"
+	s.env["MPVRC_MPV_ARGS"] = "--fs --mute"
+
+	cfg, _, err := config.Load([]string{"-listen", ":9001", "-mpv-arg", "--ontop"}, s.getenv)
+	s.Require().NoError(err)
+	s.Equal(":9002", cfg.Listen)
+	s.Equal("debug", cfg.LogLevel)
+	s.Equal([]string{"--fs", "--mute"}, cfg.MPV.Args)
+}
+
+func (s *configSuite) TestConfigFlag() {
+	delete(s.env, "MPVRC_CONFIG")
+	s.writeFile(`{"mpv": {"ipcServer": "custom"}}`)
+
+	cfg, _, err := config.Load([]string{"-config", s.file}, s.getenv)
+	s.Require().NoError(err)
+	s.Equal(s.file, cfg.File)
+	s.Equal("custom", cfg.MPV.IPCServer)
+}
+
+func (s *configSuite) TestUnknownField() {
+	s.writeFile(`{"listne": ":9000"}`)
+
+	_, _, err := config.Load(nil, s.getenv)
+	s.ErrorContains(err, "listne")
+}
+
+func (s *configSuite) TestValidate() {
+	_, _, err := config.Load([]string{"-listen", "nope", "-log-level", "loud"}, s.getenv)
+	s.ErrorContains(err, "invalid listen address")
+	s.ErrorContains(err, "invalid log level")
+}
+
+func (s *configSuite) TestTLS() {
+	s.env["MPVRC_TLS"] = "true"
+
+	cfg, _, err := config.Load([]string{"-tls-redirect", ":80"}, s.getenv)
+	s.Require().NoError(err)
+	s.True(cfg.TLS.Enabled)
+	s.Equal(":80", cfg.TLS.RedirectListen)
+	s.Equal(filepath.Join(filepath.Dir(s.file), "certs"), cfg.CertDir())
+
+	_, _, err = config.Load([]string{"-tls-cert", "cert.pem"}, s.getenv)
+	s.ErrorContains(err, "both TLS certificate and key files must be set")
+
+	s.env["MPVRC_TLS"] = "maybe"
+	_, _, err = config.Load(nil, s.getenv)
+	s.ErrorContains(err, "MPVRC_TLS")
+}
+
+func (s *configSuite) TestMedia() {
+	s.writeFile(`{"media": {"extensions": ["mkv", "mp4"], "recursive": true}}`)
+
+	cfg, _, err := config.Load(nil, s.getenv)
+	s.Require().NoError(err)
+	s.Equal([]string{"mkv", "mp4"}, cfg.Media.Extensions)
+	s.True(cfg.Media.Recursive)
+
+	s.writeFile(`{"media": {"extensions": []}}`)
+	_, _, err = config.Load(nil, s.getenv)
+	s.ErrorContains(err, "media extensions must not be empty")
+}
+
+func (s *configSuite) TestHistory() {
+	cfg, _, err := config.Load(nil, s.getenv)
+	s.Require().NoError(err)
+	s.Equal(config.ResumeAuto, cfg.History.Resume)
+	s.Equal(filepath.Join(filepath.Dir(s.file), "history.json"), cfg.HistoryPath())
+
+	s.writeFile(`{"history": {"resume": "sometimes"}}`)
+	_, _, err = config.Load(nil, s.getenv)
+	s.ErrorContains(err, "invalid history resume mode")
+}
+
+func (s *configSuite) TestChapters() {
+	s.writeFile(`{"chapters": {"introPattern": "(intro"}}`)
+
+	_, _, err := config.Load(nil, s.getenv)
+	s.ErrorContains(err, "invalid intro chapter pattern")
+}
+
+func (s *configSuite) TestAudio() {
+	s.writeFile(`{"audio": {"rememberVolume": true}}`)
+
+	cfg, _, err := config.Load(nil, s.getenv)
+	s.Require().NoError(err)
+	s.True(cfg.Audio.RememberVolume)
+	s.Equal(filepath.Join(filepath.Dir(s.file), "audio-volumes.json"), cfg.AudioVolumesPath())
+}
+
+func (s *configSuite) TestPreferences() {
+	s.writeFile(`{"preferences": {"remember": false}}`)
+
+	cfg, _, err := config.Load(nil, s.getenv)
+	s.Require().NoError(err)
+	s.False(cfg.Preferences.Remember)
+	s.True(config.Default().Preferences.Remember)
+	s.Equal(filepath.Join(filepath.Dir(s.file), "preferences.json"), cfg.PreferencesPath())
+}
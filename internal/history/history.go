@@ -0,0 +1,207 @@
+// Package history keeps watch history: how far each file was played and when, so playback can be resumed.
+package history
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/jsonfile"
+)
+
+const (
+	// MaxEntries is how many files are remembered, the least recently played ones are forgotten first.
+	MaxEntries = 500
+	// SaveInterval is how long changes of progress are collected before they are written to disk.
+	SaveInterval = 30 * time.Second
+	// MinResumePosition is position in seconds before which files are started from the beginning.
+	MinResumePosition = 10
+	// CompletedRatio is part of the file after which the file counts as watched, so ending credits can be skipped.
+	CompletedRatio = 0.95
+)
+
+var ErrEntryNotFound = errors.New("history entry not found")
+
+// Entry is playback progress of a file. Position and Duration are in seconds.
+type Entry struct {
+	Path       string    `json:"path"`
+	Position   float64   `json:"position"`
+	Duration   float64   `json:"duration"`
+	LastPlayed time.Time `json:"lastPlayed"`
+	Completed  bool      `json:"completed"`
+}
+
+type Store struct {
+	m sync.Mutex
+	// entries are sorted from the most recently played one.
+	entries []Entry
+	// file saves entries, it is nil if history is not persisted.
+	file *jsonfile.Writer
+
+	now func() time.Time
+}
+
+// NewStore creates store which doesn't persist history.
+func NewStore() *Store {
+	return &Store{now: time.Now}
+}
+
+// LoadStore loads history from path. Changes are saved back to path.
+func LoadStore(path string) (*Store, error) {
+	s := NewStore()
+	s.file = jsonfile.NewWriter(path, SaveInterval)
+
+	if _, err := jsonfile.Read(path, &s.entries); err != nil {
+		return nil, fmt.Errorf("read history: %w", err)
+	}
+	return s, nil
+}
+
+// Update records that path is played at position. Changes are saved after SaveInterval, use Flush to
+// save them immediately.
+func (s *Store) Update(path string, position, duration float64) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	entry := s.take(path)
+	entry.Position = position
+	if duration > 0 {
+		entry.Duration = duration
+	}
+	entry.LastPlayed = s.now().UTC()
+	entry.Completed = entry.Duration > 0 && entry.Position >= entry.Duration*CompletedRatio
+	s.put(entry)
+	s.schedule()
+}
+
+// Complete marks path as watched to the end. Changes are saved like the ones of Update.
+func (s *Store) Complete(path string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	entry := s.take(path)
+	entry.LastPlayed = s.now().UTC()
+	entry.Completed = true
+	s.put(entry)
+	s.schedule()
+}
+
+func (s *Store) Entry(path string) (Entry, bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	index := s.index(path)
+	if index == -1 {
+		return Entry{}, false
+	}
+	return s.entries[index], true
+}
+
+// ResumePosition returns position from which playback of path should continue. Files which were
+// watched to the end or barely started are played from the beginning.
+func (s *Store) ResumePosition(path string) (float64, bool) {
+	entry, ok := s.Entry(path)
+	if !ok || entry.Completed || entry.Position < MinResumePosition {
+		return 0, false
+	}
+	return entry.Position, true
+}
+
+// Recent returns up to limit most recently played entries. With unfinished only files which can be
+// resumed are returned. Zero limit means no limit.
+func (s *Store) Recent(limit int, unfinished bool) []Entry {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	entries := make([]Entry, 0)
+	for _, entry := range s.entries {
+		if limit > 0 && len(entries) >= limit {
+			break
+		}
+		if unfinished && (entry.Completed || entry.Position < MinResumePosition) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func (s *Store) Remove(path string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	index := s.index(path)
+	if index == -1 {
+		return ErrEntryNotFound
+	}
+
+	entries := s.entries
+	s.entries = slices.Delete(slices.Clone(entries), index, index+1)
+	if err := s.save(); err != nil {
+		s.entries = entries
+		return err
+	}
+	return nil
+}
+
+func (s *Store) Clear() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	entries := s.entries
+	s.entries = nil
+	if err := s.save(); err != nil {
+		s.entries = entries
+		return err
+	}
+	return nil
+}
+
+// Flush saves changes which were not saved by Update or Complete yet.
+func (s *Store) Flush() error {
+	if s.file == nil {
+		return nil
+	}
+	if err := s.file.Flush(); err != nil {
+		return fmt.Errorf("write history: %w", err)
+	}
+	return nil
+}
+
+// take removes entry of path from entries or returns a new entry, it must be called with s.m locked.
+func (s *Store) take(path string) Entry {
+	index := s.index(path)
+	if index == -1 {
+		return Entry{Path: path}
+	}
+	entry := s.entries[index]
+	s.entries = slices.Delete(s.entries, index, index+1)
+	return entry
+}
+
+// put inserts entry as the most recently played one, it must be called with s.m locked.
+func (s *Store) put(entry Entry) {
+	s.entries = slices.Insert(s.entries, 0, entry)
+	if len(s.entries) > MaxEntries {
+		s.entries = s.entries[:MaxEntries]
+	}
+}
+
+func (s *Store) index(path string) int {
+	return slices.IndexFunc(s.entries, func(entry Entry) bool { return entry.Path == path })
+}
+
+// schedule saves copy of entries in background, it must be called with s.m locked.
+func (s *Store) schedule() {
+	if s.file != nil {
+		s.file.Schedule(slices.Clone(s.entries))
+	}
+}
+
+// save must be called with s.m locked.
+func (s *Store) save() error {
+	s.schedule()
+	return s.Flush()
+}
@@ -0,0 +1,134 @@
+package history
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type storeSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+func TestStore(t *testing.T) {
+	suite.Run(t, new(storeSuite))
+}
+
+func (s *storeSuite) SetupTest() {
+	s.now = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// newStore loads store from path, empty path creates store which doesn't persist history.
+func (s *storeSuite) newStore(path string) *Store {
+	store := NewStore()
+	if path != "" {
+		var err error
+		store, err = LoadStore(path)
+		s.Require().NoError(err)
+	}
+	store.now = func() time.Time { return s.now }
+	return store
+}
+
+func (s *storeSuite) TestResumePosition() {
+	store := s.newStore("")
+
+	_, ok := store.ResumePosition("/video/a.mkv")
+	s.False(ok)
+
+	store.Update("/video/a.mkv", 5, 1200)
+	_, ok = store.ResumePosition("/video/a.mkv")
+	s.False(ok, "barely started file is played from the beginning")
+
+	store.Update("/video/a.mkv", 600, 0)
+	position, ok := store.ResumePosition("/video/a.mkv")
+	s.True(ok)
+	s.Equal(600.0, position)
+
+	entry, ok := store.Entry("/video/a.mkv")
+	s.True(ok)
+	s.Equal(Entry{Path: "/video/a.mkv", Position: 600, Duration: 1200, LastPlayed: s.now}, entry, "unknown duration doesn't overwrite the known one")
+
+	store.Update("/video/a.mkv", 1150, 1200)
+	_, ok = store.ResumePosition("/video/a.mkv")
+	s.False(ok, "file is watched once ending credits start")
+
+	store.Update("/video/b.mkv", 60, 0)
+	store.Complete("/video/b.mkv")
+	_, ok = store.ResumePosition("/video/b.mkv")
+	s.False(ok)
+}
+
+func (s *storeSuite) TestRecent() {
+	r := s.Require()
+	store := s.newStore("")
+
+	store.Update("/video/a.mkv", 600, 1200)
+	s.now = s.now.Add(time.Hour)
+	store.Update("/video/b.mkv", 1200, 1200)
+	s.now = s.now.Add(time.Hour)
+	store.Update("/video/c.mkv", 30, 1200)
+
+	paths := func(entries []Entry) []string {
+		var paths []string
+		for _, entry := range entries {
+			paths = append(paths, entry.Path)
+		}
+		return paths
+	}
+	s.Equal([]string{"/video/c.mkv", "/video/b.mkv", "/video/a.mkv"}, paths(store.Recent(0, false)))
+	s.Equal([]string{"/video/c.mkv", "/video/b.mkv"}, paths(store.Recent(2, false)))
+	s.Equal([]string{"/video/c.mkv", "/video/a.mkv"}, paths(store.Recent(0, true)))
+
+	s.now = s.now.Add(time.Hour)
+	store.Update("/video/a.mkv", 700, 1200)
+	s.Equal([]string{"/video/a.mkv", "/video/c.mkv"}, paths(store.Recent(0, true)))
+	s.Equal(s.now, store.Recent(1, false)[0].LastPlayed)
+
+	r.NoError(store.Remove("/video/a.mkv"))
+	s.ErrorIs(store.Remove("/video/a.mkv"), ErrEntryNotFound)
+	s.Equal([]string{"/video/c.mkv", "/video/b.mkv"}, paths(store.Recent(0, false)))
+
+	r.NoError(store.Clear())
+	s.Empty(store.Recent(0, false))
+	s.NotNil(store.Recent(0, false), "empty history is encoded as JSON array")
+}
+
+func (s *storeSuite) TestOldEntriesAreForgotten() {
+	store := s.newStore("")
+
+	for i := range MaxEntries + 1 {
+		store.Update(fmt.Sprintf("/video/%d.mkv", i), 60, 1200)
+	}
+	s.Len(store.Recent(0, false), MaxEntries)
+	_, ok := store.Entry("/video/0.mkv")
+	s.False(ok)
+}
+
+func (s *storeSuite) TestHistoryIsPersisted() {
+	r := s.Require()
+	path := filepath.Join(s.T().TempDir(), "mpvrc", "history.json")
+	store := s.newStore(path)
+
+	store.Update("/video/a.mkv", 60, 1200)
+	store.Update("/video/a.mkv", 120, 1200)
+	s.Empty(s.newStore(path).Recent(0, false), "progress is saved in background")
+	r.NoError(store.Flush())
+	s.Equal(120.0, s.newStore(path).Recent(0, false)[0].Position)
+
+	store.Complete("/video/a.mkv")
+	r.NoError(store.Flush())
+	s.True(s.newStore(path).Recent(0, false)[0].Completed)
+
+	r.NoError(store.Remove("/video/a.mkv"))
+	s.Empty(s.newStore(path).Recent(0, false), "removed entries are saved immediately")
+	store.Update("/video/b.mkv", 60, 1200)
+	r.NoError(store.Flush())
+
+	r.NoError(store.Clear())
+	s.Empty(s.newStore(path).Recent(0, false))
+}
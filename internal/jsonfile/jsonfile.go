@@ -0,0 +1,100 @@
+// Package jsonfile reads and writes small JSON files where mpvrc keeps its state.
+package jsonfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Read decodes file at path into v. It returns false if file doesn't exist.
+func Read(path string, v any) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("parse %q: %w", path, err)
+	}
+	return true, nil
+}
+
+// Write replaces file at path with v encoded as JSON. File is replaced atomically, so it is never left
+// half-written. Missing directories are created.
+func Write(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Writer writes values to file in background, so frequent changes are written at most once per delay.
+// Only the latest value is written.
+type Writer struct {
+	path  string
+	delay time.Duration
+
+	m       sync.Mutex
+	pending any
+	timer   *time.Timer
+	// writeM keeps writes in the order in which values were scheduled.
+	writeM sync.Mutex
+}
+
+func NewWriter(path string, delay time.Duration) *Writer {
+	return &Writer{path: path, delay: delay}
+}
+
+// Schedule writes v to file after delay, unless a newer value is scheduled before that.
+// v must not be modified after it's scheduled.
+func (w *Writer) Schedule(v any) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	w.pending = v
+	if w.timer == nil {
+		w.timer = time.AfterFunc(w.delay, func() {
+			if err := w.Flush(); err != nil {
+				slog.Error("failed to write file", "path", w.path, "err", err)
+			}
+		})
+	}
+}
+
+// Flush writes scheduled value now.
+func (w *Writer) Flush() error {
+	w.writeM.Lock()
+	defer w.writeM.Unlock()
+
+	w.m.Lock()
+	v := w.pending
+	w.pending = nil
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	w.m.Unlock()
+
+	if v == nil {
+		return nil
+	}
+	return Write(w.path, v)
+}
@@ -0,0 +1,67 @@
+package jsonfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/jsonfile"
+	"github.com/stretchr/testify/suite"
+)
+
+type jsonFileSuite struct {
+	suite.Suite
+}
+
+func TestJSONFile(t *testing.T) {
+	suite.Run(t, new(jsonFileSuite))
+}
+
+func (s *jsonFileSuite) TestReadWrite() {
+	r := s.Require()
+	path := filepath.Join(s.T().TempDir(), "mpvrc", "state.json")
+
+	var value map[string]int
+	ok, err := jsonfile.Read(path, &value)
+	r.NoError(err)
+	s.False(ok)
+
+	r.NoError(jsonfile.Write(path, map[string]int{"a": 1}))
+	ok, err = jsonfile.Read(path, &value)
+	r.NoError(err)
+	s.True(ok)
+	s.Equal(map[string]int{"a": 1}, value)
+	s.NoFileExists(path + ".tmp")
+
+	r.NoError(os.WriteFile(path, []byte("{"), 0o600))
+	_, err = jsonfile.Read(path, &value)
+	s.ErrorContains(err, path)
+}
+
+func (s *jsonFileSuite) TestWriter() {
+	r := s.Require()
+	path := filepath.Join(s.T().TempDir(), "state.json")
+	w := jsonfile.NewWriter(path, 10*time.Millisecond)
+
+	w.Schedule(map[string]int{"a": 1})
+	w.Schedule(map[string]int{"a": 2})
+	s.NoFileExists(path)
+
+	var value map[string]int
+	s.Eventually(func() bool {
+		ok, err := jsonfile.Read(path, &value)
+		return err == nil && ok
+	}, time.Second, time.Millisecond)
+	s.Equal(map[string]int{"a": 2}, value)
+
+	// Flush writes immediately and does nothing when nothing is scheduled.
+	w.Schedule(map[string]int{"a": 3})
+	r.NoError(w.Flush())
+	_, err := jsonfile.Read(path, &value)
+	r.NoError(err)
+	s.Equal(map[string]int{"a": 3}, value)
+	r.NoError(os.Remove(path))
+	r.NoError(w.Flush())
+	s.NoFileExists(path)
+}
@@ -0,0 +1,111 @@
+// Package launcher finds mpv executable and builds command line to start it.
+package launcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Options describe how mpv should be started.
+type Options struct {
+	// Path to mpv executable. When empty, PATH and common install locations are searched.
+	Path string
+	// Profile is mpv profile name from mpv.conf. It is also used to generate IPC server name,
+	// so multiple profiles can run side by side.
+	Profile string
+	// Args are extra arguments passed to mpv before files.
+	Args []string
+}
+
+var ErrNotFound = errors.New("mpv executable not found")
+
+// FindExecutable returns path to mpv executable. explicit path is used if it is not empty,
+// otherwise PATH and common install locations for current OS are searched.
+func FindExecutable(explicit string) (string, error) {
+	if explicit != "" {
+		path, err := exec.LookPath(explicit)
+		if err != nil {
+			return "", fmt.Errorf("%w: %q is not an executable: %w", ErrNotFound, explicit, err)
+		}
+		return path, nil
+	}
+
+	if path, err := exec.LookPath(executableName); err == nil {
+		return path, nil
+	}
+
+	locations := commonLocations()
+	for _, location := range locations {
+		if info, err := os.Stat(location); err == nil && !info.IsDir() {
+			return location, nil
+		}
+	}
+
+	return "", fmt.Errorf(
+		"%w in PATH or in common install locations (%s), specify path to mpv with -mpv-path flag or MPVRC_MPV_PATH environment variable",
+		ErrNotFound,
+		strings.Join(locations, ", "),
+	)
+}
+
+// IPCServerName returns IPC server name for the profile, which is passed to mpv as
+// --input-ipc-server and used to connect to it.
+func IPCServerName(profile string) string {
+	name := "mpvrc"
+	if profile != "" {
+		name += "-" + sanitizeName(profile)
+	}
+	return ipcServerPath(name)
+}
+
+// CommandArgs returns full mpv command line arguments, excluding executable path.
+func (o Options) CommandArgs(ipcServer string, files []string) []string {
+	args := []string{"--force-window", "--idle", "--input-ipc-server=" + ipcServer}
+	if o.Profile != "" {
+		args = append(args, "--profile="+o.Profile)
+	}
+	args = append(args, o.Args...)
+	if len(files) > 0 {
+		// Stop option parsing, so files starting with "-" are not treated as options.
+		args = append(args, "--")
+		args = append(args, files...)
+	}
+	return args
+}
+
+// Command creates command which starts mpv.
+func (o Options) Command(ipcServer string, files []string) (*exec.Cmd, error) {
+	path, err := FindExecutable(o.Path)
+	if err != nil {
+		return nil, err
+	}
+	return exec.Command(path, o.CommandArgs(ipcServer, files)...), nil
+}
+
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func homeDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return home
+}
+
+func joinIfNotEmpty(base string, elem ...string) []string {
+	if base == "" {
+		return nil
+	}
+	return []string{filepath.Join(append([]string{base}, elem...)...)}
+}
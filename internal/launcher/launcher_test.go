@@ -0,0 +1,80 @@
+package launcher_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/miere43/mpvrc/internal/launcher"
+	"github.com/stretchr/testify/suite"
+)
+
+type launcherSuite struct {
+	suite.Suite
+}
+
+func TestLauncher(t *testing.T) {
+	suite.Run(t, new(launcherSuite))
+}
+
+func (s *launcherSuite) writeExecutable(dir string) string {
+	name := "mpv"
+	if runtime.GOOS == "windows" {
+		name = "mpv.exe"
+	}
+	path := filepath.Join(dir, name)
+	s.Require().NoError(os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755))
+	return path
+}
+
+func (s *launcherSuite) TestFindExecutableExplicit() {
+	path := s.writeExecutable(s.T().TempDir())
+
+	found, err := launcher.FindExecutable(path)
+	s.Require().NoError(err)
+	s.Equal(path, found)
+}
+
+func (s *launcherSuite) TestFindExecutableExplicitMissing() {
+	_, err := launcher.FindExecutable(filepath.Join(s.T().TempDir(), "missing", "mpv"))
+	s.ErrorIs(err, launcher.ErrNotFound)
+}
+
+func (s *launcherSuite) TestFindExecutableInPath() {
+	dir := s.T().TempDir()
+	path := s.writeExecutable(dir)
+	s.T().Setenv("PATH", dir)
+
+	found, err := launcher.FindExecutable("")
+	s.Require().NoError(err)
+	s.Equal(path, found)
+}
+
+func (s *launcherSuite) TestIPCServerName() {
+	s.NotEqual(launcher.IPCServerName(""), launcher.IPCServerName("anime"))
+	s.True(strings.HasSuffix(launcher.IPCServerName("my profile/1"), "mpvrc-my_profile_1"+ipcSuffix()))
+}
+
+func ipcSuffix() string {
+	if runtime.GOOS == "windows" {
+		return ""
+	}
+	return ".sock"
+}
+
+func (s *launcherSuite) TestCommandArgs() {
+	options := launcher.Options{
+		Profile: "anime",
+		Args:    []string{"--fs", "--volume=50"},
+	}
+
+	s.Equal([]string{
+		"--force-window", "--idle", "--input-ipc-server=/tmp/mpvrc-anime.sock",
+		"--profile=anime", "--fs", "--volume=50",
+		"--", "-video.mkv",
+	}, options.CommandArgs("/tmp/mpvrc-anime.sock", []string{"-video.mkv"}))
+
+	s.Equal([]string{"--force-window", "--idle", "--input-ipc-server=mpvrc"}, launcher.Options{}.CommandArgs("mpvrc", nil))
+}
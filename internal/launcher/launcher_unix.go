@@ -0,0 +1,24 @@
+//go:build unix
+
+package launcher
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const executableName = "mpv"
+
+func commonLocations() []string {
+	locations := []string{"/usr/bin/mpv", "/usr/local/bin/mpv", "/snap/bin/mpv"}
+	if runtime.GOOS == "darwin" {
+		locations = append(locations, "/opt/homebrew/bin/mpv", "/Applications/mpv.app/Contents/MacOS/mpv")
+	}
+	locations = append(locations, joinIfNotEmpty(homeDir(), ".local", "bin", "mpv")...)
+	return locations
+}
+
+func ipcServerPath(name string) string {
+	return filepath.Join(os.TempDir(), name+".sock")
+}
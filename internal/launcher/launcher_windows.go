@@ -0,0 +1,19 @@
+package launcher
+
+import "os"
+
+const executableName = "mpv.exe"
+
+func commonLocations() []string {
+	var locations []string
+	locations = append(locations, joinIfNotEmpty(os.Getenv("ProgramFiles"), "mpv", "mpv.exe")...)
+	locations = append(locations, joinIfNotEmpty(os.Getenv("ProgramFiles(x86)"), "mpv", "mpv.exe")...)
+	locations = append(locations, joinIfNotEmpty(os.Getenv("LOCALAPPDATA"), "Programs", "mpv", "mpv.exe")...)
+	locations = append(locations, joinIfNotEmpty(homeDir(), "scoop", "apps", "mpv", "current", "mpv.exe")...)
+	locations = append(locations, joinIfNotEmpty(os.Getenv("ProgramData"), "chocolatey", "bin", "mpv.exe")...)
+	return locations
+}
+
+func ipcServerPath(name string) string {
+	return `\\.\pipe\` + name
+}
@@ -0,0 +1,13 @@
+//go:build unix
+
+package media
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// IsHidden reports whether file is hidden. On Unix names of hidden files start with a dot.
+func IsHidden(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), ".")
+}
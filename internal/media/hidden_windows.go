@@ -0,0 +1,25 @@
+package media
+
+import (
+	"log/slog"
+	"syscall"
+)
+
+// IsHidden reports whether file has hidden attribute.
+func IsHidden(path string) bool {
+	pathW, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		slog.Error("failed to convert path to utf16", "err", err)
+		return false
+	}
+
+	attrs, err := syscall.GetFileAttributes(pathW)
+	if err != nil {
+		slog.Error("failed to get win32 file attributes", "err", err)
+		return false
+	}
+
+	hidden := (attrs & syscall.FILE_ATTRIBUTE_HIDDEN) != 0
+
+	return hidden
+}
@@ -0,0 +1,100 @@
+// Package media finds media files in directories and sorts them the way people number episodes.
+package media
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// DefaultExtensions are extensions of files which mpv can play, without the leading dot.
+var DefaultExtensions = []string{
+	"3gp", "avi", "flv", "m2ts", "m4v", "mkv", "mov", "mp4", "mpeg", "mpg", "ogv", "ts", "webm", "wmv",
+	"aac", "flac", "m4a", "mka", "mp3", "ogg", "opus", "wav", "wma",
+}
+
+var ErrNoFiles = errors.New("no media files")
+
+// Options control which files ListFiles returns.
+type Options struct {
+	// Extensions are extensions of media files without the leading dot, compared case-insensitively.
+	Extensions []string
+	// Recursive also lists files in subdirectories.
+	Recursive bool
+}
+
+// IsMedia reports whether path has one of media extensions.
+func (o Options) IsMedia(path string) bool {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	return ext != "" && slices.ContainsFunc(o.Extensions, func(mediaExt string) bool {
+		return strings.EqualFold(strings.TrimPrefix(mediaExt, "."), ext)
+	})
+}
+
+// ListFiles returns media files in dir in natural order. Hidden files and directories are skipped.
+// It returns error wrapping ErrNoFiles if there are no media files.
+func ListFiles(dir string, opts Options) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			// Unreadable subdirectory shouldn't prevent playing the rest.
+			return nil
+		}
+		if path == dir {
+			return nil
+		}
+
+		if IsHidden(path) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			if !opts.Recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.Type().IsRegular() || isSymlinkToFile(path, entry) {
+			if opts.IsMedia(path) {
+				files = append(files, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w in %q", ErrNoFiles, dir)
+	}
+
+	slices.SortFunc(files, func(a, b string) int {
+		return Compare(relative(dir, a), relative(dir, b))
+	})
+	return files, nil
+}
+
+func isSymlinkToFile(path string, entry fs.DirEntry) bool {
+	if entry.Type()&fs.ModeSymlink == 0 {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+func relative(dir, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
@@ -0,0 +1,105 @@
+package media_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/miere43/mpvrc/internal/media"
+	"github.com/stretchr/testify/suite"
+)
+
+type mediaSuite struct {
+	suite.Suite
+	dir string
+}
+
+func TestMedia(t *testing.T) {
+	suite.Run(t, new(mediaSuite))
+}
+
+func (s *mediaSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+}
+
+func (s *mediaSuite) createFiles(names ...string) {
+	for _, name := range names {
+		path := filepath.Join(s.dir, name)
+		s.Require().NoError(os.MkdirAll(filepath.Dir(path), 0o755))
+		s.Require().NoError(os.WriteFile(path, nil, 0o644))
+	}
+}
+
+func (s *mediaSuite) relative(paths []string) []string {
+	var names []string
+	for _, path := range paths {
+		rel, err := filepath.Rel(s.dir, path)
+		s.Require().NoError(err)
+		names = append(names, filepath.ToSlash(rel))
+	}
+	return names
+}
+
+func (s *mediaSuite) TestCompare() {
+	sorted := []string{
+		"Episode 1.mkv",
+		"Episode 02.mkv",
+		"episode 2.mkv",
+		"Episode 10.mkv",
+		"Episode 10v2.mkv",
+		"Episode 100.mkv",
+		"Episode 99999999999999999999999.mkv",
+		"Episode.mkv",
+		"OVA 1.mkv",
+	}
+	shuffled := slices.Clone(sorted)
+	slices.Reverse(shuffled)
+	slices.SortFunc(shuffled, media.Compare)
+	s.Equal(sorted, shuffled)
+
+	s.Equal(0, media.Compare("a1", "a1"))
+	s.Equal(-1, media.Compare("a", "a1"))
+	s.NotEqual(0, media.Compare("a01", "a1"), "different strings are never equal")
+}
+
+func (s *mediaSuite) TestListFiles() {
+	s.createFiles(
+		"Show - 10.mkv",
+		"Show - 2.MKV",
+		"Show - 1.mkv",
+		"Show - 1.en.ass",
+		"cover.jpg",
+		"Extras/Creditless OP.mkv",
+	)
+
+	files, err := media.ListFiles(s.dir, media.Options{Extensions: media.DefaultExtensions})
+	s.Require().NoError(err)
+	s.Equal([]string{"Show - 1.mkv", "Show - 2.MKV", "Show - 10.mkv"}, s.relative(files))
+
+	files, err = media.ListFiles(s.dir, media.Options{Extensions: []string{".mkv"}, Recursive: true})
+	s.Require().NoError(err)
+	s.Equal([]string{"Extras/Creditless OP.mkv", "Show - 1.mkv", "Show - 2.MKV", "Show - 10.mkv"}, s.relative(files))
+}
+
+func (s *mediaSuite) TestListFilesSkipsHidden() {
+	if runtime.GOOS == "windows" {
+		s.T().Skip("hidden files are marked with an attribute on Windows")
+	}
+	s.createFiles("a.mkv", ".b.mkv", ".trash/c.mkv")
+
+	files, err := media.ListFiles(s.dir, media.Options{Extensions: media.DefaultExtensions, Recursive: true})
+	s.Require().NoError(err)
+	s.Equal([]string{"a.mkv"}, s.relative(files))
+}
+
+func (s *mediaSuite) TestListFilesErrors() {
+	s.createFiles("notes.txt")
+
+	_, err := media.ListFiles(s.dir, media.Options{Extensions: media.DefaultExtensions})
+	s.ErrorIs(err, media.ErrNoFiles)
+
+	_, err = media.ListFiles(filepath.Join(s.dir, "missing"), media.Options{Extensions: media.DefaultExtensions})
+	s.ErrorIs(err, os.ErrNotExist)
+}
@@ -0,0 +1,62 @@
+package media
+
+import (
+	"cmp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Compare compares strings in natural order: case is ignored and runs of digits are compared
+// as numbers, so "Episode 2" goes before "Episode 10". It returns -1, 0 or +1 like strings.Compare.
+func Compare(a, b string) int {
+	if c := compareNatural(a, b); c != 0 {
+		return c
+	}
+	// Strings which only differ in case or leading zeros still need a stable order.
+	return strings.Compare(a, b)
+}
+
+func compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			var numA, numB string
+			numA, a = cutDigits(a)
+			numB, b = cutDigits(b)
+			if c := compareNumbers(numA, numB); c != 0 {
+				return c
+			}
+			continue
+		}
+
+		runeA, sizeA := utf8.DecodeRuneInString(a)
+		runeB, sizeB := utf8.DecodeRuneInString(b)
+		if c := cmp.Compare(unicode.ToLower(runeA), unicode.ToLower(runeB)); c != 0 {
+			return c
+		}
+		a, b = a[sizeA:], b[sizeB:]
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// compareNumbers compares decimal numbers of any length without parsing them.
+func compareNumbers(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return cmp.Compare(len(a), len(b))
+	}
+	return strings.Compare(a, b)
+}
+
+func cutDigits(s string) (digits, rest string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
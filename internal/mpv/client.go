@@ -0,0 +1,364 @@
+package mpv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Errors reported by mpv in command replies. CommandError unwraps to one of them.
+var (
+	ErrPropertyUnavailable = errors.New("property unavailable")
+	ErrPropertyNotFound    = errors.New("property not found")
+	ErrPropertyFormat      = errors.New("unsupported format for accessing property")
+	ErrInvalidParameter    = errors.New("invalid parameter")
+	ErrCommandFailed       = errors.New("error running command")
+)
+
+var commandErrors = []error{
+	ErrPropertyUnavailable,
+	ErrPropertyNotFound,
+	ErrPropertyFormat,
+	ErrInvalidParameter,
+	ErrCommandFailed,
+}
+
+// CommandError is returned when mpv replies to a command with an error.
+type CommandError struct {
+	Command []any
+	Message string
+}
+
+func (e *CommandError) Error() string {
+	if len(e.Command) == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%v: %s", e.Command[0], e.Message)
+}
+
+func (e *CommandError) Unwrap() error {
+	index := slices.IndexFunc(commandErrors, func(err error) bool { return err.Error() == e.Message })
+	if index == -1 {
+		return nil
+	}
+	return commandErrors[index]
+}
+
+type SeekMode string
+
+const (
+	SeekRelative      SeekMode = "relative"
+	SeekAbsolute      SeekMode = "absolute"
+	SeekRelativeExact SeekMode = "relative+exact"
+	SeekAbsoluteExact SeekMode = "absolute+exact"
+)
+
+type LoadFileMode string
+
+const (
+	LoadFileReplace        LoadFileMode = "replace"
+	LoadFileAppend         LoadFileMode = "append"
+	LoadFileAppendPlay     LoadFileMode = "append-play"
+	LoadFileInsertNext     LoadFileMode = "insert-next"
+	LoadFileInsertNextPlay LoadFileMode = "insert-next-play"
+)
+
+// TrackType is type of track in mpv "track-list" property.
+type TrackType string
+
+const (
+	TrackVideo TrackType = "video"
+	TrackAudio TrackType = "audio"
+	TrackSub   TrackType = "sub"
+	// TrackSecondarySub is not a type in "track-list", it selects "sub" track which is shown together
+	// with the main subtitles.
+	TrackSecondarySub TrackType = "secondary-sub"
+)
+
+// trackProperties are properties which select track of each type.
+var trackProperties = map[TrackType]string{
+	TrackVideo:        "vid",
+	TrackAudio:        "aid",
+	TrackSub:          "sid",
+	TrackSecondarySub: "secondary-sid",
+}
+
+// trackAddCommands are commands which load external file as track of type.
+var trackAddCommands = map[TrackType]string{
+	TrackAudio: "audio-add",
+	TrackSub:   "sub-add",
+}
+
+// TrackProperty returns name of property which selects track of type, e.g. "aid" for TrackAudio.
+func TrackProperty(trackType TrackType) (string, bool) {
+	name, ok := trackProperties[trackType]
+	return name, ok
+}
+
+// TrackAddCommand returns name of command which loads external file as track of type, e.g. "sub-add" for TrackSub.
+func TrackAddCommand(trackType TrackType) (string, bool) {
+	name, ok := trackAddCommands[trackType]
+	return name, ok
+}
+
+// PlaylistEntry is an element of mpv "playlist" property.
+type PlaylistEntry struct {
+	ID       int    `json:"id"`
+	Filename string `json:"filename"`
+	Title    string `json:"title,omitempty"`
+	Current  bool   `json:"current,omitempty"`
+	Playing  bool   `json:"playing,omitempty"`
+}
+
+// Track is an element of mpv "track-list" property.
+type Track struct {
+	ID               int     `json:"id"`
+	Type             string  `json:"type"`
+	SrcID            int     `json:"src-id"`
+	Title            string  `json:"title,omitempty"`
+	Lang             string  `json:"lang,omitempty"`
+	Default          bool    `json:"default"`
+	Forced           bool    `json:"forced"`
+	Selected         bool    `json:"selected"`
+	External         bool    `json:"external"`
+	ExternalFilename string  `json:"external-filename,omitempty"`
+	Codec            string  `json:"codec,omitempty"`
+	Decoder          string  `json:"decoder,omitempty"`
+	AudioChannels    int     `json:"audio-channels,omitempty"`
+	DemuxSamplerate  int     `json:"demux-samplerate,omitempty"`
+	DemuxW           int     `json:"demux-w,omitempty"`
+	DemuxH           int     `json:"demux-h,omitempty"`
+	DemuxFPS         float64 `json:"demux-fps,omitempty"`
+}
+
+// Chapter is an element of mpv "chapter-list" property. Time is in seconds.
+type Chapter struct {
+	Title string  `json:"title"`
+	Time  float64 `json:"time"`
+}
+
+// AudioDevice is an element of mpv "audio-device-list" property.
+type AudioDevice struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// GetProperty reads property name and decodes it into T.
+func GetProperty[T any](ctx context.Context, mpv *Conn, name string) (T, error) {
+	var value T
+
+	response, err := mpv.SendCommandContext(ctx, []any{"get_property", name}, false)
+	if err != nil {
+		return value, err
+	}
+
+	if err := json.Unmarshal(response.Data, &value); err != nil {
+		return value, fmt.Errorf("unmarshal property %q: %w", name, err)
+	}
+	return value, nil
+}
+
+// Command sends command and discards the result.
+func (mpv *Conn) Command(ctx context.Context, command ...any) error {
+	_, err := mpv.SendCommandContext(ctx, command, false)
+	return err
+}
+
+func (mpv *Conn) SetProperty(ctx context.Context, name string, value any) error {
+	return mpv.Command(ctx, "set_property", name, value)
+}
+
+func (mpv *Conn) SetPause(ctx context.Context, pause bool) error {
+	return mpv.SetProperty(ctx, "pause", pause)
+}
+
+func (mpv *Conn) Pause(ctx context.Context) error {
+	return mpv.SetPause(ctx, true)
+}
+
+func (mpv *Conn) Resume(ctx context.Context) error {
+	return mpv.SetPause(ctx, false)
+}
+
+func (mpv *Conn) Seek(ctx context.Context, d time.Duration, mode SeekMode) error {
+	return mpv.Command(ctx, "seek", d.Seconds(), string(mode))
+}
+
+// SetVolume sets volume in percent, 100 is the original volume.
+func (mpv *Conn) SetVolume(ctx context.Context, volume float64) error {
+	return mpv.SetProperty(ctx, "volume", volume)
+}
+
+func (mpv *Conn) SetSpeed(ctx context.Context, speed float64) error {
+	return mpv.SetProperty(ctx, "speed", speed)
+}
+
+// Stop stops playback and clears playlist.
+func (mpv *Conn) Stop(ctx context.Context) error {
+	return mpv.Command(ctx, "stop")
+}
+
+// Quit exits mpv. Connection may be closed before mpv replies, so ErrClosed is not reported.
+func (mpv *Conn) Quit(ctx context.Context) error {
+	if err := mpv.Command(ctx, "quit"); err != nil && !errors.Is(err, ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+func (mpv *Conn) PlaylistNext(ctx context.Context) error {
+	return mpv.Command(ctx, "playlist-next")
+}
+
+func (mpv *Conn) PlaylistPrev(ctx context.Context) error {
+	return mpv.Command(ctx, "playlist-prev")
+}
+
+// PlaylistPlayIndex starts playing playlist entry at index.
+func (mpv *Conn) PlaylistPlayIndex(ctx context.Context, index int) error {
+	return mpv.Command(ctx, "playlist-play-index", index)
+}
+
+// PlaylistMove moves playlist entry at index from, so it has index to after moving.
+func (mpv *Conn) PlaylistMove(ctx context.Context, from, to int) error {
+	// mpv moves entry to the place of entry at the second index, which shifts the target
+	// one position back when moving the entry forward.
+	if to > from {
+		to++
+	}
+	return mpv.Command(ctx, "playlist-move", from, to)
+}
+
+// PlaylistRemove removes playlist entry at index. mpv plays the next entry if the playing one is removed.
+func (mpv *Conn) PlaylistRemove(ctx context.Context, index int) error {
+	return mpv.Command(ctx, "playlist-remove", index)
+}
+
+func (mpv *Conn) PlaylistShuffle(ctx context.Context) error {
+	return mpv.Command(ctx, "playlist-shuffle")
+}
+
+// PlaylistClear removes all playlist entries except the playing one.
+func (mpv *Conn) PlaylistClear(ctx context.Context) error {
+	return mpv.Command(ctx, "playlist-clear")
+}
+
+// SelectTrack selects track id of trackType. Track IDs are from "track-list" property.
+func (mpv *Conn) SelectTrack(ctx context.Context, trackType TrackType, id int) error {
+	return mpv.setTrack(ctx, trackType, id)
+}
+
+// DisableTrack turns off track of trackType, e.g. hides subtitles.
+func (mpv *Conn) DisableTrack(ctx context.Context, trackType TrackType) error {
+	return mpv.setTrack(ctx, trackType, "no")
+}
+
+func (mpv *Conn) setTrack(ctx context.Context, trackType TrackType, value any) error {
+	name, ok := TrackProperty(trackType)
+	if !ok {
+		return fmt.Errorf("unknown track type %q", trackType)
+	}
+	return mpv.SetProperty(ctx, name, value)
+}
+
+// AddTrack loads external file at path as track of trackType and selects it.
+func (mpv *Conn) AddTrack(ctx context.Context, trackType TrackType, path string) error {
+	name, ok := TrackAddCommand(trackType)
+	if !ok {
+		return fmt.Errorf("external %q tracks are not supported", trackType)
+	}
+	return mpv.Command(ctx, name, path, "select")
+}
+
+// SetSubDelay delays subtitles by seconds, negative delay shows them earlier.
+func (mpv *Conn) SetSubDelay(ctx context.Context, delay float64) error {
+	return mpv.SetProperty(ctx, "sub-delay", delay)
+}
+
+// SetAudioDelay delays audio by seconds, negative delay plays it earlier.
+func (mpv *Conn) SetAudioDelay(ctx context.Context, delay float64) error {
+	return mpv.SetProperty(ctx, "audio-delay", delay)
+}
+
+// SetSubScale sets factor of subtitle font size, 1 is the original size.
+func (mpv *Conn) SetSubScale(ctx context.Context, scale float64) error {
+	return mpv.SetProperty(ctx, "sub-scale", scale)
+}
+
+// SetSubPos sets vertical position of subtitles in percent of screen height, 100 is the bottom.
+func (mpv *Conn) SetSubPos(ctx context.Context, pos float64) error {
+	return mpv.SetProperty(ctx, "sub-pos", pos)
+}
+
+// ShowText displays text on mpv OSD.
+func (mpv *Conn) ShowText(ctx context.Context, text string) error {
+	return mpv.Command(ctx, "show-text", text)
+}
+
+// LoadFile loads path with mode. opts are per-file options, e.g. "start" -> "30".
+// Passing options requires mpv 0.38 or newer.
+func (mpv *Conn) LoadFile(ctx context.Context, path string, mode LoadFileMode, opts map[string]string) error {
+	if len(opts) == 0 {
+		return mpv.Command(ctx, "loadfile", path, string(mode))
+	}
+
+	pairs := make([]string, 0, len(opts))
+	for key, value := range opts {
+		pairs = append(pairs, key+"="+value)
+	}
+	slices.Sort(pairs)
+
+	// -1 is playlist index, it is only used by "insert-at" modes.
+	return mpv.Command(ctx, "loadfile", path, string(mode), -1, strings.Join(pairs, ","))
+}
+
+// ObservePropertyContext makes mpv send PropertyChange events for property name, starting with its current value.
+// It returns observation ID for UnobserveProperty.
+func (mpv *Conn) ObservePropertyContext(ctx context.Context, name string) (int32, error) {
+	id := mpv.nextPropertyID.Add(1)
+	return id, mpv.Command(ctx, "observe_property", id, name)
+}
+
+func (mpv *Conn) UnobserveProperty(ctx context.Context, id int32) error {
+	return mpv.Command(ctx, "unobserve_property", id)
+}
+
+func (mpv *Conn) Playlist(ctx context.Context) ([]PlaylistEntry, error) {
+	return GetProperty[[]PlaylistEntry](ctx, mpv, "playlist")
+}
+
+func (mpv *Conn) Tracks(ctx context.Context) ([]Track, error) {
+	return GetProperty[[]Track](ctx, mpv, "track-list")
+}
+
+// SetChapter jumps to the start of chapter by index.
+func (mpv *Conn) SetChapter(ctx context.Context, index int) error {
+	return mpv.SetProperty(ctx, "chapter", index)
+}
+
+func (mpv *Conn) NextChapter(ctx context.Context) error {
+	return mpv.Command(ctx, "add", "chapter", 1)
+}
+
+// PrevChapter jumps to the previous chapter. Like mpv, it jumps to the start of the current chapter
+// if playback is not close to it.
+func (mpv *Conn) PrevChapter(ctx context.Context) error {
+	return mpv.Command(ctx, "add", "chapter", -1)
+}
+
+func (mpv *Conn) Chapters(ctx context.Context) ([]Chapter, error) {
+	return GetProperty[[]Chapter](ctx, mpv, "chapter-list")
+}
+
+func (mpv *Conn) AudioDevices(ctx context.Context) ([]AudioDevice, error) {
+	return GetProperty[[]AudioDevice](ctx, mpv, "audio-device-list")
+}
+
+// SetAudioDevice switches audio output to device by name, "auto" lets mpv choose.
+func (mpv *Conn) SetAudioDevice(ctx context.Context, name string) error {
+	return mpv.SetProperty(ctx, "audio-device", name)
+}
@@ -0,0 +1,213 @@
+package mpv_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/mpv"
+	"github.com/miere43/mpvrc/internal/mpv/mpvtest"
+	"github.com/stretchr/testify/suite"
+)
+
+type clientSuite struct {
+	suite.Suite
+	fake *mpvtest.Server
+	conn *mpv.Conn
+	ctx  context.Context
+}
+
+func TestClient(t *testing.T) {
+	suite.Run(t, new(clientSuite))
+}
+
+func (s *clientSuite) SetupTest() {
+	s.fake = mpvtest.NewServer()
+	s.ctx = context.Background()
+
+	events := make(chan mpv.Event)
+	go func() {
+		for range events {
+		}
+	}()
+
+	conn, err := mpv.Dial(s.fake.Dialer(), events, time.Second)
+	s.Require().NoError(err)
+	s.conn = conn
+}
+
+func (s *clientSuite) TearDownTest() {
+	s.conn.Disconnect()
+	s.fake.Close()
+}
+
+func (s *clientSuite) TestPauseResume() {
+	r := s.Require()
+	r.NoError(s.conn.Pause(s.ctx))
+	s.Equal(true, s.fake.Property("pause"))
+
+	r.NoError(s.conn.Resume(s.ctx))
+	s.Equal(false, s.fake.Property("pause"))
+}
+
+func (s *clientSuite) TestQuit() {
+	s.Require().NoError(s.conn.Quit(s.ctx))
+	commands := s.fake.Commands()
+	s.Equal([]any{"quit"}, commands[len(commands)-1])
+}
+
+func (s *clientSuite) TestGetProperty() {
+	r := s.Require()
+	r.NoError(s.conn.SetVolume(s.ctx, 55))
+
+	volume, err := mpv.GetProperty[float64](s.ctx, s.conn, "volume")
+	r.NoError(err)
+	s.Equal(55.0, volume)
+}
+
+func (s *clientSuite) TestGetPropertyErrors() {
+	_, err := mpv.GetProperty[string](s.ctx, s.conn, "path")
+	s.ErrorIs(err, mpv.ErrPropertyUnavailable)
+
+	_, err = mpv.GetProperty[string](s.ctx, s.conn, "no-such-property")
+	s.ErrorIs(err, mpv.ErrPropertyNotFound)
+
+	var commandErr *mpv.CommandError
+	s.Require().True(errors.As(err, &commandErr))
+	s.Equal("property not found", commandErr.Message)
+	s.Equal([]any{"get_property", "no-such-property"}, commandErr.Command)
+
+	_, err = mpv.GetProperty[string](s.ctx, s.conn, "volume")
+	s.Error(err, "volume is a number and can't be decoded into string")
+}
+
+func (s *clientSuite) TestSeek() {
+	r := s.Require()
+	r.Error(s.conn.Seek(s.ctx, 10*time.Second, mpv.SeekRelative), "nothing is playing")
+
+	r.NoError(s.conn.LoadFile(s.ctx, "/video/a.mkv", mpv.LoadFileReplace, nil))
+	r.NoError(s.conn.Seek(s.ctx, 30*time.Second, mpv.SeekAbsolute))
+	r.NoError(s.conn.Seek(s.ctx, -10*time.Second, mpv.SeekRelativeExact))
+
+	commands := s.fake.Commands()
+	s.Equal([]any{"seek", -10.0, "relative+exact"}, commands[len(commands)-1])
+	s.Equal(20.0, s.fake.Property("playback-time"))
+}
+
+func (s *clientSuite) TestLoadFile() {
+	r := s.Require()
+	r.NoError(s.conn.LoadFile(s.ctx, "/video/a.mkv", mpv.LoadFileReplace, nil))
+	s.Equal("/video/a.mkv", s.fake.Property("path"))
+
+	r.NoError(s.conn.LoadFile(s.ctx, "/video/b.mkv", mpv.LoadFileAppend, map[string]string{"start": "30", "pause": "yes"}))
+	commands := s.fake.Commands()
+	s.Equal([]any{"loadfile", "/video/b.mkv", "append", -1.0, "pause=yes,start=30"}, commands[len(commands)-1])
+}
+
+func (s *clientSuite) TestPlaylist() {
+	r := s.Require()
+	for _, path := range []string{"/video/a.mkv", "/video/b.mkv", "/video/c.mkv"} {
+		r.NoError(s.conn.LoadFile(s.ctx, path, mpv.LoadFileAppendPlay, nil))
+	}
+
+	filenames := func() []string {
+		playlist, err := s.conn.Playlist(s.ctx)
+		r.NoError(err)
+		var filenames []string
+		for _, entry := range playlist {
+			filenames = append(filenames, entry.Filename)
+		}
+		return filenames
+	}
+
+	r.NoError(s.conn.PlaylistMove(s.ctx, 0, 2))
+	s.Equal([]string{"/video/b.mkv", "/video/c.mkv", "/video/a.mkv"}, filenames())
+	r.NoError(s.conn.PlaylistMove(s.ctx, 2, 0))
+	s.Equal([]string{"/video/a.mkv", "/video/b.mkv", "/video/c.mkv"}, filenames())
+
+	r.NoError(s.conn.PlaylistPlayIndex(s.ctx, 1))
+	s.Equal("/video/b.mkv", s.fake.Property("path"))
+
+	r.NoError(s.conn.PlaylistRemove(s.ctx, 0))
+	s.Equal([]string{"/video/b.mkv", "/video/c.mkv"}, filenames())
+
+	r.NoError(s.conn.PlaylistClear(s.ctx))
+	s.Equal([]string{"/video/b.mkv"}, filenames())
+}
+
+func (s *clientSuite) TestSelectTrack() {
+	r := s.Require()
+	r.NoError(s.conn.SelectTrack(s.ctx, mpv.TrackAudio, 2))
+	s.Equal(2.0, s.fake.Property("aid"))
+
+	r.NoError(s.conn.DisableTrack(s.ctx, mpv.TrackSub))
+	s.Equal("no", s.fake.Property("sid"))
+
+	s.Error(s.conn.SelectTrack(s.ctx, "lyrics", 1))
+
+	r.NoError(s.conn.SelectTrack(s.ctx, mpv.TrackSecondarySub, 3))
+	s.Equal(3.0, s.fake.Property("secondary-sid"))
+}
+
+func (s *clientSuite) TestAddTrack() {
+	r := s.Require()
+	r.NoError(s.conn.AddTrack(s.ctx, mpv.TrackSub, "/video/a.en.srt"))
+	s.Equal(1.0, s.fake.Property("sid"))
+	s.Equal([]any{"sub-add", "/video/a.en.srt", "select"}, s.fake.Commands()[len(s.fake.Commands())-1])
+
+	tracks, err := s.conn.Tracks(s.ctx)
+	r.NoError(err)
+	s.Equal([]mpv.Track{{
+		ID:               1,
+		Type:             "sub",
+		Title:            "a.en.srt",
+		Selected:         true,
+		External:         true,
+		ExternalFilename: "/video/a.en.srt",
+	}}, tracks)
+
+	s.Error(s.conn.AddTrack(s.ctx, mpv.TrackVideo, "/video/a.mkv"))
+}
+
+func (s *clientSuite) TestTrackOptions() {
+	r := s.Require()
+	r.NoError(s.conn.SetSubDelay(s.ctx, -1.5))
+	r.NoError(s.conn.SetAudioDelay(s.ctx, 0.25))
+	r.NoError(s.conn.SetSubScale(s.ctx, 1.2))
+	r.NoError(s.conn.SetSubPos(s.ctx, 90))
+	s.Equal(-1.5, s.fake.Property("sub-delay"))
+	s.Equal(0.25, s.fake.Property("audio-delay"))
+	s.Equal(1.2, s.fake.Property("sub-scale"))
+	s.Equal(90.0, s.fake.Property("sub-pos"))
+}
+
+func (s *clientSuite) TestPlaylistTracksChapters() {
+	r := s.Require()
+	s.fake.SetProperty("playlist", []any{
+		map[string]any{"id": 1, "filename": "/video/a.mkv", "current": true, "playing": true},
+		map[string]any{"id": 2, "filename": "/video/b.mkv"},
+	})
+	s.fake.SetProperty("track-list", []any{
+		map[string]any{"id": 1, "type": "audio", "lang": "jpn", "selected": true, "audio-channels": 2},
+	})
+	s.fake.SetProperty("chapter-list", []any{
+		map[string]any{"title": "Opening", "time": 0.0},
+		map[string]any{"title": "Part A", "time": 90.5},
+	})
+
+	playlist, err := s.conn.Playlist(s.ctx)
+	r.NoError(err)
+	s.Equal([]mpv.PlaylistEntry{
+		{ID: 1, Filename: "/video/a.mkv", Current: true, Playing: true},
+		{ID: 2, Filename: "/video/b.mkv"},
+	}, playlist)
+
+	tracks, err := s.conn.Tracks(s.ctx)
+	r.NoError(err)
+	s.Equal([]mpv.Track{{ID: 1, Type: "audio", Lang: "jpn", Selected: true, AudioChannels: 2}}, tracks)
+
+	chapters, err := s.conn.Chapters(s.ctx)
+	r.NoError(err)
+	s.Equal([]mpv.Chapter{{Title: "Opening", Time: 0}, {Title: "Part A", Time: 90.5}}, chapters)
+}
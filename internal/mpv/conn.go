@@ -9,9 +9,6 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
-
-	"github.com/miere43/mpvrc/internal/pipe"
-	"github.com/miere43/mpvrc/internal/util"
 )
 
 type MpvCommand struct {
@@ -23,15 +20,18 @@ type MpvCommand struct {
 }
 
 type MpvResponse struct {
-	RequestID int32  `json:"request_id"`
-	Error     string `json:"error"`
-	Data      any    `json:"data"`
+	RequestID int32           `json:"request_id"`
+	Error     string          `json:"error"`
+	Data      json.RawMessage `json:"data"`
 }
 
+// ErrClosed is returned for commands which were not answered before connection to mpv was closed.
+var ErrClosed = errors.New("mpv connection closed")
+
 type Conn struct {
-	ctx   context.Context
-	conn  *pipe.Client
-	reads chan []byte
+	ctx       context.Context
+	transport Transport
+	reads     chan []byte
 
 	wg             *sync.WaitGroup
 	commands       chan *MpvCommand
@@ -41,26 +41,25 @@ type Conn struct {
 	waitingForResponse      map[int32]*MpvCommand
 	waitingForResponseMutex sync.Mutex
 
-	events chan any
+	events chan<- Event
 
 	nextPropertyID atomic.Int32
 
 	// mpvProcess *exec.Cmd
 }
 
-func Dial(events chan any, timeout time.Duration) (*Conn, error) {
-	const pipeName = "\\\\.\\pipe\\mpvsocket"
-
+// Dial connects to mpv IPC server using dialer. Events received from mpv are sent to events.
+func Dial(dialer Dialer, events chan<- Event, timeout time.Duration) (*Conn, error) {
 	reads := make(chan []byte)
-	conn, err := pipe.Dial(pipeName, timeout, reads)
+	transport, err := dialer(timeout, reads)
 	if err != nil {
 		return nil, err
 	}
 
 	mpv := &Conn{
-		ctx:   conn.Context(),
-		conn:  conn,
-		reads: reads,
+		ctx:       transport.Context(),
+		transport: transport,
+		reads:     reads,
 
 		wg:                 &sync.WaitGroup{},
 		commands:           make(chan *MpvCommand),
@@ -81,15 +80,14 @@ func (mpv *Conn) Context() context.Context {
 }
 
 func (mpv *Conn) Disconnect() {
-	if mpv.conn == nil {
+	if mpv.transport == nil {
 		slog.Debug("Disconnect: mpv already disconnected")
 		return
 	}
 
-	if err := mpv.conn.Close(); err != nil {
-		slog.Error("failed to close mpv named pipe", "err", err)
+	if err := mpv.transport.Close(); err != nil {
+		slog.Error("failed to close mpv transport", "err", err)
 	}
-	close(mpv.reads)
 	mpv.wg.Wait()
 }
 
@@ -99,8 +97,16 @@ func (mpv *Conn) registerWaitForResponse(cmd *MpvCommand) {
 	mpv.waitingForResponse[cmd.RequestID] = cmd
 }
 
+func (mpv *Conn) unregisterWaitForResponse(cmd *MpvCommand) {
+	mpv.waitingForResponseMutex.Lock()
+	defer mpv.waitingForResponseMutex.Unlock()
+	delete(mpv.waitingForResponse, cmd.RequestID)
+}
+
 func (mpv *Conn) setResponse(requestID int32, response MpvResponse) {
 	mpv.waitingForResponseMutex.Lock()
+	defer mpv.waitingForResponseMutex.Unlock()
+
 	cmd, ok := mpv.waitingForResponse[requestID]
 	if !ok {
 		slog.Warn("Got response for unknown request ID", "requestId", requestID)
@@ -108,27 +114,63 @@ func (mpv *Conn) setResponse(requestID int32, response MpvResponse) {
 	}
 
 	delete(mpv.waitingForResponse, cmd.RequestID)
-	mpv.waitingForResponseMutex.Unlock()
-
-	cmd.responseReady <- response
+	cmd.responseReady <- response // Never blocks because channel is buffered.
 }
 
-func (mpv *Conn) waitForResponse(cmd *MpvCommand) MpvResponse {
-	response := <-cmd.responseReady
-
+// failPendingResponses wakes up all commands waiting for response after connection was closed.
+func (mpv *Conn) failPendingResponses() {
 	mpv.waitingForResponseMutex.Lock()
 	defer mpv.waitingForResponseMutex.Unlock()
-	delete(mpv.waitingForResponse, cmd.RequestID)
 
-	return response
+	for requestID, cmd := range mpv.waitingForResponse {
+		close(cmd.responseReady)
+		delete(mpv.waitingForResponse, requestID)
+	}
+}
+
+func (mpv *Conn) waitForResponse(ctx context.Context, cmd *MpvCommand) (MpvResponse, error) {
+	select {
+	case response, ok := <-cmd.responseReady:
+		if !ok {
+			return MpvResponse{}, ErrClosed
+		}
+		return response, nil
+
+	case <-ctx.Done():
+		mpv.unregisterWaitForResponse(cmd)
+		return MpvResponse{}, fmt.Errorf("wait for mpv response: %w", ctx.Err())
+
+	case <-mpv.ctx.Done():
+		// Command may have been registered after pending responses were failed.
+		mpv.unregisterWaitForResponse(cmd)
+		select {
+		case response, ok := <-cmd.responseReady:
+			if ok {
+				return response, nil
+			}
+		default:
+		}
+		return MpvResponse{}, ErrClosed
+	}
 }
 
+// SendCommand sends command to mpv and waits for the response until connection is closed.
 func (mpv *Conn) SendCommand(command []any, async bool) (MpvResponse, error) {
+	return mpv.SendCommandContext(context.Background(), command, async)
+}
+
+// SendCommandContext sends command to mpv and waits for the response. It returns ctx error
+// if ctx is done before mpv replies and ErrClosed if connection is closed.
+func (mpv *Conn) SendCommandContext(ctx context.Context, command []any, async bool) (MpvResponse, error) {
+	if mpv.ctx.Err() != nil {
+		return MpvResponse{}, ErrClosed
+	}
+
 	cmd := &MpvCommand{
 		Command:       command,
 		RequestID:     mpv.nextRequestID(),
 		Async:         async,
-		responseReady: make(chan MpvResponse),
+		responseReady: make(chan MpvResponse, 1),
 	}
 
 	cmdJSON, err := json.Marshal(cmd)
@@ -139,16 +181,19 @@ func (mpv *Conn) SendCommand(command []any, async bool) (MpvResponse, error) {
 
 	mpv.registerWaitForResponse(cmd)
 
-	if err := mpv.conn.Write(cmdJSON); err != nil {
-		// TODO: unregisterWaitForResponse(cmd)
+	if err := mpv.transport.Write(cmdJSON); err != nil {
+		mpv.unregisterWaitForResponse(cmd)
 		return MpvResponse{}, fmt.Errorf("write command to MPV: %w", err)
 	}
 
-	response := mpv.waitForResponse(cmd)
+	response, err := mpv.waitForResponse(ctx, cmd)
+	if err != nil {
+		return response, err
+	}
 	if response.Error == "success" {
 		return response, nil
 	}
-	return response, errors.New(response.Error)
+	return response, &CommandError{Command: command, Message: response.Error}
 }
 
 func (mpv *Conn) ObserveProperty(property string) {
@@ -160,13 +205,14 @@ func (mpv *Conn) ObserveProperty(property string) {
 
 func (mpv *Conn) readResponses() {
 	defer mpv.wg.Done()
+	defer mpv.failPendingResponses()
 
 	// Stores incomplete messages
 	var buffer []byte
 
 	for {
 		select {
-		case <-mpv.conn.Context().Done():
+		case <-mpv.ctx.Done():
 			return
 
 		case partialRead := <-mpv.reads:
@@ -179,26 +225,47 @@ func (mpv *Conn) readResponses() {
 					break
 				}
 
-				event, err := ParseEvent(completeRead)
-				if errors.Is(err, ErrUnknownEvent) {
-					var response MpvResponse
-					if err := json.Unmarshal(completeRead, &response); err != nil {
-						util.Fatal("unmarshal response", "err", err, "response", string(completeRead))
-					}
-
-					if response.RequestID != 0 {
-						mpv.setResponse(response.RequestID, response)
-					}
-				} else if err != nil {
-					slog.Error("failed to parse MPV event", "err", err, "event", string(completeRead))
-				} else {
-					mpv.events <- event
+				if !mpv.handleMessage(completeRead) {
+					return
 				}
 			}
 		}
 	}
 }
 
+// handleMessage dispatches a single IPC message either to events channel or to the command
+// waiting for the reply. It returns false if connection was closed while sending event.
+func (mpv *Conn) handleMessage(message []byte) bool {
+	event, err := ParseEvent(message)
+	switch {
+	case errors.Is(err, ErrNotEvent):
+		var response MpvResponse
+		if err := json.Unmarshal(message, &response); err != nil {
+			slog.Error("failed to unmarshal MPV response", "err", err, "response", string(message))
+			return true
+		}
+
+		if response.RequestID != 0 {
+			mpv.setResponse(response.RequestID, response)
+		}
+
+	case errors.Is(err, ErrUnknownEvent):
+		slog.Debug("skipping unknown MPV event", "event", string(message))
+
+	case err != nil:
+		slog.Error("failed to parse MPV event", "err", err, "event", string(message))
+
+	default:
+		select {
+		case mpv.events <- event:
+		case <-mpv.ctx.Done():
+			return false
+		}
+	}
+
+	return true
+}
+
 func (mpv *Conn) nextRequestID() int32 {
 	return mpv._nextRequestID.Add(1)
 }
@@ -0,0 +1,119 @@
+package mpv_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/mpv"
+	"github.com/miere43/mpvrc/internal/pipe"
+	"github.com/stretchr/testify/suite"
+)
+
+type connSuite struct {
+	suite.Suite
+}
+
+func TestConn(t *testing.T) {
+	suite.Run(t, new(connSuite))
+}
+
+func (s *connSuite) dial(events chan mpv.Event) (*mpv.Conn, net.Conn) {
+	clientConn, serverConn := net.Pipe()
+	conn, err := mpv.Dial(func(timeout time.Duration, reads chan<- []byte) (mpv.Transport, error) {
+		return pipe.NewNetClient(clientConn, reads), nil
+	}, events, time.Second)
+	s.Require().NoError(err)
+	return conn, serverConn
+}
+
+func (s *connSuite) TestSendCommand() {
+	r := s.Require()
+	conn, server := s.dial(make(chan mpv.Event))
+	defer conn.Disconnect()
+
+	go func() {
+		scanner := bufio.NewScanner(server)
+		for scanner.Scan() {
+			var cmd mpv.MpvCommand
+			s.NoError(json.Unmarshal(scanner.Bytes(), &cmd))
+			fmt.Fprintf(server, `{"request_id":%d,"error":"success","data":%q}`+"\n", cmd.RequestID, cmd.Command[1])
+		}
+	}()
+
+	response, err := conn.SendCommand([]any{"get_property", "path"}, false)
+	r.NoError(err)
+	s.JSONEq(`"path"`, string(response.Data))
+
+	response, err = conn.SendCommand([]any{"get_property", "volume"}, false)
+	r.NoError(err)
+	s.JSONEq(`"volume"`, string(response.Data))
+}
+
+func (s *connSuite) TestEvents() {
+	events := make(chan mpv.Event)
+	conn, server := s.dial(events)
+	defer conn.Disconnect()
+
+	go fmt.Fprint(server, `{"event":"property-change","id":1,"name":"pause","data":true}`+"\n")
+
+	select {
+	case event := <-events:
+		change, ok := event.(mpv.PropertyChange)
+		s.Require().True(ok)
+		s.Equal("pause", change.Name)
+		s.JSONEq("true", string(change.Data))
+	case <-time.After(time.Second):
+		s.Fail("timed out waiting for event")
+	}
+}
+
+func (s *connSuite) TestRemoteClose() {
+	conn, server := s.dial(make(chan mpv.Event))
+	defer conn.Disconnect()
+
+	server.Close()
+
+	select {
+	case <-conn.Context().Done():
+	case <-time.After(time.Second):
+		s.Fail("context was not canceled after remote close")
+	}
+}
+
+func (s *connSuite) TestSendCommandContextTimeout() {
+	conn, server := s.dial(make(chan mpv.Event))
+	defer conn.Disconnect()
+
+	// Read commands but never reply.
+	go bufio.NewScanner(server).Scan()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := conn.SendCommandContext(ctx, []any{"get_property", "path"}, false)
+	s.True(errors.Is(err, context.DeadlineExceeded), "got %v", err)
+}
+
+func (s *connSuite) TestPendingCommandsFailOnClose() {
+	conn, server := s.dial(make(chan mpv.Event))
+	defer conn.Disconnect()
+
+	scanner := bufio.NewScanner(server)
+	go func() {
+		// Close connection after the command is received, without replying.
+		scanner.Scan()
+		server.Close()
+	}()
+
+	_, err := conn.SendCommand([]any{"get_property", "path"}, false)
+	s.True(errors.Is(err, mpv.ErrClosed), "got %v", err)
+
+	_, err = conn.SendCommand([]any{"get_property", "path"}, false)
+	s.True(errors.Is(err, mpv.ErrClosed), "got %v", err)
+}
@@ -6,6 +6,12 @@ import (
 	"fmt"
 )
 
+// Event is implemented by all events sent by mpv.
+type Event interface {
+	// Event returns mpv event name.
+	Event() string
+}
+
 type PropertyChange struct {
 	Id   int             `json:"id"`
 	Name string          `json:"name"`
@@ -16,11 +22,136 @@ func (PropertyChange) Event() string {
 	return "property-change"
 }
 
+// StartFile is sent when a new file is about to be loaded.
+type StartFile struct {
+	PlaylistEntryID int `json:"playlist_entry_id"`
+}
+
+func (StartFile) Event() string {
+	return "start-file"
+}
+
+type EndFileReason string
+
+const (
+	EndFileReasonEOF      EndFileReason = "eof"
+	EndFileReasonStop     EndFileReason = "stop"
+	EndFileReasonQuit     EndFileReason = "quit"
+	EndFileReasonError    EndFileReason = "error"
+	EndFileReasonRedirect EndFileReason = "redirect"
+	EndFileReasonUnknown  EndFileReason = "unknown"
+)
+
+// EndFile is sent when a file was unloaded. FileError is set when Reason is EndFileReasonError.
+type EndFile struct {
+	Reason                   EndFileReason `json:"reason"`
+	PlaylistEntryID          int           `json:"playlist_entry_id"`
+	FileError                string        `json:"file_error"`
+	PlaylistInsertID         int           `json:"playlist_insert_id"`
+	PlaylistInsertNumEntries int           `json:"playlist_insert_num_entries"`
+}
+
+func (EndFile) Event() string {
+	return "end-file"
+}
+
+type FileLoaded struct{}
+
+func (FileLoaded) Event() string {
+	return "file-loaded"
+}
+
+type Seek struct{}
+
+func (Seek) Event() string {
+	return "seek"
+}
+
+type PlaybackRestart struct{}
+
+func (PlaybackRestart) Event() string {
+	return "playback-restart"
+}
+
+type Shutdown struct{}
+
+func (Shutdown) Event() string {
+	return "shutdown"
+}
+
+type Idle struct{}
+
+func (Idle) Event() string {
+	return "idle"
+}
+
+// ClientMessage is sent by script-message command.
+type ClientMessage struct {
+	Args []string `json:"args"`
+}
+
+func (ClientMessage) Event() string {
+	return "client-message"
+}
+
+// LogMessage is sent after log messages were enabled with request_log_messages command.
+type LogMessage struct {
+	Prefix string `json:"prefix"`
+	Level  string `json:"level"`
+	Text   string `json:"text"`
+}
+
+func (LogMessage) Event() string {
+	return "log-message"
+}
+
+type VideoReconfig struct{}
+
+func (VideoReconfig) Event() string {
+	return "video-reconfig"
+}
+
+type AudioReconfig struct{}
+
+func (AudioReconfig) Event() string {
+	return "audio-reconfig"
+}
+
+// Hook is sent when hook registered with hook-add is run. mpv waits for hook-ack with HookID.
+type Hook struct {
+	Id     int   `json:"id"`
+	HookID int64 `json:"hook_id"`
+}
+
+func (Hook) Event() string {
+	return "hook"
+}
+
 var ErrUnknownEvent = errors.New("unknown mpv event")
 
+// ErrNotEvent is returned by ParseEvent for messages which are command replies.
+var ErrNotEvent = errors.New("not an mpv event")
+
+var eventParsers = map[string]func(event []byte) (Event, error){
+	"property-change":  parseEvent[PropertyChange],
+	"start-file":       parseEvent[StartFile],
+	"end-file":         parseEvent[EndFile],
+	"file-loaded":      parseEvent[FileLoaded],
+	"seek":             parseEvent[Seek],
+	"playback-restart": parseEvent[PlaybackRestart],
+	"shutdown":         parseEvent[Shutdown],
+	"idle":             parseEvent[Idle],
+	"client-message":   parseEvent[ClientMessage],
+	"log-message":      parseEvent[LogMessage],
+	"video-reconfig":   parseEvent[VideoReconfig],
+	"audio-reconfig":   parseEvent[AudioReconfig],
+	"hook":             parseEvent[Hook],
+}
+
 // ParseEvent parses a raw mpv event JSON and returns the corresponding event structure.
-// It returns an error if the event type is unknown or if parsing fails.
-func ParseEvent(event []byte) (any, error) {
+// It returns ErrNotEvent if the message is a command reply, ErrUnknownEvent if the event type
+// is unknown or another error if parsing fails.
+func ParseEvent(event []byte) (Event, error) {
 	var header struct {
 		Event string `json:"event"`
 	}
@@ -28,14 +159,21 @@ func ParseEvent(event []byte) (any, error) {
 		return nil, fmt.Errorf("failed to unmarshal mpv event header: %w", err)
 	}
 
-	switch header.Event {
-	case "property-change":
-		var change PropertyChange
-		if err := json.Unmarshal(event, &change); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal property-change event: %w", err)
-		}
-		return change, nil
+	if header.Event == "" {
+		return nil, ErrNotEvent
+	}
+
+	parse, ok := eventParsers[header.Event]
+	if !ok {
+		return nil, fmt.Errorf(`%w: "%s"`, ErrUnknownEvent, header.Event)
 	}
+	return parse(event)
+}
 
-	return nil, fmt.Errorf(`%w: "%s"`, ErrUnknownEvent, header.Event)
+func parseEvent[T Event](event []byte) (Event, error) {
+	var result T
+	if err := json.Unmarshal(event, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s event: %w", result.Event(), err)
+	}
+	return result, nil
 }
@@ -28,3 +28,43 @@ func (s *mpvSuite) TestParseEvent() {
 	s.Equal("playback-time", change.Name)
 	s.Nil(change.Data)
 }
+
+func (s *mpvSuite) TestParseEventTypes() {
+	for _, test := range []struct {
+		json string
+		want mpv.Event
+	}{
+		{`{"event":"start-file","playlist_entry_id":3}`, mpv.StartFile{PlaylistEntryID: 3}},
+		{
+			`{"event":"end-file","reason":"error","playlist_entry_id":3,"file_error":"unrecognized file format"}`,
+			mpv.EndFile{Reason: mpv.EndFileReasonError, PlaylistEntryID: 3, FileError: "unrecognized file format"},
+		},
+		{`{"event":"end-file","reason":"eof","playlist_entry_id":1}`, mpv.EndFile{Reason: mpv.EndFileReasonEOF, PlaylistEntryID: 1}},
+		{`{"event":"file-loaded"}`, mpv.FileLoaded{}},
+		{`{"event":"seek"}`, mpv.Seek{}},
+		{`{"event":"playback-restart"}`, mpv.PlaybackRestart{}},
+		{`{"event":"shutdown"}`, mpv.Shutdown{}},
+		{`{"event":"idle"}`, mpv.Idle{}},
+		{`{"event":"client-message","args":["mpvrc","hello"]}`, mpv.ClientMessage{Args: []string{"mpvrc", "hello"}}},
+		{`{"event":"log-message","prefix":"cplayer","level":"info","text":"Playing: a.mkv\n"}`, mpv.LogMessage{Prefix: "cplayer", Level: "info", Text: "Playing: a.mkv\n"}},
+		{`{"event":"video-reconfig"}`, mpv.VideoReconfig{}},
+		{`{"event":"audio-reconfig"}`, mpv.AudioReconfig{}},
+		{`{"event":"hook","id":2,"hook_id":17}`, mpv.Hook{Id: 2, HookID: 17}},
+	} {
+		s.Run(test.want.Event(), func() {
+			event, err := mpv.ParseEvent([]byte(test.json))
+			s.Require().NoError(err)
+			s.Equal(test.want, event)
+		})
+	}
+}
+
+func (s *mpvSuite) TestParseEventReply() {
+	_, err := mpv.ParseEvent([]byte(`{"request_id":1,"error":"success","data":null}`))
+	s.ErrorIs(err, mpv.ErrNotEvent)
+}
+
+func (s *mpvSuite) TestParseEventUnknown() {
+	_, err := mpv.ParseEvent([]byte(`{"event":"chapter-change"}`))
+	s.ErrorIs(err, mpv.ErrUnknownEvent)
+}
@@ -0,0 +1,252 @@
+package mpvtest
+
+import (
+	"errors"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/miere43/mpvrc/internal/mpv"
+)
+
+type playlistEntry struct {
+	id       int
+	filename string
+}
+
+var errNoEntry = errors.New("error running command")
+
+// playlistIndex converts command argument to playlist index. "current" is index of the playing entry.
+func (s *Server) playlistIndex(arg any) (int, bool) {
+	if arg == "current" {
+		return s.playlistPos, s.playlistPos != -1
+	}
+	index, ok := arg.(float64)
+	if !ok || index != float64(int(index)) || int(index) < 0 || int(index) >= len(s.playlist) {
+		return 0, false
+	}
+	return int(index), true
+}
+
+// updatePlaylist changes playlist with fn and publishes the result. fn returns index of entry to play,
+// -1 to stop playback or -2 to keep the playing entry.
+func (s *Server) updatePlaylist(fn func() (int, error)) error {
+	s.m.Lock()
+	play, err := fn()
+	if err != nil {
+		s.m.Unlock()
+		return err
+	}
+	if play != -2 {
+		s.playlistPos = play
+	}
+
+	playlist := make([]any, 0, len(s.playlist))
+	for i, entry := range s.playlist {
+		item := map[string]any{"id": entry.id, "filename": entry.filename}
+		if i == s.playlistPos {
+			item["current"] = true
+			item["playing"] = true
+		}
+		playlist = append(playlist, item)
+	}
+	pos := s.playlistPos
+	var playing *playlistEntry
+	if play >= 0 {
+		entry := s.playlist[play]
+		playing = &entry
+	}
+	s.m.Unlock()
+
+	s.SetProperty("playlist", playlist)
+	s.SetProperty("playlist-pos", pos)
+	switch {
+	case playing != nil:
+		s.Emit("start-file", map[string]any{"playlist_entry_id": playing.id})
+		s.SetProperty("path", playing.filename)
+		s.SetProperty("playback-time", 0.0)
+		s.Emit("file-loaded", nil)
+	case play == -1:
+		s.SetProperty("path", nil)
+		s.SetProperty("playback-time", nil)
+	}
+	return nil
+}
+
+// addEntry must be called with s.m locked.
+func (s *Server) addEntry(index int, filename string) {
+	s.nextEntryID++
+	s.playlist = slices.Insert(s.playlist, index, playlistEntry{id: s.nextEntryID, filename: filename})
+}
+
+func handleLoadFile(s *Server, args []any) (any, error) {
+	if len(args) < 1 {
+		return nil, errors.New("invalid parameter")
+	}
+	path, ok := args[0].(string)
+	if !ok {
+		return nil, errors.New("invalid parameter")
+	}
+	mode := string(mpv.LoadFileReplace)
+	if len(args) > 1 {
+		mode, _ = args[1].(string)
+	}
+
+	return nil, s.updatePlaylist(func() (int, error) {
+		idle := s.playlistPos == -1
+		switch mpv.LoadFileMode(mode) {
+		case mpv.LoadFileReplace:
+			s.playlist = nil
+			s.addEntry(0, path)
+			return 0, nil
+		case mpv.LoadFileAppend, mpv.LoadFileAppendPlay:
+			s.addEntry(len(s.playlist), path)
+			if idle && mode == string(mpv.LoadFileAppendPlay) {
+				return len(s.playlist) - 1, nil
+			}
+		case mpv.LoadFileInsertNext, mpv.LoadFileInsertNextPlay:
+			s.addEntry(s.playlistPos+1, path)
+			if idle && mode == string(mpv.LoadFileInsertNextPlay) {
+				return s.playlistPos + 1, nil
+			}
+		default:
+			return 0, errors.New("invalid parameter")
+		}
+		return -2, nil
+	})
+}
+
+func handleStop(s *Server, args []any) (any, error) {
+	s.m.Lock()
+	currentID := s.currentEntryID()
+	s.m.Unlock()
+
+	if currentID != 0 {
+		s.Emit("end-file", map[string]any{"reason": "stop", "playlist_entry_id": currentID})
+	}
+
+	return nil, s.updatePlaylist(func() (int, error) {
+		s.playlist = nil
+		return -1, nil
+	})
+}
+
+func handlePlaylistNext(s *Server, args []any) (any, error) {
+	return nil, s.updatePlaylist(func() (int, error) {
+		if s.playlistPos+1 >= len(s.playlist) {
+			return 0, errNoEntry
+		}
+		return s.playlistPos + 1, nil
+	})
+}
+
+func handlePlaylistPrev(s *Server, args []any) (any, error) {
+	return nil, s.updatePlaylist(func() (int, error) {
+		if s.playlistPos <= 0 {
+			return 0, errNoEntry
+		}
+		return s.playlistPos - 1, nil
+	})
+}
+
+func handlePlaylistPlayIndex(s *Server, args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, errors.New("invalid parameter")
+	}
+	return nil, s.updatePlaylist(func() (int, error) {
+		if args[0] == "none" {
+			return -1, nil
+		}
+		index, ok := s.playlistIndex(args[0])
+		if !ok {
+			return 0, errNoEntry
+		}
+		return index, nil
+	})
+}
+
+// handlePlaylistMove moves entry to the place of entry at the second index, like mpv does.
+func handlePlaylistMove(s *Server, args []any) (any, error) {
+	if len(args) != 2 {
+		return nil, errors.New("invalid parameter")
+	}
+	return nil, s.updatePlaylist(func() (int, error) {
+		from, ok := s.playlistIndex(args[0])
+		to, ok2 := args[1].(float64)
+		if !ok || !ok2 || to < 0 || int(to) > len(s.playlist) {
+			return 0, errNoEntry
+		}
+
+		currentID := s.currentEntryID()
+		entry := s.playlist[from]
+		s.playlist = slices.Delete(s.playlist, from, from+1)
+		target := int(to)
+		if target > from {
+			target--
+		}
+		s.playlist = slices.Insert(s.playlist, target, entry)
+
+		s.playlistPos = s.entryIndex(currentID)
+		return -2, nil
+	})
+}
+
+func handlePlaylistRemove(s *Server, args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, errors.New("invalid parameter")
+	}
+	return nil, s.updatePlaylist(func() (int, error) {
+		index, ok := s.playlistIndex(args[0])
+		if !ok {
+			return 0, errNoEntry
+		}
+
+		s.playlist = slices.Delete(s.playlist, index, index+1)
+		switch {
+		case index == s.playlistPos && index < len(s.playlist):
+			// mpv plays the next entry when the playing one is removed.
+			return index, nil
+		case index == s.playlistPos:
+			return -1, nil
+		case index < s.playlistPos:
+			s.playlistPos--
+		}
+		return -2, nil
+	})
+}
+
+func handlePlaylistShuffle(s *Server, args []any) (any, error) {
+	return nil, s.updatePlaylist(func() (int, error) {
+		currentID := s.currentEntryID()
+		rand.Shuffle(len(s.playlist), func(i, j int) {
+			s.playlist[i], s.playlist[j] = s.playlist[j], s.playlist[i]
+		})
+		s.playlistPos = s.entryIndex(currentID)
+		return -2, nil
+	})
+}
+
+// handlePlaylistClear removes all entries except the playing one.
+func handlePlaylistClear(s *Server, args []any) (any, error) {
+	return nil, s.updatePlaylist(func() (int, error) {
+		if s.playlistPos == -1 {
+			s.playlist = nil
+			return -2, nil
+		}
+		s.playlist = []playlistEntry{s.playlist[s.playlistPos]}
+		s.playlistPos = 0
+		return -2, nil
+	})
+}
+
+// currentEntryID returns ID of the playing entry or 0 if nothing is playing. It must be called with s.m locked.
+func (s *Server) currentEntryID() int {
+	if s.playlistPos == -1 {
+		return 0
+	}
+	return s.playlist[s.playlistPos].id
+}
+
+// entryIndex returns index of entry id or -1, it must be called with s.m locked.
+func (s *Server) entryIndex(id int) int {
+	return slices.IndexFunc(s.playlist, func(entry playlistEntry) bool { return entry.id == id })
+}
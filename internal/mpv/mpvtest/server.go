@@ -0,0 +1,543 @@
+// Package mpvtest provides an in-process fake mpv JSON IPC server for tests.
+package mpvtest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/mpv"
+	"github.com/miere43/mpvrc/internal/pipe"
+)
+
+// HandlerFunc handles a command sent to the fake mpv. Returned data is sent in "data" field of the reply,
+// returned error message is sent in "error" field.
+type HandlerFunc func(s *Server, args []any) (any, error)
+
+// Server is a fake mpv speaking JSON IPC protocol. Its zero value is not usable, use NewServer.
+type Server struct {
+	m          sync.Mutex
+	properties map[string]any
+	handlers   map[string]HandlerFunc
+	errors     map[string]string
+	commands   [][]any
+	sessions   []*clientSession
+	latency    time.Duration
+	closed     bool
+
+	// Playlist is changed by playlist commands, "playlist" property is its copy.
+	playlist    []playlistEntry
+	playlistPos int
+	nextEntryID int
+
+	// mpv executes commands one at a time. Notifications caused by a command are sent
+	// after the command reply, so they are collected in deferred while the command runs.
+	execM    sync.Mutex
+	deferred []func()
+	running  bool
+}
+
+type clientSession struct {
+	conn     net.Conn
+	m        sync.Mutex
+	observed map[int]string
+}
+
+// NewServer creates a fake mpv with a set of properties similar to idle mpv.
+func NewServer() *Server {
+	s := &Server{
+		properties: map[string]any{
+			"playback-time": nil,
+			"duration":      nil,
+			"pause":         false,
+			"volume":        100.0,
+			"path":          nil,
+			"speed":         1.0,
+			"track-list":    []any{},
+			"playlist":      []any{},
+			"playlist-pos":  -1,
+			"chapter-list":  []any{},
+			"chapter":       nil,
+			"audio-device":  "auto",
+			"audio-device-list": []any{
+				map[string]any{"name": "auto", "description": "Autoselect device"},
+			},
+			"vid":           false,
+			"aid":           false,
+			"sid":           false,
+			"secondary-sid": false,
+			"sub-delay":     0.0,
+			"audio-delay":   0.0,
+			"sub-scale":     1.0,
+			"sub-pos":       100.0,
+		},
+		handlers:    map[string]HandlerFunc{},
+		errors:      map[string]string{},
+		playlistPos: -1,
+	}
+
+	s.handlers["get_property"] = handleGetProperty
+	s.handlers["set_property"] = handleSetProperty
+	s.handlers["cycle"] = handleCycle
+	s.handlers["add"] = handleAdd
+	s.handlers["loadfile"] = handleLoadFile
+	s.handlers["seek"] = handleSeek
+	s.handlers["stop"] = handleStop
+	s.handlers["playlist-next"] = handlePlaylistNext
+	s.handlers["playlist-prev"] = handlePlaylistPrev
+	s.handlers["playlist-play-index"] = handlePlaylistPlayIndex
+	s.handlers["playlist-move"] = handlePlaylistMove
+	s.handlers["playlist-remove"] = handlePlaylistRemove
+	s.handlers["playlist-shuffle"] = handlePlaylistShuffle
+	s.handlers["playlist-clear"] = handlePlaylistClear
+	s.handlers["audio-add"] = handleTrackAdd(mpv.TrackAudio)
+	s.handlers["sub-add"] = handleTrackAdd(mpv.TrackSub)
+	s.handlers["show-text"] = func(s *Server, args []any) (any, error) { return nil, nil }
+	s.handlers["quit"] = func(s *Server, args []any) (any, error) { return nil, nil }
+
+	return s
+}
+
+// Dialer returns mpv.Dialer which connects to this server with in-memory connection.
+// Every dial creates a new independent client session, like connecting to the real mpv pipe again.
+func (s *Server) Dialer() mpv.Dialer {
+	return func(timeout time.Duration, reads chan<- []byte) (mpv.Transport, error) {
+		s.m.Lock()
+		defer s.m.Unlock()
+
+		if s.closed {
+			return nil, fmt.Errorf("fake mpv is not running: %w", os.ErrNotExist)
+		}
+
+		clientConn, serverConn := net.Pipe()
+		session := &clientSession{conn: serverConn, observed: map[int]string{}}
+		s.sessions = append(s.sessions, session)
+		go s.serve(session)
+
+		return pipe.NewNetClient(clientConn, reads), nil
+	}
+}
+
+// Handle registers or replaces handler for command name.
+func (s *Server) Handle(name string, handler HandlerFunc) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.handlers[name] = handler
+}
+
+// FailCommand makes every following command name reply with mpv error message. Empty message removes the failure.
+func (s *Server) FailCommand(name string, message string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if message == "" {
+		delete(s.errors, name)
+	} else {
+		s.errors[name] = message
+	}
+}
+
+// SetLatency delays every reply by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.latency = d
+}
+
+// Commands returns all commands received so far.
+func (s *Server) Commands() [][]any {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return slices.Clone(s.commands)
+}
+
+// Property returns current value of property name.
+func (s *Server) Property(name string) any {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.properties[name]
+}
+
+// SetProperty changes property value and notifies observers, as if the value was changed inside mpv.
+func (s *Server) SetProperty(name string, value any) {
+	s.m.Lock()
+	s.properties[name] = value
+	s.m.Unlock()
+
+	s.later(func() { s.notifyObservers(name, value) })
+}
+
+// Emit sends event to all connected clients. fields are merged into the event object.
+func (s *Server) Emit(event string, fields map[string]any) {
+	message := map[string]any{"event": event}
+	for key, value := range fields {
+		message[key] = value
+	}
+
+	s.later(func() {
+		for _, session := range s.activeSessions() {
+			session.send(message)
+		}
+	})
+}
+
+// Disconnect closes all client connections, as if mpv has been restarted. New dials are accepted.
+func (s *Server) Disconnect() {
+	s.m.Lock()
+	sessions := s.sessions
+	s.sessions = nil
+	s.m.Unlock()
+
+	for _, session := range sessions {
+		session.conn.Close()
+	}
+}
+
+// Close disconnects all clients and rejects following dials, as if mpv has exited.
+func (s *Server) Close() {
+	s.m.Lock()
+	s.closed = true
+	s.m.Unlock()
+
+	s.Disconnect()
+}
+
+// Reopen allows dialing the server again after Close.
+func (s *Server) Reopen() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.closed = false
+}
+
+// later runs fn after reply to the currently executed command is sent, or immediately if there is no such command.
+func (s *Server) later(fn func()) {
+	s.m.Lock()
+	if s.running {
+		s.deferred = append(s.deferred, fn)
+		s.m.Unlock()
+		return
+	}
+	s.m.Unlock()
+
+	fn()
+}
+
+func (s *Server) activeSessions() []*clientSession {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return slices.Clone(s.sessions)
+}
+
+func (s *Server) serve(session *clientSession) {
+	defer s.removeSession(session)
+
+	scanner := bufio.NewScanner(session.conn)
+	for scanner.Scan() {
+		var cmd mpv.MpvCommand
+		if err := json.Unmarshal(scanner.Bytes(), &cmd); err != nil {
+			session.send(map[string]any{"error": "invalid parameter"})
+			continue
+		}
+
+		s.execM.Lock()
+		s.m.Lock()
+		s.running = true
+		s.m.Unlock()
+
+		data, err := s.execute(session, cmd.Command)
+
+		reply := map[string]any{
+			"request_id": cmd.RequestID,
+			"error":      "success",
+		}
+		if err != nil {
+			reply["error"] = err.Error()
+		} else {
+			reply["data"] = data
+		}
+
+		session.send(reply)
+
+		s.m.Lock()
+		deferred := s.deferred
+		s.deferred = nil
+		s.running = false
+		s.m.Unlock()
+		s.execM.Unlock()
+
+		for _, fn := range deferred {
+			fn()
+		}
+	}
+}
+
+func (s *Server) removeSession(session *clientSession) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	session.conn.Close()
+	s.sessions = slices.DeleteFunc(s.sessions, func(other *clientSession) bool { return other == session })
+}
+
+func (s *Server) execute(session *clientSession, command []any) (any, error) {
+	if len(command) == 0 {
+		return nil, errors.New("invalid parameter")
+	}
+	name, _ := command[0].(string)
+
+	s.m.Lock()
+	s.commands = append(s.commands, command)
+	latency := s.latency
+	handler := s.handlers[name]
+	failure := s.errors[name]
+	s.m.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	if failure != "" {
+		return nil, errors.New(failure)
+	}
+
+	// Observations are stored per client session, so they can't be implemented with HandlerFunc.
+	switch name {
+	case "observe_property":
+		return s.observe(session, command[1:])
+	case "unobserve_property":
+		return s.unobserve(session, command[1:])
+	}
+
+	if handler == nil {
+		return nil, errors.New("invalid parameter")
+	}
+	return handler(s, command[1:])
+}
+
+func (s *Server) observe(session *clientSession, args []any) (any, error) {
+	if len(args) != 2 {
+		return nil, errors.New("invalid parameter")
+	}
+	id, ok := args[0].(float64)
+	name, ok2 := args[1].(string)
+	if !ok || !ok2 {
+		return nil, errors.New("invalid parameter")
+	}
+
+	session.m.Lock()
+	session.observed[int(id)] = name
+	session.m.Unlock()
+
+	s.m.Lock()
+	value := s.properties[name]
+	s.m.Unlock()
+
+	// mpv reports current value right after the property is observed.
+	s.later(func() { session.send(propertyChangeEvent(int(id), name, value)) })
+
+	return nil, nil
+}
+
+func (s *Server) unobserve(session *clientSession, args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, errors.New("invalid parameter")
+	}
+	id, ok := args[0].(float64)
+	if !ok {
+		return nil, errors.New("invalid parameter")
+	}
+
+	session.m.Lock()
+	defer session.m.Unlock()
+	delete(session.observed, int(id))
+	return nil, nil
+}
+
+func (s *Server) notifyObservers(name string, value any) {
+	for _, session := range s.activeSessions() {
+		session.m.Lock()
+		var ids []int
+		for id, observedName := range session.observed {
+			if observedName == name {
+				ids = append(ids, id)
+			}
+		}
+		session.m.Unlock()
+
+		for _, id := range ids {
+			session.send(propertyChangeEvent(id, name, value))
+		}
+	}
+}
+
+func propertyChangeEvent(id int, name string, value any) map[string]any {
+	event := map[string]any{
+		"event": "property-change",
+		"id":    id,
+		"name":  name,
+	}
+	if value != nil {
+		event["data"] = value
+	}
+	return event
+}
+
+func (session *clientSession) send(message any) {
+	messageJSON, err := json.Marshal(message)
+	if err != nil {
+		panic(fmt.Sprintf("mpvtest: failed to marshal message: %v", err))
+	}
+	messageJSON = append(messageJSON, '\n')
+
+	session.m.Lock()
+	defer session.m.Unlock()
+	// Errors are ignored because the client may disconnect at any time.
+	session.conn.Write(messageJSON)
+}
+
+func handleGetProperty(s *Server, args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, errors.New("invalid parameter")
+	}
+	name, _ := args[0].(string)
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	value, ok := s.properties[name]
+	if !ok {
+		return nil, errors.New("property not found")
+	}
+	if value == nil {
+		return nil, errors.New("property unavailable")
+	}
+	return value, nil
+}
+
+func handleSetProperty(s *Server, args []any) (any, error) {
+	if len(args) != 2 {
+		return nil, errors.New("invalid parameter")
+	}
+	name, _ := args[0].(string)
+
+	s.m.Lock()
+	_, ok := s.properties[name]
+	s.m.Unlock()
+	if !ok {
+		return nil, errors.New("property not found")
+	}
+
+	s.SetProperty(name, args[1])
+	return nil, nil
+}
+
+func handleCycle(s *Server, args []any) (any, error) {
+	if len(args) < 1 {
+		return nil, errors.New("invalid parameter")
+	}
+	name, _ := args[0].(string)
+
+	s.m.Lock()
+	value, ok := s.properties[name].(bool)
+	s.m.Unlock()
+	if !ok {
+		return nil, errors.New("property not found")
+	}
+
+	s.SetProperty(name, !value)
+	return nil, nil
+}
+
+func handleAdd(s *Server, args []any) (any, error) {
+	if len(args) < 1 {
+		return nil, errors.New("invalid parameter")
+	}
+	name, _ := args[0].(string)
+	change := 1.0
+	if len(args) > 1 {
+		change, _ = args[1].(float64)
+	}
+
+	s.m.Lock()
+	value, ok := s.properties[name].(float64)
+	s.m.Unlock()
+	if !ok {
+		return nil, errors.New("property unavailable")
+	}
+
+	s.SetProperty(name, value+change)
+	return nil, nil
+}
+
+// handleTrackAdd returns handler which adds external file as track of trackType and selects it.
+func handleTrackAdd(trackType mpv.TrackType) HandlerFunc {
+	return func(s *Server, args []any) (any, error) {
+		if len(args) < 1 {
+			return nil, errors.New("invalid parameter")
+		}
+		path, ok := args[0].(string)
+		if !ok {
+			return nil, errors.New("invalid parameter")
+		}
+
+		s.m.Lock()
+		tracks, _ := s.properties["track-list"].([]any)
+		id := 1
+		for _, track := range tracks {
+			if track, ok := track.(map[string]any); ok && track["type"] == string(trackType) {
+				id++
+			}
+		}
+		s.m.Unlock()
+
+		tracks = append(slices.Clone(tracks), map[string]any{
+			"id":                id,
+			"type":              string(trackType),
+			"title":             filepath.Base(path),
+			"selected":          true,
+			"external":          true,
+			"external-filename": path,
+		})
+		property, _ := mpv.TrackProperty(trackType)
+		s.SetProperty("track-list", tracks)
+		s.SetProperty(property, float64(id))
+		return nil, nil
+	}
+}
+
+func handleSeek(s *Server, args []any) (any, error) {
+	if len(args) < 1 {
+		return nil, errors.New("invalid parameter")
+	}
+	target, ok := args[0].(float64)
+	if !ok {
+		return nil, errors.New("invalid parameter")
+	}
+
+	s.m.Lock()
+	position, ok := s.properties["playback-time"].(float64)
+	s.m.Unlock()
+	if !ok {
+		// Nothing is playing.
+		return nil, errors.New("error running command")
+	}
+
+	mode := "relative"
+	if len(args) > 1 {
+		mode, _ = args[1].(string)
+	}
+	if mode == "relative" || mode == "relative+exact" {
+		target += position
+	}
+
+	s.Emit("seek", nil)
+	s.SetProperty("playback-time", max(target, 0))
+	s.Emit("playback-restart", nil)
+	return nil, nil
+}
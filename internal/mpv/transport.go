@@ -0,0 +1,45 @@
+package mpv
+
+import (
+	"context"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/pipe"
+)
+
+// Transport is a byte stream connection to mpv JSON IPC server.
+type Transport interface {
+	// Write sends data to mpv.
+	Write(data []byte) error
+	// Context is done when the connection is closed or lost.
+	Context() context.Context
+	Close() error
+}
+
+// Dialer establishes a new Transport. Data received from mpv must be sent to reads
+// until Transport context is done.
+type Dialer func(timeout time.Duration, reads chan<- []byte) (Transport, error)
+
+// PipeDialer connects to mpv started with --input-ipc-server=name. name is a named pipe path
+// on Windows and a Unix domain socket path on other platforms.
+func PipeDialer(name string) Dialer {
+	return func(timeout time.Duration, reads chan<- []byte) (Transport, error) {
+		client, err := pipe.Dial(name, timeout, reads)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+}
+
+// NetDialer connects to mpv IPC server exposed on network address, e.g. a TCP socket
+// forwarded with socat.
+func NetDialer(network, address string) Dialer {
+	return func(timeout time.Duration, reads chan<- []byte) (Transport, error) {
+		client, err := pipe.DialNet(network, address, timeout, reads)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+}
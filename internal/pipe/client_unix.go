@@ -0,0 +1,12 @@
+//go:build unix
+
+package pipe
+
+import "time"
+
+// Client is a Unix domain socket connection. It has the same API as named pipe client used on Windows.
+type Client = NetClient
+
+func Dial(name string, timeout time.Duration, reads chan<- []byte) (*Client, error) {
+	return DialNet("unix", name, timeout, reads)
+}
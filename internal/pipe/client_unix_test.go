@@ -0,0 +1,73 @@
+//go:build unix
+
+package pipe_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/pipe"
+	"github.com/stretchr/testify/suite"
+)
+
+type unixSuite struct {
+	suite.Suite
+}
+
+func TestUnix(t *testing.T) {
+	suite.Run(t, new(unixSuite))
+}
+
+func (s *unixSuite) TestDialMissingSocket() {
+	_, err := pipe.Dial(filepath.Join(s.T().TempDir(), "missing.sock"), 0, nil)
+	s.True(errors.Is(err, os.ErrNotExist), "got %v", err)
+}
+
+func (s *unixSuite) TestClientServerMessage() {
+	r := s.Require()
+	name := filepath.Join(s.T().TempDir(), "test.sock")
+
+	messages := make(chan []byte, 1)
+	server, err := pipe.NewServer(name, func(client *pipe.ConnectedClient) {
+		message, err := client.ReadMessage()
+		s.NoError(err)
+		messages <- message
+	})
+	r.NoError(err)
+	go server.Serve()
+
+	client, err := pipe.Dial(name, time.Second, nil)
+	r.NoError(err)
+	r.NoError(client.Write([]byte(`["mpvrc","video.mp4"]`)))
+	r.NoError(client.Close())
+
+	select {
+	case message := <-messages:
+		s.Equal(`["mpvrc","video.mp4"]`, string(message))
+	case <-time.After(time.Second):
+		s.Fail("timed out waiting for message")
+	}
+}
+
+func (s *unixSuite) TestCloseCancelsContext() {
+	r := s.Require()
+	name := filepath.Join(s.T().TempDir(), "test.sock")
+
+	_, err := pipe.NewServer(name, func(client *pipe.ConnectedClient) {})
+	r.NoError(err)
+
+	reads := make(chan []byte)
+	client, err := pipe.Dial(name, time.Second, reads)
+	r.NoError(err)
+	r.NoError(client.Close())
+
+	select {
+	case <-client.Context().Done():
+	case <-time.After(time.Second):
+		s.Fail("context was not canceled after close")
+	}
+	s.Error(client.Write([]byte("data")))
+}
@@ -19,7 +19,6 @@ type Client struct {
 	writes     chan []byte
 	ctx        context.Context
 	cancelCtx  context.CancelFunc
-	canceled   bool
 	wg         *sync.WaitGroup
 }
 
@@ -104,14 +103,15 @@ func (c *Client) readFromPipe(ctx context.Context) {
 	overlapped := &syscall.Overlapped{
 		HEvent: event,
 	}
-	overlappedDone := make(chan struct{})
+	// Overlapped operation may complete after the client is closed, so completion never blocks.
+	overlappedDone := make(chan struct{}, 1)
 
 	var buffer [1024 * 8]byte
 	for {
 		if err = syscall.ReadFile(c.pipeHandle, buffer[:], nil, overlapped); err != nil {
 			if !errors.Is(err, syscall.ERROR_IO_PENDING) {
 				slog.Error("failed to read from pipe", "err", err)
-				c.cancel()
+				c.cancelCtx()
 				return
 			}
 		}
@@ -120,7 +120,7 @@ func (c *Client) readFromPipe(ctx context.Context) {
 			bytesRead, err := winapi.GetOverlappedResult(c.pipeHandle, overlapped, true)
 			if err != nil {
 				slog.Error("failed to get overlapped result", "err", err)
-				c.cancel()
+				c.cancelCtx()
 				return
 			}
 
@@ -129,7 +129,11 @@ func (c *Client) readFromPipe(ctx context.Context) {
 
 			slog.Debug("Read operation completed", "bytesRead", bytesRead, "result", string(responseJSON))
 
-			c.reads <- responseJSON
+			select {
+			case c.reads <- responseJSON:
+			case <-ctx.Done():
+				return
+			}
 			overlappedDone <- struct{}{}
 		}()
 
@@ -154,7 +158,8 @@ func (c *Client) writeToPipe(ctx context.Context) {
 	overlapped := &syscall.Overlapped{
 		HEvent: event,
 	}
-	overlappedDone := make(chan struct{})
+	// Overlapped operation may complete after the client is closed, so completion never blocks.
+	overlappedDone := make(chan struct{}, 1)
 
 	for {
 		select {
@@ -190,15 +195,12 @@ func (c *Client) writeToPipe(ctx context.Context) {
 }
 
 func (c *Client) Write(data []byte) error {
-	if c.pipeHandle == 0 {
-		return errors.New("connection already closed")
-	}
-	if c.canceled {
+	select {
+	case c.writes <- data:
+		return nil
+	case <-c.ctx.Done():
 		return errors.New("connection is closed")
 	}
-	// TODO: this is jank. c.canceled may become true after this check
-	c.writes <- data
-	return nil
 }
 
 func (c *Client) Close() error {
@@ -206,8 +208,8 @@ func (c *Client) Close() error {
 		return errors.New("connection already closed")
 	}
 
+	// writes is not closed, so Write which runs concurrently returns an error instead of panicking.
 	c.cancelCtx()
-	close(c.writes)
 	c.wg.Wait()
 
 	if err := syscall.CloseHandle(c.pipeHandle); err != nil {
@@ -217,8 +219,3 @@ func (c *Client) Close() error {
 	c.pipeHandle = 0
 	return nil
 }
-
-func (c *Client) cancel() {
-	c.canceled = true
-	c.cancelCtx()
-}
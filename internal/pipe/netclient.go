@@ -0,0 +1,144 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// NetClient is a connection on top of net.Conn. It has the same API as named pipe Client
+// and is used for Unix domain sockets, TCP connections and in-memory connections created with net.Pipe.
+type NetClient struct {
+	conn      net.Conn
+	reads     chan<- []byte
+	ctx       context.Context
+	cancelCtx context.CancelFunc
+	closed    bool
+	m         sync.Mutex
+	wg        *sync.WaitGroup
+}
+
+// DialNet connects to the address on the named network. It keeps retrying until timeout
+// expires while nobody listens on the address.
+func DialNet(network, address string, timeout time.Duration, reads chan<- []byte) (*NetClient, error) {
+	maxInstant := time.Now().UTC().Add(timeout)
+
+	var conn net.Conn
+	var err error
+	for {
+		conn, err = net.Dial(network, address)
+		if err == nil {
+			break
+		}
+
+		// A socket file without a listener is left behind when the server crashes,
+		// treat it the same way as a missing socket.
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			err = fmt.Errorf("%w: %w", os.ErrNotExist, err)
+		}
+
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to connect to %s socket: %w", network, err)
+		} else if time.Now().UTC().After(maxInstant) {
+			return nil, fmt.Errorf("timed out while waiting for %s socket to become available: %w", network, err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return NewNetClient(conn, reads), nil
+}
+
+// NewNetClient takes ownership of already established connection.
+func NewNetClient(conn net.Conn, reads chan<- []byte) *NetClient {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &NetClient{
+		conn:      conn,
+		reads:     reads,
+		ctx:       ctx,
+		cancelCtx: cancel,
+		wg:        &sync.WaitGroup{},
+	}
+
+	if reads != nil {
+		client.wg.Add(1)
+		go client.readFromConn(ctx)
+	}
+
+	return client
+}
+
+func (c *NetClient) Context() context.Context {
+	return c.ctx
+}
+
+func (c *NetClient) readFromConn(ctx context.Context) {
+	defer c.wg.Done()
+	defer c.cancelCtx()
+
+	var buffer [1024 * 8]byte
+	for {
+		bytesRead, err := c.conn.Read(buffer[:])
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("failed to read from socket", "err", err)
+			}
+			return
+		}
+
+		responseJSON := make([]byte, bytesRead)
+		copy(responseJSON, buffer[:bytesRead])
+
+		slog.Debug("Read operation completed", "bytesRead", bytesRead, "result", string(responseJSON))
+
+		select {
+		case c.reads <- responseJSON:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *NetClient) Write(data []byte) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.closed {
+		return errors.New("connection already closed")
+	}
+	if c.ctx.Err() != nil {
+		return errors.New("connection is closed")
+	}
+
+	slog.Debug("Preparing to write to socket", "writeBytes", len(data), "data", string(data))
+	if _, err := c.conn.Write(data); err != nil {
+		c.cancelCtx()
+		return fmt.Errorf("failed to write to socket: %w", err)
+	}
+	return nil
+}
+
+func (c *NetClient) Close() error {
+	c.m.Lock()
+	if c.closed {
+		c.m.Unlock()
+		return errors.New("connection already closed")
+	}
+	c.closed = true
+	c.m.Unlock()
+
+	c.cancelCtx()
+	err := c.conn.Close()
+	c.wg.Wait()
+
+	if err != nil {
+		return fmt.Errorf("failed to close socket: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package pipe_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/pipe"
+	"github.com/stretchr/testify/suite"
+)
+
+type netClientSuite struct {
+	suite.Suite
+}
+
+func TestNetClient(t *testing.T) {
+	suite.Run(t, new(netClientSuite))
+}
+
+func (s *netClientSuite) TestReadWrite() {
+	r := s.Require()
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+
+	reads := make(chan []byte)
+	client := pipe.NewNetClient(clientConn, reads)
+
+	go func() {
+		buffer := make([]byte, 64)
+		n, err := serverConn.Read(buffer)
+		s.NoError(err)
+		serverConn.Write(buffer[:n])
+	}()
+
+	r.NoError(client.Write([]byte("ping")))
+
+	select {
+	case read := <-reads:
+		s.Equal("ping", string(read))
+	case <-time.After(time.Second):
+		s.Fail("timed out waiting for read")
+	}
+
+	r.NoError(client.Close())
+	s.Error(client.Close())
+}
+
+func (s *netClientSuite) TestRemoteCloseCancelsContext() {
+	clientConn, serverConn := net.Pipe()
+
+	client := pipe.NewNetClient(clientConn, make(chan []byte))
+	serverConn.Close()
+
+	select {
+	case <-client.Context().Done():
+	case <-time.After(time.Second):
+		s.Fail("context was not canceled after remote close")
+	}
+	s.Error(client.Write([]byte("data")))
+	s.NoError(client.Close())
+}
@@ -0,0 +1,70 @@
+//go:build unix
+
+package pipe
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+
+	"github.com/miere43/mpvrc/internal/util"
+)
+
+type Server struct {
+	name          string
+	listener      net.Listener
+	newClientFunc func(client *ConnectedClient)
+}
+
+func NewServer(name string, newClientFunc func(client *ConnectedClient)) (*Server, error) {
+	s := &Server{
+		name:          name,
+		newClientFunc: newClientFunc,
+	}
+
+	// Remove socket file left behind by previous instance, otherwise listen fails with EADDRINUSE.
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale unix socket %q: %w", name, err)
+	}
+
+	listener, err := net.Listen("unix", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on unix socket %q: %w", name, err)
+	}
+	s.listener = listener
+	return s, nil
+}
+
+func (s *Server) Serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			util.Fatal("pipe server failed to connect client", "err", err)
+		}
+
+		slog.Info("received new unix socket client")
+
+		go func(client *ConnectedClient) {
+			s.newClientFunc(client)
+
+			client.conn.Close()
+			client.conn = nil
+		}(&ConnectedClient{conn})
+	}
+}
+
+type ConnectedClient struct {
+	conn net.Conn
+}
+
+// ReadMessage reads everything the client has written until it closes the connection.
+func (s *ConnectedClient) ReadMessage() ([]byte, error) {
+	message, err := io.ReadAll(s.conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from unix socket: %w", err)
+	}
+	return message, nil
+}
@@ -0,0 +1,269 @@
+// Package policy decides which mpv commands remote clients are allowed to send.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/miere43/mpvrc/internal/config"
+	"github.com/miere43/mpvrc/internal/mpv"
+)
+
+var ErrForbidden = errors.New("forbidden")
+
+type Policy struct {
+	commands   map[string]bool
+	properties map[string]bool
+	roots      []string
+}
+
+func New(cfg config.Policy) *Policy {
+	p := &Policy{
+		commands:   make(map[string]bool),
+		properties: make(map[string]bool),
+	}
+	for _, command := range cfg.Commands {
+		p.commands[command] = true
+	}
+	for _, property := range cfg.Properties {
+		p.properties[property] = true
+	}
+	for _, root := range cfg.Roots {
+		p.roots = append(p.roots, filepath.Clean(root))
+	}
+	return p
+}
+
+// Roots returns directories from which files can be opened. Empty means any local file.
+func (p *Policy) Roots() []string {
+	return p.roots
+}
+
+// Check returns an error wrapping ErrForbidden if command can't be sent to mpv.
+func (p *Policy) Check(command []any) error {
+	if len(command) == 0 {
+		return fmt.Errorf("%w: empty command", ErrForbidden)
+	}
+
+	name, ok := command[0].(string)
+	if !ok {
+		return fmt.Errorf("%w: command name must be a string", ErrForbidden)
+	}
+	if !p.commands[name] {
+		return fmt.Errorf("%w: command %q is not allowed", ErrForbidden, name)
+	}
+
+	args := command[1:]
+	for i, arg := range args {
+		switch arg.(type) {
+		case string, bool, float64, int:
+		default:
+			return fmt.Errorf("%w: %s: argument %d must be a string, number or boolean", ErrForbidden, name, i+1)
+		}
+	}
+
+	validate, ok := validators[name]
+	if !ok {
+		return nil
+	}
+	if err := validate(p, args); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrForbidden, name, err)
+	}
+	return nil
+}
+
+// CheckProperty returns an error wrapping ErrForbidden if property can't be read or changed.
+func (p *Policy) CheckProperty(name string) error {
+	if !p.properties[name] {
+		return fmt.Errorf("%w: property %q is not allowed", ErrForbidden, name)
+	}
+	return nil
+}
+
+// CheckPath returns an error wrapping ErrForbidden if path is not inside of allowed directories.
+func (p *Policy) CheckPath(path string) error {
+	if err := p.checkPath(path); err != nil {
+		return fmt.Errorf("%w: %w", ErrForbidden, err)
+	}
+	return nil
+}
+
+var validators = map[string]func(p *Policy, args []any) error{
+	"get_property": func(p *Policy, args []any) error {
+		return p.checkPropertyCommand(args, 1, 1)
+	},
+	"set_property": func(p *Policy, args []any) error {
+		return p.checkPropertyCommand(args, 2, 2)
+	},
+	"cycle": func(p *Policy, args []any) error {
+		return p.checkPropertyCommand(args, 1, 2)
+	},
+	"add": func(p *Policy, args []any) error {
+		return p.checkPropertyCommand(args, 1, 2)
+	},
+	"seek": func(p *Policy, args []any) error {
+		return checkArgCount(args, 1, 2)
+	},
+	"show-text": func(p *Policy, args []any) error {
+		return checkArgCount(args, 1, 3)
+	},
+	"playlist-next": func(p *Policy, args []any) error {
+		return checkArgCount(args, 0, 1)
+	},
+	"playlist-prev": func(p *Policy, args []any) error {
+		return checkArgCount(args, 0, 1)
+	},
+	"stop": func(p *Policy, args []any) error {
+		return checkArgCount(args, 0, 1)
+	},
+	"quit": func(p *Policy, args []any) error {
+		return checkArgCount(args, 0, 1)
+	},
+	"playlist-play-index": func(p *Policy, args []any) error {
+		if err := checkArgCount(args, 1, 1); err != nil {
+			return err
+		}
+		return checkIndex(args[0], "current", "none")
+	},
+	"playlist-move": func(p *Policy, args []any) error {
+		if err := checkArgCount(args, 2, 2); err != nil {
+			return err
+		}
+		if err := checkIndex(args[0]); err != nil {
+			return err
+		}
+		return checkIndex(args[1])
+	},
+	"playlist-remove": func(p *Policy, args []any) error {
+		if err := checkArgCount(args, 1, 1); err != nil {
+			return err
+		}
+		return checkIndex(args[0], "current")
+	},
+	"playlist-shuffle": func(p *Policy, args []any) error {
+		return checkArgCount(args, 0, 0)
+	},
+	"playlist-clear": func(p *Policy, args []any) error {
+		return checkArgCount(args, 0, 0)
+	},
+	"sub-add": func(p *Policy, args []any) error {
+		return p.checkTrackAdd(args)
+	},
+	"audio-add": func(p *Policy, args []any) error {
+		return p.checkTrackAdd(args)
+	},
+	"loadfile": func(p *Policy, args []any) error {
+		// Per-file options are not allowed, they can be used to load scripts.
+		if err := checkArgCount(args, 1, 2); err != nil {
+			return err
+		}
+
+		path, _ := args[0].(string)
+		if err := p.checkPath(path); err != nil {
+			return err
+		}
+
+		if len(args) == 2 && !slices.Contains(loadFileModes, args[1]) {
+			return fmt.Errorf("unsupported mode %v", args[1])
+		}
+		return nil
+	},
+}
+
+var loadFileModes = []any{
+	string(mpv.LoadFileReplace),
+	string(mpv.LoadFileAppend),
+	string(mpv.LoadFileAppendPlay),
+	string(mpv.LoadFileInsertNext),
+	string(mpv.LoadFileInsertNextPlay),
+}
+
+var trackAddFlags = []any{"select", "auto", "cached"}
+
+// checkTrackAdd checks arguments of "sub-add" and "audio-add". Title and language arguments are not allowed.
+func (p *Policy) checkTrackAdd(args []any) error {
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return err
+	}
+
+	path, _ := args[0].(string)
+	if err := p.checkPath(path); err != nil {
+		return err
+	}
+
+	if len(args) == 2 && !slices.Contains(trackAddFlags, args[1]) {
+		return fmt.Errorf("unsupported flag %v", args[1])
+	}
+	return nil
+}
+
+func checkArgCount(args []any, min, max int) error {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return fmt.Errorf("expected %d arguments, got %d", min, len(args))
+		}
+		return fmt.Errorf("expected %d to %d arguments, got %d", min, max, len(args))
+	}
+	return nil
+}
+
+// checkIndex allows non-negative integer playlist index or one of names.
+func checkIndex(arg any, names ...string) error {
+	switch arg := arg.(type) {
+	case int:
+		if arg >= 0 {
+			return nil
+		}
+	case float64:
+		if arg >= 0 && arg == math.Trunc(arg) {
+			return nil
+		}
+	case string:
+		if slices.Contains(names, arg) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid playlist index %v", arg)
+}
+
+func (p *Policy) checkPropertyCommand(args []any, min, max int) error {
+	if err := checkArgCount(args, min, max); err != nil {
+		return err
+	}
+
+	property, _ := args[0].(string)
+	if !p.properties[property] {
+		return fmt.Errorf("property %q is not allowed", property)
+	}
+	return nil
+}
+
+// checkPath only allows absolute local paths, which also rules out URLs and mpv protocols like "av://".
+func (p *Policy) checkPath(path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("path %q must be absolute", path)
+	}
+	if len(p.roots) == 0 {
+		return nil
+	}
+
+	path = filepath.Clean(path)
+	for _, root := range p.roots {
+		if isInside(root, path) {
+			return nil
+		}
+	}
+	return fmt.Errorf("path %q is outside of allowed directories", path)
+}
+
+func isInside(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
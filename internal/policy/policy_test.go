@@ -0,0 +1,103 @@
+package policy_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/miere43/mpvrc/internal/config"
+	"github.com/miere43/mpvrc/internal/policy"
+	"github.com/stretchr/testify/suite"
+)
+
+type policySuite struct {
+	suite.Suite
+	root   string
+	policy *policy.Policy
+}
+
+func TestPolicy(t *testing.T) {
+	suite.Run(t, new(policySuite))
+}
+
+func (s *policySuite) SetupTest() {
+	s.root = s.T().TempDir()
+
+	cfg := config.Default().Policy
+	cfg.Roots = []string{s.root}
+	s.policy = policy.New(cfg)
+}
+
+func (s *policySuite) TestAllowed() {
+	for _, command := range [][]any{
+		{"get_property", "playback-time"},
+		{"set_property", "pause", true},
+		{"set_property", "volume", 50.0},
+		{"cycle", "sub"},
+		{"seek", 10.0, "relative+exact"},
+		{"show-text", "Volume: 50%"},
+		{"loadfile", filepath.Join(s.root, "video.mkv")},
+		{"loadfile", filepath.Join(s.root, "dir", "video.mkv"), "append"},
+		{"playlist-next"},
+		{"playlist-play-index", 2.0},
+		{"playlist-play-index", "none"},
+		{"playlist-move", 0, 3},
+		{"playlist-remove", "current"},
+		{"playlist-shuffle"},
+		{"playlist-clear"},
+		{"sub-add", filepath.Join(s.root, "video.en.srt"), "select"},
+		{"audio-add", filepath.Join(s.root, "video.commentary.mka")},
+		{"set_property", "secondary-sid", 2.0},
+		{"set_property", "sub-pos", 90.0},
+	} {
+		s.NoError(s.policy.Check(command), "%v", command)
+	}
+}
+
+func (s *policySuite) TestForbidden() {
+	for _, command := range [][]any{
+		{},
+		{42.0},
+		{"run", "calc.exe"},
+		{"subprocess", "rm"},
+		{"load-script", "evil.lua"},
+		{"get_property", "input-ipc-server"},
+		{"set_property", "script-opts", "x=y"},
+		{"set_property", "pause"},
+		{"cycle", "scripts"},
+		{"show-text", map[string]any{"text": "hi"}},
+		{"loadfile", "video.mkv"},
+		{"loadfile", "https://example.com/video.mkv"},
+		{"loadfile", filepath.Join(s.root, "..", "video.mkv")},
+		{"loadfile", filepath.Join(s.root, "video.mkv"), "replace", -1.0, "script=evil.lua"},
+		{"loadfile", filepath.Join(s.root, "video.mkv"), "insert-at"},
+		{"playlist-play-index", -1.0},
+		{"playlist-play-index", 1.5},
+		{"playlist-move", 0},
+		{"playlist-remove", "none"},
+		{"playlist-shuffle", "yes"},
+		{"sub-add", "video.en.srt"},
+		{"sub-add", filepath.Join(s.root, "..", "video.en.srt")},
+		{"sub-add", filepath.Join(s.root, "video.en.srt"), "select", "English"},
+		{"audio-add", filepath.Join(s.root, "video.mka"), "replace"},
+		{"quit"},
+	} {
+		err := s.policy.Check(command)
+		s.True(errors.Is(err, policy.ErrForbidden), "%v: got %v", command, err)
+	}
+}
+
+func (s *policySuite) TestQuit() {
+	cfg := config.Default().Policy
+	cfg.Commands = append(cfg.Commands, "quit")
+	p := policy.New(cfg)
+	s.NoError(p.Check([]any{"quit"}))
+	s.NoError(p.Check([]any{"quit", 4.0}))
+	s.ErrorIs(p.Check([]any{"quit", 4.0, "now"}), policy.ErrForbidden)
+}
+
+func (s *policySuite) TestAnyPathWithoutRoots() {
+	p := policy.New(config.Default().Policy)
+	s.NoError(p.CheckPath(filepath.Join(s.root, "..", "video.mkv")))
+	s.ErrorIs(p.CheckPath("video.mkv"), policy.ErrForbidden)
+}
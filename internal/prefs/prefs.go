@@ -0,0 +1,282 @@
+// Package prefs remembers track choices, delays and volume per file and per directory, so every episode
+// of a series plays with the same audio and subtitles.
+package prefs
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miere43/mpvrc/internal/jsonfile"
+	"github.com/miere43/mpvrc/internal/mpv"
+)
+
+var ErrNotFound = errors.New("preferences not found")
+
+// SaveDelay is how long remembered changes are collected before they are written to disk.
+const SaveDelay = 5 * time.Second
+
+// Scope is what preferences are remembered for.
+type Scope string
+
+const (
+	ScopeFile Scope = "file"
+	// ScopeDirectory preferences apply to all files in the directory, e.g. episodes of a series.
+	ScopeDirectory Scope = "directory"
+)
+
+// TrackRule selects the first track whose language and title match. Empty fields match any track.
+type TrackRule struct {
+	// Lang is track language as reported by mpv, e.g. "jpn". It is compared case-insensitively.
+	Lang string `json:"lang,omitempty"`
+	// Title is regular expression which track title must match.
+	Title string `json:"title,omitempty"`
+	// ExcludeTitle is regular expression which track title must not match, e.g. "(?i)signs".
+	ExcludeTitle string `json:"excludeTitle,omitempty"`
+	// Off turns tracks off instead of selecting one.
+	Off bool `json:"off,omitempty"`
+}
+
+// RuleFor returns rule which selects track, titled tracks are matched by the whole title.
+func RuleFor(track mpv.Track) TrackRule {
+	rule := TrackRule{Lang: track.Lang}
+	if track.Title != "" {
+		rule.Title = "^" + regexp.QuoteMeta(track.Title) + "$"
+	}
+	return rule
+}
+
+func (r TrackRule) Validate() error {
+	if _, err := regexp.Compile(r.Title); err != nil {
+		return fmt.Errorf("invalid title pattern: %w", err)
+	}
+	if _, err := regexp.Compile(r.ExcludeTitle); err != nil {
+		return fmt.Errorf("invalid excluded title pattern: %w", err)
+	}
+	return nil
+}
+
+// Match returns ID of the first track of trackType which matches the rule. Rule with invalid patterns
+// doesn't match anything.
+func (r TrackRule) Match(tracks []mpv.Track, trackType mpv.TrackType) (int, bool) {
+	if r.Off || r.Validate() != nil {
+		return 0, false
+	}
+	title := regexp.MustCompile(r.Title)
+	exclude := regexp.MustCompile(r.ExcludeTitle)
+
+	for _, track := range tracks {
+		if track.Type != string(trackType) {
+			continue
+		}
+		if r.Lang != "" && !strings.EqualFold(r.Lang, track.Lang) {
+			continue
+		}
+		if !title.MatchString(track.Title) || (r.ExcludeTitle != "" && exclude.MatchString(track.Title)) {
+			continue
+		}
+		return track.ID, true
+	}
+	return 0, false
+}
+
+// Preferences are applied when a file is loaded. Nil fields are not remembered and are left as mpv chose them.
+// Delays are in seconds.
+type Preferences struct {
+	Audio        *TrackRule `json:"audio,omitempty"`
+	Sub          *TrackRule `json:"sub,omitempty"`
+	SecondarySub *TrackRule `json:"secondarySub,omitempty"`
+	SubDelay     *float64   `json:"subDelay,omitempty"`
+	AudioDelay   *float64   `json:"audioDelay,omitempty"`
+	Volume       *float64   `json:"volume,omitempty"`
+}
+
+// Track returns rule for track type, TrackSecondarySub selects from "sub" tracks.
+func (p *Preferences) Track(trackType mpv.TrackType) **TrackRule {
+	switch trackType {
+	case mpv.TrackAudio:
+		return &p.Audio
+	case mpv.TrackSub:
+		return &p.Sub
+	case mpv.TrackSecondarySub:
+		return &p.SecondarySub
+	}
+	return nil
+}
+
+func (p Preferences) IsZero() bool {
+	return p == Preferences{}
+}
+
+func (p Preferences) Validate() error {
+	var errs []error
+	for _, rule := range []*TrackRule{p.Audio, p.Sub, p.SecondarySub} {
+		if rule != nil {
+			errs = append(errs, rule.Validate())
+		}
+	}
+	if p.Volume != nil && *p.Volume < 0 {
+		errs = append(errs, errors.New("volume must be a non-negative number"))
+	}
+	return errors.Join(errs...)
+}
+
+// merge returns p with fields which are set in other replaced.
+func (p Preferences) merge(other Preferences) Preferences {
+	if other.Audio != nil {
+		p.Audio = other.Audio
+	}
+	if other.Sub != nil {
+		p.Sub = other.Sub
+	}
+	if other.SecondarySub != nil {
+		p.SecondarySub = other.SecondarySub
+	}
+	if other.SubDelay != nil {
+		p.SubDelay = other.SubDelay
+	}
+	if other.AudioDelay != nil {
+		p.AudioDelay = other.AudioDelay
+	}
+	if other.Volume != nil {
+		p.Volume = other.Volume
+	}
+	return p
+}
+
+// stored is content of preferences file. Keys are file and directory paths.
+type stored struct {
+	Files       map[string]Preferences `json:"files"`
+	Directories map[string]Preferences `json:"directories"`
+}
+
+type Store struct {
+	m    sync.Mutex
+	data stored
+	// file saves data, it is nil if preferences are not persisted.
+	file *jsonfile.Writer
+}
+
+// NewStore creates store which doesn't persist preferences.
+func NewStore() *Store {
+	return &Store{data: stored{
+		Files:       make(map[string]Preferences),
+		Directories: make(map[string]Preferences),
+	}}
+}
+
+// LoadStore loads preferences from path. Changes are saved back to path.
+func LoadStore(path string) (*Store, error) {
+	s := NewStore()
+	s.file = jsonfile.NewWriter(path, SaveDelay)
+
+	if _, err := jsonfile.Read(path, &s.data); err != nil {
+		return nil, fmt.Errorf("read preferences: %w", err)
+	}
+	if s.data.Files == nil {
+		s.data.Files = make(map[string]Preferences)
+	}
+	if s.data.Directories == nil {
+		s.data.Directories = make(map[string]Preferences)
+	}
+	return s, nil
+}
+
+// Lookup returns preferences of file at path: preferences of its directory overridden by preferences of the file.
+func (s *Store) Lookup(path string) Preferences {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	path = filepath.Clean(path)
+	return s.data.Directories[filepath.Dir(path)].merge(s.data.Files[path])
+}
+
+// Get returns preferences remembered for file at path or for its directory.
+func (s *Store) Get(path string, scope Scope) (Preferences, bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	entries, key := s.entries(path, scope)
+	p, ok := entries[key]
+	return p, ok
+}
+
+// Remember changes preferences of file at path and of its directory with fn. Changes are saved after
+// SaveDelay, use Flush to save them immediately.
+func (s *Store) Remember(path string, fn func(p *Preferences)) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for _, scope := range []Scope{ScopeFile, ScopeDirectory} {
+		entries, key := s.entries(path, scope)
+		p := entries[key]
+		fn(&p)
+		entries[key] = p
+	}
+	s.schedule()
+}
+
+// Set replaces preferences of file at path or of its directory. Empty preferences are forgotten.
+func (s *Store) Set(path string, scope Scope, p Preferences) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	entries, key := s.entries(path, scope)
+	if p.IsZero() {
+		delete(entries, key)
+	} else {
+		entries[key] = p
+	}
+	return s.save()
+}
+
+// Remove forgets preferences of file at path or of its directory.
+func (s *Store) Remove(path string, scope Scope) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	entries, key := s.entries(path, scope)
+	if _, ok := entries[key]; !ok {
+		return ErrNotFound
+	}
+	delete(entries, key)
+	return s.save()
+}
+
+// entries returns map which keeps preferences of scope and key of path in it, it must be called with s.m locked.
+func (s *Store) entries(path string, scope Scope) (map[string]Preferences, string) {
+	path = filepath.Clean(path)
+	if scope == ScopeDirectory {
+		return s.data.Directories, filepath.Dir(path)
+	}
+	return s.data.Files, path
+}
+
+// Flush saves changes which were not saved by Remember yet.
+func (s *Store) Flush() error {
+	if s.file == nil {
+		return nil
+	}
+	if err := s.file.Flush(); err != nil {
+		return fmt.Errorf("write preferences: %w", err)
+	}
+	return nil
+}
+
+// schedule saves copy of data in background, it must be called with s.m locked.
+func (s *Store) schedule() {
+	if s.file != nil {
+		s.file.Schedule(stored{Files: maps.Clone(s.data.Files), Directories: maps.Clone(s.data.Directories)})
+	}
+}
+
+// save must be called with s.m locked.
+func (s *Store) save() error {
+	s.schedule()
+	return s.Flush()
+}
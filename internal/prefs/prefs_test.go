@@ -0,0 +1,107 @@
+package prefs_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/miere43/mpvrc/internal/mpv"
+	"github.com/miere43/mpvrc/internal/prefs"
+	"github.com/stretchr/testify/suite"
+)
+
+type prefsSuite struct {
+	suite.Suite
+}
+
+func TestPrefs(t *testing.T) {
+	suite.Run(t, new(prefsSuite))
+}
+
+var testTracks = []mpv.Track{
+	{ID: 1, Type: "audio", Lang: "eng"},
+	{ID: 2, Type: "audio", Lang: "jpn"},
+	{ID: 1, Type: "sub", Lang: "eng", Title: "Signs & Songs"},
+	{ID: 2, Type: "sub", Lang: "eng", Title: "Full Subtitles"},
+	{ID: 3, Type: "sub", Lang: "jpn"},
+}
+
+func (s *prefsSuite) TestMatch() {
+	for _, test := range []struct {
+		rule      prefs.TrackRule
+		trackType mpv.TrackType
+		id        int
+		ok        bool
+	}{
+		{prefs.TrackRule{Lang: "JPN"}, mpv.TrackAudio, 2, true},
+		{prefs.TrackRule{Lang: "eng"}, mpv.TrackSub, 1, true},
+		{prefs.TrackRule{Lang: "eng", ExcludeTitle: "(?i)signs"}, mpv.TrackSub, 2, true},
+		{prefs.TrackRule{Title: "(?i)full"}, mpv.TrackSub, 2, true},
+		{prefs.RuleFor(testTracks[3]), mpv.TrackSub, 2, true},
+		{prefs.TrackRule{Lang: "ger"}, mpv.TrackSub, 0, false},
+		{prefs.TrackRule{Lang: "jpn"}, mpv.TrackVideo, 0, false},
+		{prefs.TrackRule{Title: "("}, mpv.TrackSub, 0, false},
+		{prefs.TrackRule{Off: true}, mpv.TrackSub, 0, false},
+	} {
+		id, ok := test.rule.Match(testTracks, test.trackType)
+		s.Equal(test.ok, ok, "%+v", test.rule)
+		s.Equal(test.id, id, "%+v", test.rule)
+	}
+}
+
+func (s *prefsSuite) TestRememberAndLookup() {
+	r := s.Require()
+	path := filepath.Join(s.T().TempDir(), "preferences.json")
+	store, err := prefs.LoadStore(path)
+	r.NoError(err)
+
+	episode1 := filepath.Join("/series", "Show - 01.mkv")
+	episode2 := filepath.Join("/series", "Show - 02.mkv")
+	delay := -0.5
+	store.Remember(episode1, func(p *prefs.Preferences) {
+		p.Audio = &prefs.TrackRule{Lang: "jpn"}
+		p.SubDelay = &delay
+	})
+
+	// Preferences of the series apply to other episodes.
+	s.Equal(prefs.Preferences{Audio: &prefs.TrackRule{Lang: "jpn"}, SubDelay: &delay}, store.Lookup(episode2))
+
+	volume := 80.0
+	store.Remember(episode2, func(p *prefs.Preferences) {
+		p.Audio = &prefs.TrackRule{Lang: "eng"}
+		p.Volume = &volume
+	})
+	r.NoError(store.Set(episode2, prefs.ScopeDirectory, prefs.Preferences{Audio: &prefs.TrackRule{Lang: "jpn"}}))
+
+	// Preferences of the file override preferences of the series.
+	s.Equal(prefs.Preferences{Audio: &prefs.TrackRule{Lang: "eng"}, Volume: &volume}, store.Lookup(episode2))
+	s.Equal(prefs.Preferences{Audio: &prefs.TrackRule{Lang: "jpn"}, SubDelay: &delay}, store.Lookup(episode1))
+
+	// Remembered changes are saved in background, Set saves all changes immediately.
+	loaded, err := prefs.LoadStore(path)
+	r.NoError(err)
+	s.Equal(store.Lookup(episode2), loaded.Lookup(episode2))
+	directory, ok := loaded.Get(episode1, prefs.ScopeDirectory)
+	s.True(ok)
+	s.Equal(prefs.Preferences{Audio: &prefs.TrackRule{Lang: "jpn"}}, directory)
+
+	r.NoError(loaded.Remove(episode2, prefs.ScopeFile))
+	s.ErrorIs(loaded.Remove(episode2, prefs.ScopeFile), prefs.ErrNotFound)
+	r.NoError(loaded.Set(episode2, prefs.ScopeDirectory, prefs.Preferences{}))
+	_, ok = loaded.Get(episode2, prefs.ScopeDirectory)
+	s.False(ok)
+	s.True(loaded.Lookup(episode2).IsZero())
+
+	store.Remember(episode1, func(p *prefs.Preferences) { p.Volume = &volume })
+	r.NoError(store.Flush())
+	loaded, err = prefs.LoadStore(path)
+	r.NoError(err)
+	s.Equal(&volume, loaded.Lookup(episode1).Volume)
+}
+
+func (s *prefsSuite) TestValidate() {
+	s.NoError(prefs.Preferences{Sub: &prefs.TrackRule{Title: "(?i)full", ExcludeTitle: "signs"}}.Validate())
+	s.Error(prefs.Preferences{Sub: &prefs.TrackRule{Title: "("}}.Validate())
+	s.Error(prefs.Preferences{SecondarySub: &prefs.TrackRule{ExcludeTitle: "["}}.Validate())
+	volume := -1.0
+	s.Error(prefs.Preferences{Volume: &volume}.Validate())
+}
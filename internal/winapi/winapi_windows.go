@@ -15,6 +15,9 @@ const (
 
 	ERROR_PIPE_BUSY      syscall.Errno = 231
 	ERROR_PIPE_CONNECTED syscall.Errno = 535
+
+	MB_OK        = 0x00000000
+	MB_ICONERROR = 0x00000010
 )
 
 var kernel32 *syscall.LazyDLL
@@ -22,6 +25,8 @@ var createEvent *syscall.LazyProc
 var getOverlappedResult *syscall.LazyProc
 var createNamedPipe *syscall.LazyProc
 var connectNamedPipe *syscall.LazyProc
+var user32 *syscall.LazyDLL
+var messageBox *syscall.LazyProc
 
 func init() {
 	kernel32 = syscall.NewLazyDLL("kernel32.dll")
@@ -29,6 +34,8 @@ func init() {
 	getOverlappedResult = kernel32.NewProc("GetOverlappedResult")
 	createNamedPipe = kernel32.NewProc("CreateNamedPipeW")
 	connectNamedPipe = kernel32.NewProc("ConnectNamedPipe")
+	user32 = syscall.NewLazyDLL("user32.dll")
+	messageBox = user32.NewProc("MessageBoxW")
 }
 
 func CreateEventW(lpEventAttributes uintptr, bManualReset, bInitialState bool) (syscall.Handle, error) {
@@ -116,3 +123,25 @@ func ConnectNamedPipe(handle syscall.Handle, overlapped *syscall.Overlapped) err
 	}
 	return nil
 }
+
+func MessageBox(caption string, text string, flags uint32) error {
+	caption16, err := syscall.UTF16PtrFromString(caption)
+	if err != nil {
+		return err
+	}
+	text16, err := syscall.UTF16PtrFromString(text)
+	if err != nil {
+		return err
+	}
+
+	ret, _, callErr := messageBox.Call(
+		0, // hWnd
+		uintptr(unsafe.Pointer(text16)),
+		uintptr(unsafe.Pointer(caption16)),
+		uintptr(flags),
+	)
+	if ret == 0 {
+		return fmt.Errorf("MessageBoxW failed: %w", callErr)
+	}
+	return nil
+}